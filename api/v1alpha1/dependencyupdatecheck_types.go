@@ -60,12 +60,49 @@ type DependencyUpdateCheckSpec struct {
 	// If omitted, MintMaker will run for all namespaces.
 	// +optional
 	Namespaces []NamespaceSpec `json:"namespaces,omitempty"`
+
+	// Specifies the priority of the PipelineRuns created for this check.
+	// Pending PipelineRuns with a higher priority are started first, e.g.
+	// for security-driven updates. Defaults to 0, the priority of routine runs.
+	// +kubebuilder:validation:Minimum=0
+	// +kubebuilder:validation:Maximum=1000
+	// +optional
+	Priority int32 `json:"priority,omitempty"`
+}
+
+// ComponentReference identifies a Component
+type ComponentReference struct {
+	Name      string `json:"name"`
+	Namespace string `json:"namespace"`
+
+	// Number of failed attempts to create the PipelineRun of the component
+	// +optional
+	Attempts int32 `json:"attempts,omitempty"`
 }
 
 // DependencyUpdateCheckStatus defines the observed state of DependencyUpdateCheck
 type DependencyUpdateCheckStatus struct {
 	// INSERT ADDITIONAL STATUS FIELD - define observed state of cluster
 	// Important: Run "make" to regenerate code after modifying this file
+
+	// Components for which a PipelineRun is still to be created. PipelineRuns
+	// are created once there is room in the queue of pending PipelineRuns.
+	// +optional
+	PendingComponents []ComponentReference `json:"pendingComponents,omitempty"`
+
+	// Components whose PipelineRun couldn't be created within the maximum
+	// number of attempts of the retry policy
+	// +optional
+	FailedComponents []ComponentReference `json:"failedComponents,omitempty"`
+
+	// Name of the Secret with the registry credentials mounted by the
+	// PipelineRuns of this check
+	// +optional
+	RegistrySecret string `json:"registrySecret,omitempty"`
+
+	// Number of PipelineRuns created for this check
+	// +optional
+	CreatedPipelineRuns int32 `json:"createdPipelineRuns,omitempty"`
 }
 
 // +kubebuilder:object:root=true
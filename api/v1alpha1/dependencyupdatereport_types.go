@@ -0,0 +1,109 @@
+// Copyright 2024 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// DependencyUpdate is an update of a dependency proposed by Renovate
+type DependencyUpdate struct {
+	// Package manager of the dependency, e.g. dockerfile or gomod
+	Manager string `json:"manager"`
+
+	// File declaring the dependency
+	// +optional
+	PackageFile string `json:"packageFile,omitempty"`
+
+	// Name of the dependency
+	Name string `json:"name"`
+
+	// Version currently used
+	// +optional
+	CurrentVersion string `json:"currentVersion,omitempty"`
+
+	// Version proposed by the update
+	// +optional
+	NewVersion string `json:"newVersion,omitempty"`
+
+	// Type of the update, e.g. major, minor, patch or digest
+	// +optional
+	UpdateType string `json:"updateType,omitempty"`
+
+	// Number of the pull request proposing the update, if one is open
+	// +optional
+	PullRequest int32 `json:"pullRequest,omitempty"`
+
+	// Whether the update fixes a vulnerability known in the OSV database
+	// +optional
+	Vulnerable bool `json:"vulnerable,omitempty"`
+
+	// Severity of the fixed vulnerability, e.g. HIGH
+	// +optional
+	VulnerabilitySeverity string `json:"vulnerabilitySeverity,omitempty"`
+}
+
+// DependencyUpdateReportStatus is the outcome of the last successful Renovate run of a component
+type DependencyUpdateReportStatus struct {
+	// Name of the component the report is about
+	Component string `json:"component"`
+
+	// Name of the PipelineRun which produced the report
+	PipelineRun string `json:"pipelineRun"`
+
+	// When the report was last updated
+	UpdateTimestamp metav1.Time `json:"updateTimestamp"`
+
+	// Number of pending updates found by Renovate. The report lists only the
+	// first updates when there are too many to be passed in a Tekton result.
+	TotalUpdates int32 `json:"totalUpdates"`
+
+	// Number of the listed updates which fix a vulnerability
+	VulnerableUpdates int32 `json:"vulnerableUpdates"`
+
+	// The pending updates
+	// +optional
+	Updates []DependencyUpdate `json:"updates,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+// +kubebuilder:printcolumn:name="Component",type=string,JSONPath=`.status.component`
+// +kubebuilder:printcolumn:name="Updates",type=integer,JSONPath=`.status.totalUpdates`
+// +kubebuilder:printcolumn:name="Vulnerable",type=integer,JSONPath=`.status.vulnerableUpdates`
+// +kubebuilder:printcolumn:name="Updated",type=date,JSONPath=`.status.updateTimestamp`
+
+// DependencyUpdateReport is the Schema for the dependencyupdatereports API.
+// The controller writes one report per component, in the namespace of the
+// component, from the results of its Renovate runs.
+type DependencyUpdateReport struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Status DependencyUpdateReportStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// DependencyUpdateReportList contains a list of DependencyUpdateReport
+type DependencyUpdateReportList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []DependencyUpdateReport `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&DependencyUpdateReport{}, &DependencyUpdateReportList{})
+}
@@ -0,0 +1,74 @@
+// Copyright 2024 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// RenovateConfigOverrideSpec defines the Renovate config forced on a set of components
+type RenovateConfigOverrideSpec struct {
+	// Specifies the components the override applies to, like the namespaces
+	// of a DependencyUpdateCheck. If omitted, the override applies to all
+	// components.
+	// +optional
+	Namespaces []NamespaceSpec `json:"namespaces,omitempty"`
+
+	// Renovate config deep-merged into the config generated for the selected
+	// components, e.g. {"schedule": ["before 6am"]}. Objects are merged
+	// recursively, rules such as packageRules and hostRules are appended and
+	// the other values are replaced. The config of the repository still takes
+	// precedence, unless the options are set under "force".
+	// +kubebuilder:pruning:PreserveUnknownFields
+	// +kubebuilder:validation:Type=object
+	// +required
+	Config runtime.RawExtension `json:"config"`
+}
+
+// +kubebuilder:object:root=true
+
+// RenovateConfigOverride is the Schema for the renovateconfigoverrides API.
+// Only the overrides in the mintmaker namespace are applied. The generated
+// config is layered in this order, each layer taking precedence over the
+// previous ones:
+//  1. renovate.json of the renovate-config ConfigMap
+//  2. self_hosted.json of the renovate-config ConfigMap
+//  3. the host rules of the registry credentials, added after the host
+//     rules of the previous layers
+//  4. the overrides selecting the component, from the least to the most
+//     specific selector: all components, a namespace, an application and
+//     finally components. Overrides as specific as each other are applied by
+//     name.
+//  5. the repository, platform and credentials set by MintMaker
+type RenovateConfigOverride struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec RenovateConfigOverrideSpec `json:"spec,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// RenovateConfigOverrideList contains a list of RenovateConfigOverride
+type RenovateConfigOverrideList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []RenovateConfigOverride `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&RenovateConfigOverride{}, &RenovateConfigOverrideList{})
+}
@@ -19,7 +19,7 @@
 package v1alpha1
 
 import (
-	runtime "k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime"
 )
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
@@ -42,13 +42,43 @@ func (in *ApplicationSpec) DeepCopy() *ApplicationSpec {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ComponentReference) DeepCopyInto(out *ComponentReference) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ComponentReference.
+func (in *ComponentReference) DeepCopy() *ComponentReference {
+	if in == nil {
+		return nil
+	}
+	out := new(ComponentReference)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DependencyUpdate) DeepCopyInto(out *DependencyUpdate) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DependencyUpdate.
+func (in *DependencyUpdate) DeepCopy() *DependencyUpdate {
+	if in == nil {
+		return nil
+	}
+	out := new(DependencyUpdate)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *DependencyUpdateCheck) DeepCopyInto(out *DependencyUpdateCheck) {
 	*out = *in
 	out.TypeMeta = in.TypeMeta
 	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
 	in.Spec.DeepCopyInto(&out.Spec)
-	out.Status = in.Status
+	in.Status.DeepCopyInto(&out.Status)
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DependencyUpdateCheck.
@@ -126,6 +156,16 @@ func (in *DependencyUpdateCheckSpec) DeepCopy() *DependencyUpdateCheckSpec {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *DependencyUpdateCheckStatus) DeepCopyInto(out *DependencyUpdateCheckStatus) {
 	*out = *in
+	if in.PendingComponents != nil {
+		in, out := &in.PendingComponents, &out.PendingComponents
+		*out = make([]ComponentReference, len(*in))
+		copy(*out, *in)
+	}
+	if in.FailedComponents != nil {
+		in, out := &in.FailedComponents, &out.FailedComponents
+		*out = make([]ComponentReference, len(*in))
+		copy(*out, *in)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DependencyUpdateCheckStatus.
@@ -138,6 +178,85 @@ func (in *DependencyUpdateCheckStatus) DeepCopy() *DependencyUpdateCheckStatus {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DependencyUpdateReport) DeepCopyInto(out *DependencyUpdateReport) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Status.DeepCopyInto(&out.Status)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DependencyUpdateReport.
+func (in *DependencyUpdateReport) DeepCopy() *DependencyUpdateReport {
+	if in == nil {
+		return nil
+	}
+	out := new(DependencyUpdateReport)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *DependencyUpdateReport) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DependencyUpdateReportList) DeepCopyInto(out *DependencyUpdateReportList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]DependencyUpdateReport, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DependencyUpdateReportList.
+func (in *DependencyUpdateReportList) DeepCopy() *DependencyUpdateReportList {
+	if in == nil {
+		return nil
+	}
+	out := new(DependencyUpdateReportList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *DependencyUpdateReportList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DependencyUpdateReportStatus) DeepCopyInto(out *DependencyUpdateReportStatus) {
+	*out = *in
+	in.UpdateTimestamp.DeepCopyInto(&out.UpdateTimestamp)
+	if in.Updates != nil {
+		in, out := &in.Updates, &out.Updates
+		*out = make([]DependencyUpdate, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DependencyUpdateReportStatus.
+func (in *DependencyUpdateReportStatus) DeepCopy() *DependencyUpdateReportStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(DependencyUpdateReportStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *NamespaceSpec) DeepCopyInto(out *NamespaceSpec) {
 	*out = *in
@@ -159,3 +278,84 @@ func (in *NamespaceSpec) DeepCopy() *NamespaceSpec {
 	in.DeepCopyInto(out)
 	return out
 }
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RenovateConfigOverride) DeepCopyInto(out *RenovateConfigOverride) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RenovateConfigOverride.
+func (in *RenovateConfigOverride) DeepCopy() *RenovateConfigOverride {
+	if in == nil {
+		return nil
+	}
+	out := new(RenovateConfigOverride)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *RenovateConfigOverride) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RenovateConfigOverrideList) DeepCopyInto(out *RenovateConfigOverrideList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]RenovateConfigOverride, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RenovateConfigOverrideList.
+func (in *RenovateConfigOverrideList) DeepCopy() *RenovateConfigOverrideList {
+	if in == nil {
+		return nil
+	}
+	out := new(RenovateConfigOverrideList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *RenovateConfigOverrideList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RenovateConfigOverrideSpec) DeepCopyInto(out *RenovateConfigOverrideSpec) {
+	*out = *in
+	if in.Namespaces != nil {
+		in, out := &in.Namespaces, &out.Namespaces
+		*out = make([]NamespaceSpec, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	in.Config.DeepCopyInto(&out.Config)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RenovateConfigOverrideSpec.
+func (in *RenovateConfigOverrideSpec) DeepCopy() *RenovateConfigOverrideSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(RenovateConfigOverrideSpec)
+	in.DeepCopyInto(out)
+	return out
+}
@@ -16,8 +16,12 @@ package main
 
 import (
 	"crypto/tls"
+	"errors"
 	"flag"
+	"fmt"
+	"net/http"
 	"os"
+	"time"
 
 	// Import all Kubernetes client auth plugins (e.g. Azure, GCP, OIDC, etc.)
 	// to ensure that exec-entrypoint and run can make use of them.
@@ -58,7 +62,40 @@ func init() {
 	// +kubebuilder:scaffold:scheme
 }
 
+// validateConfig checks a config file offline, without starting the manager:
+//
+//	manager config validate <file>
+func validateConfig(args []string) int {
+	if len(args) != 1 {
+		fmt.Fprintln(os.Stderr, "usage: manager config validate <file>")
+		return 2
+	}
+	data, err := os.ReadFile(args[0])
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	if _, err := config.ParseFile(data); err != nil {
+		var validationErrors config.ValidationErrors
+		if !errors.As(err, &validationErrors) {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		fmt.Fprintf(os.Stderr, "%s is not valid:\n", args[0])
+		for _, fieldError := range validationErrors {
+			fmt.Fprintf(os.Stderr, "  %s\n", fieldError.Error())
+		}
+		return 1
+	}
+	fmt.Printf("%s is valid\n", args[0])
+	return 0
+}
+
 func main() {
+	if len(os.Args) > 2 && os.Args[1] == "config" && os.Args[2] == "validate" {
+		os.Exit(validateConfig(os.Args[3:]))
+	}
+
 	var metricsAddr string
 	var enableLeaderElection bool
 	var probeAddr string
@@ -128,6 +165,7 @@ func main() {
 
 	mgr, err := ctrl.NewManager(ctrl.GetConfigOrDie(), ctrl.Options{
 		Scheme:                 scheme,
+		Cache:                  controller.CacheOptions(),
 		Metrics:                metricsServerOptions,
 		WebhookServer:          webhookServer,
 		HealthProbeBindAddress: probeAddr,
@@ -153,24 +191,71 @@ func main() {
 	ctx := ctrl.SetupSignalHandler()
 	config.InitGlobalConfig(ctx, mgr.GetAPIReader())
 
+	if err = controller.SetupIndexes(ctx, mgr); err != nil {
+		setupLog.Error(err, "unable to set up cache indexes")
+		os.Exit(1)
+	}
+
 	if err = (&controller.DependencyUpdateCheckReconciler{
-		Client: mgr.GetClient(),
-		Scheme: mgr.GetScheme(),
-		Config: config.GetConfig(),
+		Client:    mgr.GetClient(),
+		Scheme:    mgr.GetScheme(),
+		GetConfig: config.GetConfig,
 	}).SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "DependencyUpdateCheck")
 		os.Exit(1)
 	}
 
 	if err = (&controller.PipelineRunReconciler{
-		Client: mgr.GetClient(),
-		Scheme: mgr.GetScheme(),
-		Config: config.GetConfig(),
+		Client:    mgr.GetClient(),
+		Scheme:    mgr.GetScheme(),
+		GetConfig: config.GetConfig,
 	}).SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "PipelineRun")
 		os.Exit(1)
 	}
 
+	if err = (&controller.PipelineRunRetryReconciler{
+		Client:    mgr.GetClient(),
+		Scheme:    mgr.GetScheme(),
+		GetConfig: config.GetConfig,
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "PipelineRunRetry")
+		os.Exit(1)
+	}
+
+	if err = (&controller.PipelineRunResultReconciler{
+		Client:   mgr.GetClient(),
+		Scheme:   mgr.GetScheme(),
+		Recorder: mgr.GetEventRecorderFor("mintmaker"),
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "PipelineRunResult")
+		os.Exit(1)
+	}
+
+	if err = (&controller.PipelineRunGarbageCollector{
+		Client:    mgr.GetClient(),
+		GetConfig: config.GetConfig,
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create garbage collector", "controller", "PipelineRunGarbageCollector")
+		os.Exit(1)
+	}
+
+	if err = (&controller.OSVDatabaseWatcher{
+		Client:     mgr.GetClient(),
+		GetConfig:  config.GetConfig,
+		HTTPClient: &http.Client{Timeout: 30 * time.Second},
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create OSV database watcher", "controller", "OSVDatabaseWatcher")
+		os.Exit(1)
+	}
+
+	if err = (&controller.ConfigReconciler{
+		Client: mgr.GetClient(),
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "Config")
+		os.Exit(1)
+	}
+
 	if err = (&controller.EventReconciler{
 		Client: mgr.GetClient(),
 		Scheme: mgr.GetScheme(),
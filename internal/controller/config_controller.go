@@ -0,0 +1,83 @@
+// Copyright 2024 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controller
+
+import (
+	"context"
+	"errors"
+
+	tektonv1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+	corev1 "k8s.io/api/core/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	ctrllog "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+
+	"github.com/konflux-ci/mintmaker/internal/pkg/config"
+	. "github.com/konflux-ci/mintmaker/internal/pkg/constant"
+)
+
+// ConfigReconciler reloads the controller config when the ConfigMap holding
+// it changes
+type ConfigReconciler struct {
+	Client client.Client
+}
+
+// +kubebuilder:rbac:groups="",resources=configmaps,verbs=get;list;watch
+
+// Reconcile loads the config from the ConfigMap again and swaps it with the
+// current one. A deleted ConfigMap restores the default config, an invalid
+// config is rejected and the current one is kept.
+func (r *ConfigReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	log := ctrllog.FromContext(ctx).WithName("ConfigController")
+	ctx = ctrllog.IntoContext(ctx, log)
+
+	if _, err := config.ReloadGlobalConfig(ctx, r.Client); err != nil {
+		// Invalid configs are only reloaded once the ConfigMap is fixed
+		var validationErrors config.ValidationErrors
+		if errors.As(err, &validationErrors) {
+			return ctrl.Result{}, nil
+		}
+		return ctrl.Result{}, err
+	}
+	return ctrl.Result{}, nil
+}
+
+// CacheOptions restricts the cache of the Manager to the objects the
+// controllers read. ConfigMaps, TaskRuns and Pods are only read in the
+// mintmaker namespace, so the ones of the other namespaces, e.g. the TaskRuns
+// and Pods of the builds of the tenants, aren't watched.
+func CacheOptions() cache.Options {
+	mintmakerNamespace := map[string]cache.Config{MintMakerNamespaceName: {}}
+	return cache.Options{
+		ByObject: map[client.Object]cache.ByObject{
+			&corev1.ConfigMap{}: {Namespaces: mintmakerNamespace},
+			&tektonv1.TaskRun{}: {Namespaces: mintmakerNamespace},
+			&corev1.Pod{}:       {Namespaces: mintmakerNamespace},
+		},
+	}
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *ConfigReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		Named("config").
+		For(&corev1.ConfigMap{}).
+		WithEventFilter(predicate.NewPredicateFuncs(func(object client.Object) bool {
+			return object.GetNamespace() == MintMakerNamespaceName && object.GetName() == config.ConfigMapName
+		})).
+		Complete(r)
+}
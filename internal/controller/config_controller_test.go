@@ -0,0 +1,97 @@
+// Copyright 2024 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controller
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	tektonv1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/konflux-ci/mintmaker/internal/pkg/config"
+	. "github.com/konflux-ci/mintmaker/internal/pkg/constant"
+)
+
+var _ = Describe("Config Controller", func() {
+
+	configMap := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      config.ConfigMapName,
+			Namespace: MintMakerNamespaceName,
+		},
+		Data: map[string]string{
+			"config.json": `{"pipelinerun": {"max-parallel-pipelineruns": "7"}}`,
+		},
+	}
+
+	_ = BeforeEach(func() {
+		createNamespace(MintMakerNamespaceName)
+
+		// The config is global, the other tests expect it to be restored
+		previous := config.GetConfig()
+		DeferCleanup(func() {
+			if err := k8sClient.Delete(ctx, configMap.DeepCopy()); err != nil {
+				Expect(apierrors.IsNotFound(err)).To(BeTrue())
+			}
+			Eventually(func() string {
+				return config.Diff(config.GetConfig(), previous)
+			}, timeout, interval).Should(BeEmpty())
+		})
+	})
+
+	It("should reload the config when the ConfigMap changes", func() {
+		Expect(k8sClient.Create(ctx, configMap.DeepCopy())).To(Succeed())
+		Eventually(func() int {
+			return config.GetConfig().PipelineRunConfig.MaxParallelPipelineruns
+		}, timeout, interval).Should(Equal(7))
+
+		updated := &corev1.ConfigMap{}
+		Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(configMap), updated)).To(Succeed())
+		updated.Data["config.json"] = `{"pipelinerun": {"max-parallel-pipelineruns": "12"}}`
+		Expect(k8sClient.Update(ctx, updated)).To(Succeed())
+		Eventually(func() int {
+			return config.GetConfig().PipelineRunConfig.MaxParallelPipelineruns
+		}, timeout, interval).Should(Equal(12))
+
+		// An invalid config is rejected, the current one is kept
+		Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(configMap), updated)).To(Succeed())
+		updated.Data["config.json"] = `{"pipelinerun": {"max-parallel-pipelineruns": "none"}}`
+		Expect(k8sClient.Update(ctx, updated)).To(Succeed())
+		Consistently(func() int {
+			return config.GetConfig().PipelineRunConfig.MaxParallelPipelineruns
+		}, timeout, interval).Should(Equal(12))
+
+		// Without the ConfigMap, the default config is used again
+		Expect(k8sClient.Delete(ctx, updated)).To(Succeed())
+		Eventually(func() int {
+			return config.GetConfig().PipelineRunConfig.MaxParallelPipelineruns
+		}, timeout, interval).Should(Equal(config.DefaultConfig().PipelineRunConfig.MaxParallelPipelineruns))
+	})
+
+	DescribeTable("should only cache the objects of the mintmaker namespace",
+		func(list client.ObjectList) {
+			createNamespace(MintMakerNamespaceName)
+			Eventually(func() error {
+				return cachedClient.List(ctx, list, client.InNamespace(MintMakerNamespaceName))
+			}, timeout, interval).Should(Succeed())
+			Expect(cachedClient.List(ctx, list, client.InNamespace("testnamespace"))).NotTo(Succeed())
+		},
+		Entry("TaskRuns", &tektonv1.TaskRunList{}),
+		Entry("Pods", &corev1.PodList{}),
+	)
+})
@@ -16,15 +16,17 @@ package controller
 
 import (
 	"context"
-	"encoding/json"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
 	"slices"
-	"time"
+	"strconv"
 
+	tektonv1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
-	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/tools/record"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
@@ -33,349 +35,27 @@ import (
 	ctrllog "sigs.k8s.io/controller-runtime/pkg/log"
 	"sigs.k8s.io/controller-runtime/pkg/predicate"
 
-	tektonv1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
-
 	appstudiov1alpha1 "github.com/konflux-ci/application-api/api/v1alpha1"
 
 	mmv1alpha1 "github.com/konflux-ci/mintmaker/api/v1alpha1"
 	"github.com/konflux-ci/mintmaker/internal/pkg/component"
 	"github.com/konflux-ci/mintmaker/internal/pkg/config"
 	. "github.com/konflux-ci/mintmaker/internal/pkg/constant"
-	"github.com/konflux-ci/mintmaker/internal/pkg/tekton"
-	"github.com/konflux-ci/mintmaker/internal/pkg/utils"
 )
 
 // DependencyUpdateCheckReconciler reconciles a DependencyUpdateCheck object
 type DependencyUpdateCheckReconciler struct {
-	Client client.Client
-	Scheme *runtime.Scheme
-	Config *config.ControllerConfig
+	Client    client.Client
+	Scheme    *runtime.Scheme
+	GetConfig config.Getter
 }
 
-func NewDependencyUpdateCheckReconciler(client client.Client, scheme *runtime.Scheme, config *config.ControllerConfig, eventRecorder record.EventRecorder) *DependencyUpdateCheckReconciler {
+func NewDependencyUpdateCheckReconciler(client client.Client, scheme *runtime.Scheme, getConfig config.Getter, eventRecorder record.EventRecorder) *DependencyUpdateCheckReconciler {
 	return &DependencyUpdateCheckReconciler{
-		Client: client,
-		Scheme: scheme,
-		Config: config,
-	}
-}
-
-// getCAConfigMap returns the first ConfigMap found in mintmaker namespace
-// that has the label 'config.openshift.io/inject-trusted-cabundle: "true"'.
-// If no such ConfigMap is found, it returns nil.
-func (r *DependencyUpdateCheckReconciler) getCAConfigMap(ctx context.Context) (*corev1.ConfigMap, error) {
-	configMapList := &corev1.ConfigMapList{}
-	labelSelector := client.MatchingLabels{"config.openshift.io/inject-trusted-cabundle": "true"}
-	listOptions := []client.ListOption{
-		client.InNamespace(MintMakerNamespaceName),
-		labelSelector,
-	}
-	err := r.Client.List(ctx, configMapList, listOptions...)
-	if err != nil {
-		return nil, err
-	}
-
-	if len(configMapList.Items) > 0 {
-		// Just return the configmap
-		return &configMapList.Items[0], nil
+		Client:    client,
+		Scheme:    scheme,
+		GetConfig: getConfig,
 	}
-
-	return nil, nil
-}
-
-// Create a secret that merges all secret with the label:
-// mintmaker.appstudio.redhat.com/secret-type: registry
-// and return the new secret
-func (r *DependencyUpdateCheckReconciler) createMergedPullSecret(ctx context.Context) (*corev1.Secret, error) {
-	log := ctrllog.FromContext(ctx).WithName("DependencyUpdateCheckController")
-	ctx = ctrllog.IntoContext(ctx, log)
-
-	secretList := &corev1.SecretList{}
-	labelSelector := client.MatchingLabels{"mintmaker.appstudio.redhat.com/secret-type": "registry"}
-	listOptions := []client.ListOption{
-		client.InNamespace(MintMakerNamespaceName),
-		labelSelector,
-	}
-
-	err := r.Client.List(ctx, secretList, listOptions...)
-
-	if err != nil {
-		return nil, err
-	}
-
-	if len(secretList.Items) == 0 {
-		// No secrets to merge
-		return nil, nil
-	}
-
-	log.Info(fmt.Sprintf("Found %d secrets to merge", len(secretList.Items)))
-
-	mergedAuths := make(map[string]interface{})
-	for _, secret := range secretList.Items {
-		if secret.Type == corev1.SecretTypeDockerConfigJson {
-			data, exists := secret.Data[".dockerconfigjson"]
-			if !exists {
-				// No .dockerconfigjson section
-				log.Info("Found secret without .dockerconfigjson section")
-				return nil, nil
-			}
-
-			var dockerConfig map[string]interface{}
-			if err := json.Unmarshal(data, &dockerConfig); err != nil {
-				return nil, err
-			}
-
-			auths, exists := dockerConfig["auths"].(map[string]interface{})
-			if !exists {
-				continue
-			}
-
-			for registry, creds := range auths {
-				mergedAuths[registry] = creds
-			}
-		}
-	}
-
-	mergedDockerConfig := map[string]interface{}{
-		"auths": mergedAuths,
-	}
-
-	if len(mergedAuths) == 0 {
-		log.Info("Merged auths empty, skipping creation of secret")
-		return nil, nil
-	}
-
-	mergedConfigJson, err := json.Marshal(mergedDockerConfig)
-	if err != nil {
-		return nil, err
-	}
-
-	timestamp := time.Now().UTC().Format("01021504") // MMDDhhmm, from Go's time formatting reference date "20060102150405"
-	name := fmt.Sprintf("renovate-image-pull-secrets-%s-%s", timestamp, utils.RandomString(5))
-
-	newSecret := &corev1.Secret{
-		ObjectMeta: metav1.ObjectMeta{
-			Name:      name,
-			Namespace: MintMakerNamespaceName,
-		},
-		Type: corev1.SecretTypeDockerConfigJson,
-		Data: map[string][]byte{
-			".dockerconfigjson": []byte(mergedConfigJson),
-		},
-	}
-
-	if err := r.Client.Create(ctx, newSecret); err != nil {
-		return nil, err
-	}
-
-	return newSecret, nil
-}
-
-// createPipelineRun creates and returns a new PipelineRun
-func (r *DependencyUpdateCheckReconciler) createPipelineRun(name string, comp component.GitComponent, ctx context.Context, registrySecret *corev1.Secret) (*tektonv1.PipelineRun, error) {
-
-	log := ctrllog.FromContext(ctx).WithName("DependencyUpdateCheckController")
-	ctx = ctrllog.IntoContext(ctx, log)
-
-	var resources []client.Object
-	defer func() {
-		if len(resources) > 0 {
-			for _, resource := range resources {
-				// Ignore error
-				r.Client.Delete(ctx, resource)
-			}
-		}
-	}()
-
-	renovateConfig, err := comp.GetRenovateConfig(registrySecret)
-	if err != nil {
-		return nil, err
-	}
-	renovateJsConfig := "module.exports = " + renovateConfig
-	// Create ConfigMap for Renovate global configuration
-	renovateConfigMap := &corev1.ConfigMap{
-		ObjectMeta: metav1.ObjectMeta{
-			Name:      name,
-			Namespace: MintMakerNamespaceName,
-		},
-		Data: map[string]string{
-			"config.js": renovateJsConfig,
-		},
-	}
-
-	if err := r.Client.Create(ctx, renovateConfigMap); err != nil {
-		return nil, err
-	}
-	resources = append(resources, renovateConfigMap)
-
-	// Secret for Renovate token (repository access token)
-	renovateSecret := &corev1.Secret{
-		ObjectMeta: metav1.ObjectMeta{
-			Name:      name,
-			Namespace: MintMakerNamespaceName,
-		},
-		Type:       corev1.SecretTypeOpaque,
-		StringData: map[string]string{},
-	}
-
-	// For GitHub repositories, we intentionally do not set the "renovate-token"
-	// key. GitHub tokens generated from the Konflux GitHub application have a
-	// maximum lifespan of 1 hour. Instead of generating a token that might expire
-	// before the pipelinerun starts, we wait for events with "FailedMount" reason,
-	// which happens when pod try to mount the secret but can't find the key in
-	// secret. Then we populate the token in the event controller at that time to
-	// ensure it's valid for the pipelinerun execution.
-	if comp.GetPlatform() != "github" {
-		renovateToken, err := comp.GetToken()
-		if err != nil {
-			return nil, err
-		}
-		renovateSecret.StringData["renovate-token"] = renovateToken
-	}
-
-	if err := r.Client.Create(ctx, renovateSecret); err != nil {
-		return nil, err
-	}
-	resources = append(resources, renovateSecret)
-
-	// Create Secret for RPM activation key to access RPMs that require subscription
-	activationKey, org, rpmKeyErr := comp.GetRPMActivationKey(r.Client, ctx)
-	var rpmSecret *corev1.Secret = nil
-	if rpmKeyErr != nil {
-		log.Info(rpmKeyErr.Error())
-	} else {
-		rpmSecret = &corev1.Secret{
-			ObjectMeta: metav1.ObjectMeta{
-				Name:      name + "-rpm-key",
-				Namespace: MintMakerNamespaceName,
-			},
-			Type: corev1.SecretTypeOpaque,
-			StringData: map[string]string{
-				"activationkey": activationKey,
-				"org":           org,
-			},
-		}
-
-		if err := r.Client.Create(ctx, rpmSecret); err != nil {
-			return nil, err
-		}
-		resources = append(resources, rpmSecret)
-	}
-
-	// Creating the pipelineRun definition
-	builder := tekton.NewPipelineRunBuilder(name, MintMakerNamespaceName).
-		WithLabels(map[string]string{
-			"mintmaker.appstudio.redhat.com/application":  comp.GetApplication(),
-			"mintmaker.appstudio.redhat.com/component":    comp.GetName(),
-			"mintmaker.appstudio.redhat.com/namespace":    comp.GetNamespace(),
-			"mintmaker.appstudio.redhat.com/git-platform": comp.GetPlatform(), // (github, gitlab)
-			"mintmaker.appstudio.redhat.com/git-host":     comp.GetHost(),     // github.com, gitlab.com, gitlab.other.com
-			"mintmaker.appstudio.redhat.com/repository":   utils.NormalizeLabelValue(comp.GetRepository()),
-		}).
-		WithTimeouts(nil)
-	builder.WithServiceAccount("mintmaker-controller-manager")
-
-	cmItems := []corev1.KeyToPath{
-		{
-			Key:  "config.js",
-			Path: "config.js",
-		},
-	}
-	cmOpts := tekton.NewMountOptions().WithTaskName("build").WithStepNames([]string{"renovate"})
-	builder.WithConfigMap(name, "/etc/renovate/config", cmItems, cmOpts)
-
-	secretItems := []corev1.KeyToPath{
-		{
-			Key:  "renovate-token",
-			Path: "renovate-token",
-		},
-	}
-	secretOpts := tekton.NewMountOptions().WithTaskName("build").WithStepNames([]string{"renovate"})
-	builder.WithSecret(name, "/etc/renovate/secret", secretItems, secretOpts)
-
-	if rpmKeyErr == nil {
-		rpmSecretItems := []corev1.KeyToPath{
-			{
-				Key:  "activationkey",
-				Path: "rpm-activationkey",
-			},
-			{
-				Key:  "org",
-				Path: "rpm-org",
-			},
-		}
-		rpmSecretOpts := tekton.NewMountOptions().WithTaskName("build").WithStepNames([]string{"prepare-rpm-cert"})
-		builder.WithSecret(name+"-rpm-key", "/etc/renovate/secret", rpmSecretItems, rpmSecretOpts)
-	}
-
-	// Check if a ConfigMap with the label `config.openshift.io/inject-trusted-cabundle: "true"` exists.
-	// If such a ConfigMap is found, add a volume to the PipelineRun specification to mount this ConfigMap.
-	// The volume will be mounted at '/etc/pki/ca-trust/extracted/pem' within the PipelineRun Pod.
-	caConfigMap, err := r.getCAConfigMap(ctx)
-	if err != nil {
-		log.Error(err, "Failed to get CAConfigMap - moving on")
-	}
-	if caConfigMap != nil {
-		caConfigMapItems := []corev1.KeyToPath{
-			{
-				Key:  "ca-bundle.crt",
-				Path: "tls-ca-bundle.pem",
-			},
-		}
-		caConfigMapOpts := tekton.NewMountOptions().WithTaskName("build").WithStepNames([]string{"renovate"}).WithReadOnly(true)
-		builder.WithConfigMap(caConfigMap.ObjectMeta.Name, "/etc/pki/ca-trust/extracted/pem", caConfigMapItems, caConfigMapOpts)
-	}
-
-	if registrySecret != nil {
-		secretItems := []corev1.KeyToPath{
-			{
-				Key:  ".dockerconfigjson",
-				Path: "config.json",
-			},
-		}
-		secretOpts := tekton.NewMountOptions().WithTaskName("build").WithStepNames([]string{"renovate"}).WithReadOnly(true)
-		builder.WithSecret(registrySecret.ObjectMeta.Name, "/home/renovate/.docker", secretItems, secretOpts)
-	}
-
-	pipelineRun, err := builder.Build()
-	if err != nil {
-		log.Error(err, "failed to build pipeline definition")
-		return nil, err
-	}
-	if err := r.Client.Create(ctx, pipelineRun); err != nil {
-		return nil, err
-	}
-	resources = append(resources, pipelineRun)
-
-	// Set ownership so all resources get deleted once the job is deleted
-	// ownership for renovateSecret
-	if err := controllerutil.SetOwnerReference(pipelineRun, renovateSecret, r.Scheme); err != nil {
-		return nil, err
-	}
-	if err := r.Client.Update(ctx, renovateSecret); err != nil {
-		return nil, err
-	}
-
-	// ownership for RPM secret
-	if rpmKeyErr == nil {
-		if err := controllerutil.SetOwnerReference(pipelineRun, rpmSecret, r.Scheme); err != nil {
-			return nil, err
-		}
-		if err := r.Client.Update(ctx, rpmSecret); err != nil {
-			return nil, err
-		}
-	}
-
-	// ownership for the renovateConfigMap
-	if err := controllerutil.SetOwnerReference(pipelineRun, renovateConfigMap, r.Scheme); err != nil {
-		return nil, err
-	}
-	if err := r.Client.Update(ctx, renovateConfigMap); err != nil {
-		return nil, err
-	}
-
-	resources = nil
-	return pipelineRun, nil
 }
 
 // Reconcile is part of the main kubernetes reconciliation loop which aims to
@@ -401,24 +81,17 @@ func (r *DependencyUpdateCheckReconciler) Reconcile(ctx context.Context, req ctr
 		}
 	}
 
-	// If the DependencyUpdateCheck has been handled before, skip it
+	// If the DependencyUpdateCheck has been handled before, only the PipelineRuns
+	// of its remaining components are created
 	if value, exists := dependencyupdatecheck.Annotations[MintMakerProcessedAnnotationName]; exists && value == "true" {
-		log.Info(fmt.Sprintf("DependencyUpdateCheck has been processed: %v", req.NamespacedName))
-		return ctrl.Result{}, nil
+		if len(dependencyupdatecheck.Status.PendingComponents) == 0 {
+			log.Info(fmt.Sprintf("DependencyUpdateCheck has been processed: %v", req.NamespacedName))
+			return ctrl.Result{}, nil
+		}
+		return r.createPipelineRuns(ctx, dependencyupdatecheck)
 	}
 
-	// Update the DependencyUpdateCheck to add a processed annotation
 	log.Info(fmt.Sprintf("new DependencyUpdateCheck found: %v", req.NamespacedName))
-	if dependencyupdatecheck.Annotations == nil {
-		dependencyupdatecheck.Annotations = map[string]string{}
-	}
-	dependencyupdatecheck.Annotations[MintMakerProcessedAnnotationName] = "true"
-
-	err = r.Client.Update(ctx, dependencyupdatecheck)
-	if err != nil {
-		log.Error(err, "failed to update DependencyUpdateCheck annotations")
-		return ctrl.Result{}, nil
-	}
 
 	var gatheredComponents []appstudiov1alpha1.Component
 	if len(dependencyupdatecheck.Spec.Namespaces) > 0 {
@@ -449,10 +122,10 @@ func (r *DependencyUpdateCheckReconciler) Reconcile(ctx context.Context, req ctr
 
 	log.Info("found components with mintmaker disabled", "components", len(gatheredComponents)-len(componentList))
 	if len(componentList) == 0 {
-		return ctrl.Result{}, nil
+		return ctrl.Result{}, r.markProcessed(ctx, dependencyupdatecheck)
 	}
 
-	registrySecret, _ := r.createMergedPullSecret(ctx)
+	registrySecret, _ := newPipelineRunCreator(r.Client, r.Scheme, &r.GetConfig().PipelineRunConfig).createMergedPullSecret(ctx)
 	// ignore the error, image pull secret is not required for all repositories
 	// and set the ownership for registrySecret
 	if registrySecret != nil {
@@ -463,12 +136,50 @@ func (r *DependencyUpdateCheckReconciler) Reconcile(ctx context.Context, req ctr
 				log.Info(fmt.Sprintf("failed to update the registry secret: %s", err.Error()))
 			}
 		}
+		dependencyupdatecheck.Status.RegistrySecret = registrySecret.Name
 	}
 
+	// The PipelineRuns aren't created right away, the components are tracked
+	// in the status until there is room in the queue of pending PipelineRuns.
+	// The DependencyUpdateCheck is only marked as processed once they're
+	// saved, otherwise it's processed again.
+	dependencyupdatecheck.Status.PendingComponents = r.uniqueRepositoryComponents(ctx, componentList)
+	if err := r.Client.Status().Update(ctx, dependencyupdatecheck); err != nil {
+		log.Error(err, "failed to update DependencyUpdateCheck status")
+		return ctrl.Result{}, err
+	}
+	if err := r.markProcessed(ctx, dependencyupdatecheck); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	return r.createPipelineRuns(ctx, dependencyupdatecheck)
+}
+
+// markProcessed adds the processed annotation to the DependencyUpdateCheck,
+// its components are then read from its status
+func (r *DependencyUpdateCheckReconciler) markProcessed(ctx context.Context, dependencyupdatecheck *mmv1alpha1.DependencyUpdateCheck) error {
+	if dependencyupdatecheck.Annotations == nil {
+		dependencyupdatecheck.Annotations = map[string]string{}
+	}
+	dependencyupdatecheck.Annotations[MintMakerProcessedAnnotationName] = "true"
+	if err := r.Client.Update(ctx, dependencyupdatecheck); err != nil {
+		ctrllog.FromContext(ctx).Error(err, "failed to update DependencyUpdateCheck annotations")
+		return err
+	}
+	return nil
+}
+
+// uniqueRepositoryComponents returns the components for which a PipelineRun
+// is created. We need to create only one PipelineRun for a combination of
+// repository+branch. We cannot use repository only, because the branch is
+// used in Renovate's baseBranch config option.
+func (r *DependencyUpdateCheckReconciler) uniqueRepositoryComponents(ctx context.Context, componentList []appstudiov1alpha1.Component) []mmv1alpha1.ComponentReference {
+	log := ctrllog.FromContext(ctx)
+
 	// Track components for which we already created a PipelineRun
 	processedComponents := make([]string, 0)
 
-	timestamp := time.Now().UTC().Format("01021504") // MMDDhhmm, from Go's time formatting reference date "20060102150405"
+	var components []mmv1alpha1.ComponentReference
 	for _, appstudioComponent := range componentList {
 		comp, err := component.NewGitComponent(&appstudioComponent, r.Client, ctx)
 		if err != nil {
@@ -476,34 +187,148 @@ func (r *DependencyUpdateCheckReconciler) Reconcile(ctx context.Context, req ctr
 			continue
 		}
 
-		// We need to create only one PipelineRun for a combination
-		// of repository+branch. We cannot use repository only,
-		// because the branch is used in Renovate's baseBranch config option.
 		branch, _ := comp.GetBranch()
 		key := fmt.Sprintf("%s/%s@%s", comp.GetHost(), comp.GetRepository(), branch)
 
-		log.Info(fmt.Sprintf("check if PipelineRun has been created for %s", key))
+		log.Info(fmt.Sprintf("check if PipelineRun will be created for %s", key))
 
 		if slices.Contains(processedComponents, key) {
-			// PipelineRun has already been created for this repo-branch
+			// PipelineRun will be created for this repo-branch
 			continue
 		} else {
 			processedComponents = append(processedComponents, key)
 		}
 
-		log.Info(fmt.Sprintf("creating pending PipelineRun for %s", key))
-		plrName := fmt.Sprintf("renovate-%s-%s", timestamp, utils.RandomString(8))
-		pipelinerun, err := r.createPipelineRun(plrName, comp, ctx, registrySecret)
+		components = append(components, mmv1alpha1.ComponentReference{
+			Name:      appstudioComponent.Name,
+			Namespace: appstudioComponent.Namespace,
+		})
+	}
+	return components
+}
+
+// createPipelineRuns creates the PipelineRuns for the pending components of
+// the DependencyUpdateCheck, as long as there is room in the queue of pending
+// PipelineRuns. This keeps the number of Secrets holding tokens low, and the
+// tokens fresh. The DependencyUpdateCheck is requeued until PipelineRuns
+// have been created for all its components. A component is only removed from
+// the pending components once its PipelineRun exists, and the status is
+// written after each component. Components which failed are moved to the end
+// of the queue and retried later, up to the maximum number of attempts of the
+// retry policy, then they're moved to the failed components.
+func (r *DependencyUpdateCheckReconciler) createPipelineRuns(ctx context.Context, dependencyupdatecheck *mmv1alpha1.DependencyUpdateCheck) (ctrl.Result, error) {
+	log := ctrllog.FromContext(ctx)
+
+	pendingRuns, err := listPipelineRunsByState(ctx, r.Client, MintMakerNamespaceName, pipelineRunStatePending)
+	if err != nil {
+		log.Error(err, "unable to list pending PipelineRuns")
+		return ctrl.Result{}, err
+	}
+	cfg := r.GetConfig()
+	slots := cfg.PipelineRunConfig.MaxPendingPipelineruns - len(pendingRuns)
+
+	var registrySecret *corev1.Secret
+	if name := dependencyupdatecheck.Status.RegistrySecret; name != "" {
+		registrySecret = &corev1.Secret{}
+		if err := r.Client.Get(ctx, types.NamespacedName{Namespace: MintMakerNamespaceName, Name: name}, registrySecret); err != nil {
+			log.Info(fmt.Sprintf("failed to get the registry secret %s: %s", name, err.Error()))
+			registrySecret = nil
+		}
+	}
+
+	creator := newPipelineRunCreator(r.Client, r.Scheme, &cfg.PipelineRunConfig)
+	labels := map[string]string{MintMakerPriorityLabel: strconv.Itoa(int(dependencyupdatecheck.Spec.Priority))}
+
+	status := &dependencyupdatecheck.Status
+	// Each component is tried at most once per reconciliation
+	for attempts := len(status.PendingComponents); slots > 0 && attempts > 0; attempts-- {
+		componentRef := status.PendingComponents[0]
+		created, err := r.createPipelineRun(ctx, creator, dependencyupdatecheck, componentRef, registrySecret, labels)
+		status.PendingComponents = status.PendingComponents[1:]
 		if err != nil {
-			log.Info(fmt.Sprintf("failed to create PipelineRun for %s: %s", appstudioComponent.Name, err.Error()))
-		} else {
-			log.Info(fmt.Sprintf("created PipelineRun %s", pipelinerun.Name))
+			componentRef.Attempts++
+			if int(componentRef.Attempts) >= cfg.PipelineRunConfig.RetryPolicy.MaxAttempts {
+				log.Error(err, fmt.Sprintf("failed to create PipelineRun for %s/%s, giving up after %d attempts",
+					componentRef.Namespace, componentRef.Name, componentRef.Attempts))
+				status.FailedComponents = append(status.FailedComponents, componentRef)
+			} else {
+				log.Info(fmt.Sprintf("failed to create PipelineRun for %s/%s, retrying later: %s",
+					componentRef.Namespace, componentRef.Name, err.Error()))
+				status.PendingComponents = append(status.PendingComponents, componentRef)
+			}
+		} else if created {
+			status.CreatedPipelineRuns++
+			slots--
+		}
+		if err := r.Client.Status().Update(ctx, dependencyupdatecheck); err != nil {
+			log.Error(err, "failed to update DependencyUpdateCheck status")
+			return ctrl.Result{}, err
 		}
 	}
 
+	if len(status.PendingComponents) > 0 {
+		log.Info("waiting to create the remaining PipelineRuns", "components", len(status.PendingComponents))
+		return ctrl.Result{RequeueAfter: cfg.PipelineRunConfig.SchedulerResyncPeriod}, nil
+	}
 	return ctrl.Result{}, nil
 }
 
+// createPipelineRun creates the PipelineRun of a pending component, and
+// returns whether it was created. The name of the PipelineRun is
+// deterministic, so a component whose PipelineRun was created before the
+// status could be written isn't run twice. A component which no longer exists
+// is skipped. An error means the component has to be retried.
+func (r *DependencyUpdateCheckReconciler) createPipelineRun(
+	ctx context.Context,
+	creator *pipelineRunCreator,
+	dependencyupdatecheck *mmv1alpha1.DependencyUpdateCheck,
+	componentRef mmv1alpha1.ComponentReference,
+	registrySecret *corev1.Secret,
+	labels map[string]string,
+) (bool, error) {
+	log := ctrllog.FromContext(ctx)
+
+	appstudioComponent := &appstudiov1alpha1.Component{}
+	componentKey := types.NamespacedName{Namespace: componentRef.Namespace, Name: componentRef.Name}
+	if err := r.Client.Get(ctx, componentKey, appstudioComponent); err != nil {
+		if errors.IsNotFound(err) {
+			log.Info(fmt.Sprintf("component %v not found, skipping it", componentKey))
+			return false, nil
+		}
+		return false, err
+	}
+	comp, err := component.NewGitComponent(appstudioComponent, r.Client, ctx)
+	if err != nil {
+		return false, err
+	}
+
+	log.Info(fmt.Sprintf("creating pending PipelineRun for %s", comp.GetRepository()))
+	plrName := pipelineRunNameOf(dependencyupdatecheck, componentRef)
+	pipelinerun, err := creator.createPipelineRun(plrName, comp, ctx, registrySecret, labels, nil)
+	if errors.IsAlreadyExists(err) {
+		// The resources of a failed attempt are deleted, so the PipelineRun
+		// has to be checked
+		if getErr := r.Client.Get(ctx, types.NamespacedName{Namespace: MintMakerNamespaceName, Name: plrName}, &tektonv1.PipelineRun{}); getErr == nil {
+			log.Info(fmt.Sprintf("PipelineRun %s was already created", plrName))
+			return false, nil
+		}
+	}
+	if err != nil {
+		return false, err
+	}
+	log.Info(fmt.Sprintf("created PipelineRun %s", pipelinerun.Name))
+	return true, nil
+}
+
+// pipelineRunNameOf returns the name of the PipelineRun of a component of a
+// DependencyUpdateCheck, from the creation time of the DependencyUpdateCheck
+// and a hash identifying the component within it
+func pipelineRunNameOf(dependencyupdatecheck *mmv1alpha1.DependencyUpdateCheck, componentRef mmv1alpha1.ComponentReference) string {
+	timestamp := dependencyupdatecheck.CreationTimestamp.UTC().Format("01021504") // MMDDhhmm, from Go's time formatting reference date "20060102150405"
+	hash := sha256.Sum256([]byte(fmt.Sprintf("%s/%s/%s", dependencyupdatecheck.UID, componentRef.Namespace, componentRef.Name)))
+	return fmt.Sprintf("renovate-%s-%s", timestamp, hex.EncodeToString(hash[:])[:8])
+}
+
 // SetupWithManager sets up the controller with the Manager.
 func (r *DependencyUpdateCheckReconciler) SetupWithManager(mgr ctrl.Manager) error {
 	// we are monitoring the creation of DependencyUpdateCheck
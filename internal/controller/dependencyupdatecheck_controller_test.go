@@ -15,13 +15,18 @@
 package controller
 
 import (
+	"time"
+
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
 
 	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
 
+	mmv1alpha1 "github.com/konflux-ci/mintmaker/api/v1alpha1"
 	ghcomponent "github.com/konflux-ci/mintmaker/internal/pkg/component/github"
+	"github.com/konflux-ci/mintmaker/internal/pkg/config"
 	. "github.com/konflux-ci/mintmaker/internal/pkg/constant"
 )
 
@@ -30,6 +35,7 @@ var _ = Describe("DependencyUpdateCheck Controller", func() {
 	var (
 		origGetRenovateConfig func(registrySecret *corev1.Secret) (string, error)
 		origGetTokenFn        func() (string, error)
+		origGetInstallationID func() (int64, error)
 	)
 
 	Context("Test Renovate jobs creation", func() {
@@ -60,6 +66,11 @@ var _ = Describe("DependencyUpdateCheck Controller", func() {
 				return "tokenstring", nil
 			}
 
+			origGetInstallationID = ghcomponent.GetInstallationIDFn
+			ghcomponent.GetInstallationIDFn = func() (int64, error) {
+				return 12345, nil
+			}
+
 			Expect(listPipelineRuns(MintMakerNamespaceName)).Should(HaveLen(0))
 		})
 
@@ -70,6 +81,7 @@ var _ = Describe("DependencyUpdateCheck Controller", func() {
 			deleteConfigMap(types.NamespacedName{Namespace: MintMakerNamespaceName, Name: "renovate-config"})
 			ghcomponent.GetRenovateConfigFn = origGetRenovateConfig
 			ghcomponent.GetTokenFn = origGetTokenFn
+			ghcomponent.GetInstallationIDFn = origGetInstallationID
 		})
 
 		It("should create a pipeline run when a CR DependencyUpdateCheck is created", func() {
@@ -79,6 +91,115 @@ var _ = Describe("DependencyUpdateCheck Controller", func() {
 			deleteDependencyUpdateCheck(dependencyUpdateCheckKey)
 		})
 
+		It("should propagate the DependencyUpdateCheck priority to the pipeline run", func() {
+			dependencyUpdateCheckKey := types.NamespacedName{Namespace: MintMakerNamespaceName, Name: "dependencyupdatecheck-priority"}
+			dependencyUpdateCheck := &mmv1alpha1.DependencyUpdateCheck{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      dependencyUpdateCheckKey.Name,
+					Namespace: dependencyUpdateCheckKey.Namespace,
+				},
+				Spec: mmv1alpha1.DependencyUpdateCheckSpec{Priority: 100},
+			}
+			Expect(k8sClient.Create(ctx, dependencyUpdateCheck)).Should(Succeed())
+
+			Eventually(listPipelineRuns).WithArguments(MintMakerNamespaceName).Should(HaveLen(1))
+			pipelineRun := listPipelineRuns(MintMakerNamespaceName)[0]
+			Expect(pipelineRun.Labels).To(HaveKeyWithValue(MintMakerPriorityLabel, "100"))
+			deleteDependencyUpdateCheck(dependencyUpdateCheckKey)
+		})
+
+		It("should label the pipeline run with the git host and GitHub App installation", func() {
+			dependencyUpdateCheckKey := types.NamespacedName{Namespace: MintMakerNamespaceName, Name: "dependencyupdatecheck-sample"}
+			createDependencyUpdateCheck(dependencyUpdateCheckKey, false, nil)
+
+			Eventually(listPipelineRuns).WithArguments(MintMakerNamespaceName).Should(HaveLen(1))
+			pipelineRun := listPipelineRuns(MintMakerNamespaceName)[0]
+			Expect(pipelineRun.Labels).To(HaveKeyWithValue(MintMakerGitHostLabel, "github.com"))
+			Expect(pipelineRun.Labels).To(HaveKeyWithValue(MintMakerInstallationLabel, "12345"))
+			deleteDependencyUpdateCheck(dependencyUpdateCheckKey)
+		})
+
+		It("should track the created pipeline runs in the DependencyUpdateCheck status", func() {
+			dependencyUpdateCheckKey := types.NamespacedName{Namespace: MintMakerNamespaceName, Name: "dependencyupdatecheck-sample"}
+			createDependencyUpdateCheck(dependencyUpdateCheckKey, false, nil)
+
+			Eventually(listPipelineRuns).WithArguments(MintMakerNamespaceName).Should(HaveLen(1))
+			Eventually(func(g Gomega) {
+				dependencyUpdateCheck := getDependencyUpdateCheck(dependencyUpdateCheckKey)
+				g.Expect(dependencyUpdateCheck.Status.CreatedPipelineRuns).To(Equal(int32(1)))
+				g.Expect(dependencyUpdateCheck.Status.PendingComponents).To(BeEmpty())
+			}, timeout, interval).Should(Succeed())
+			deleteDependencyUpdateCheck(dependencyUpdateCheckKey)
+		})
+
+		It("should not create the pipeline run of a component twice", func() {
+			reconciler := NewDependencyUpdateCheckReconciler(k8sClient, k8sClient.Scheme(), config.GetConfig, nil)
+			creator := newPipelineRunCreator(k8sClient, k8sClient.Scheme(), &config.GetConfig().PipelineRunConfig)
+			dependencyUpdateCheck := &mmv1alpha1.DependencyUpdateCheck{
+				ObjectMeta: metav1.ObjectMeta{Name: "replayed", Namespace: MintMakerNamespaceName, UID: "1234"},
+			}
+			componentRef := mmv1alpha1.ComponentReference{Name: "testcomp", Namespace: "testnamespace"}
+
+			created, err := reconciler.createPipelineRun(ctx, creator, dependencyUpdateCheck, componentRef, nil, nil)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(created).To(BeTrue())
+
+			// A reconciliation replayed after a failed status update
+			created, err = reconciler.createPipelineRun(ctx, creator, dependencyUpdateCheck, componentRef, nil, nil)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(created).To(BeFalse())
+			Expect(listPipelineRuns(MintMakerNamespaceName)).To(HaveLen(1))
+		})
+
+		It("should skip components which no longer exist", func() {
+			reconciler := NewDependencyUpdateCheckReconciler(k8sClient, k8sClient.Scheme(), config.GetConfig, nil)
+			creator := newPipelineRunCreator(k8sClient, k8sClient.Scheme(), &config.GetConfig().PipelineRunConfig)
+			dependencyUpdateCheck := &mmv1alpha1.DependencyUpdateCheck{
+				ObjectMeta: metav1.ObjectMeta{Name: "deleted-component", Namespace: MintMakerNamespaceName, UID: "1234"},
+			}
+
+			created, err := reconciler.createPipelineRun(ctx, creator, dependencyUpdateCheck,
+				mmv1alpha1.ComponentReference{Name: "deleted", Namespace: "testnamespace"}, nil, nil)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(created).To(BeFalse())
+			Expect(listPipelineRuns(MintMakerNamespaceName)).To(BeEmpty())
+		})
+
+		It("should give up on a component after the maximum number of attempts", func() {
+			componentKey := types.NamespacedName{Name: "unsupported", Namespace: "testnamespace"}
+			createComponent(componentKey, "app", "https://bitbucket.org/unsupported.git", "gitrevision", "gitsourcecontext")
+			defer deleteComponent(componentKey)
+
+			// Outside of the mintmaker namespace, the DependencyUpdateCheck
+			// is only handled by the calls below
+			dependencyUpdateCheckKey := types.NamespacedName{Namespace: "testnamespace", Name: "dependencyupdatecheck-failing"}
+			createDependencyUpdateCheck(dependencyUpdateCheckKey, true, nil)
+			defer deleteDependencyUpdateCheck(dependencyUpdateCheckKey)
+			dependencyUpdateCheck := getDependencyUpdateCheck(dependencyUpdateCheckKey)
+			dependencyUpdateCheck.Status.PendingComponents = []mmv1alpha1.ComponentReference{{Name: "unsupported", Namespace: "testnamespace"}}
+			Expect(k8sClient.Status().Update(ctx, dependencyUpdateCheck)).Should(Succeed())
+
+			reconciler := NewDependencyUpdateCheckReconciler(k8sClient, k8sClient.Scheme(), config.GetConfig, nil)
+			maxAttempts := config.GetConfig().PipelineRunConfig.RetryPolicy.MaxAttempts
+			for i := 1; i < maxAttempts; i++ {
+				result, err := reconciler.createPipelineRuns(ctx, dependencyUpdateCheck)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(result.RequeueAfter).NotTo(BeZero())
+				Expect(dependencyUpdateCheck.Status.PendingComponents).To(HaveLen(1))
+				Expect(dependencyUpdateCheck.Status.PendingComponents[0].Attempts).To(Equal(int32(i)))
+			}
+
+			result, err := reconciler.createPipelineRuns(ctx, dependencyUpdateCheck)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result.RequeueAfter).To(BeZero())
+			dependencyUpdateCheck = getDependencyUpdateCheck(dependencyUpdateCheckKey)
+			Expect(dependencyUpdateCheck.Status.PendingComponents).To(BeEmpty())
+			Expect(dependencyUpdateCheck.Status.FailedComponents).To(ConsistOf(
+				mmv1alpha1.ComponentReference{Name: "unsupported", Namespace: "testnamespace", Attempts: int32(maxAttempts)},
+			))
+			Expect(listPipelineRuns(MintMakerNamespaceName)).To(BeEmpty())
+		})
+
 		It("should not create a pipelinerun for DependencyUpdateCheck CR which has been processed before", func() {
 			// Create a DependencyUpdateCheck CR in "mintmaker" namespace, that was processed before
 			dependencyUpdateCheckKey := types.NamespacedName{Namespace: MintMakerNamespaceName, Name: "dependencyupdatecheck-sample"}
@@ -95,4 +216,21 @@ var _ = Describe("DependencyUpdateCheck Controller", func() {
 			deleteDependencyUpdateCheck(dependencyUpdateCheckKey)
 		})
 	})
+
+	It("should name the pipeline runs of a DependencyUpdateCheck deterministically", func() {
+		dependencyUpdateCheck := &mmv1alpha1.DependencyUpdateCheck{
+			ObjectMeta: metav1.ObjectMeta{
+				UID:               "1234",
+				CreationTimestamp: metav1.NewTime(time.Date(2024, 3, 7, 9, 30, 0, 0, time.UTC)),
+			},
+		}
+		componentRef := mmv1alpha1.ComponentReference{Name: "testcomp", Namespace: "testnamespace"}
+
+		name := pipelineRunNameOf(dependencyUpdateCheck, componentRef)
+		Expect(name).To(MatchRegexp(`^renovate-03070930-[0-9a-f]{8}$`))
+		Expect(pipelineRunNameOf(dependencyUpdateCheck, componentRef)).To(Equal(name))
+		Expect(pipelineRunNameOf(dependencyUpdateCheck, mmv1alpha1.ComponentReference{Name: "other", Namespace: "testnamespace"})).NotTo(Equal(name))
+		dependencyUpdateCheck.UID = "5678"
+		Expect(pipelineRunNameOf(dependencyUpdateCheck, componentRef)).NotTo(Equal(name))
+	})
 })
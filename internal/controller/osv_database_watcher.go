@@ -0,0 +1,267 @@
+// Copyright 2024 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	ctrllog "sigs.k8s.io/controller-runtime/pkg/log"
+
+	appstudiov1alpha1 "github.com/konflux-ci/application-api/api/v1alpha1"
+
+	mmv1alpha1 "github.com/konflux-ci/mintmaker/api/v1alpha1"
+	"github.com/konflux-ci/mintmaker/internal/pkg/config"
+	. "github.com/konflux-ci/mintmaker/internal/pkg/constant"
+	"github.com/konflux-ci/mintmaker/internal/pkg/osv"
+	"github.com/konflux-ci/mintmaker/internal/pkg/registry"
+)
+
+// disabledFastLaneInterval is how often the config is read again while the
+// vulnerability fast lane is disabled
+const disabledFastLaneInterval = 5 * time.Minute
+
+// OSVDatabaseWatcher runs the vulnerability fast lane: it periodically checks
+// the digest of the OSV database image, and when a new database is pushed it
+// creates a high-priority DependencyUpdateCheck for the components affected by
+// the new advisories.
+type OSVDatabaseWatcher struct {
+	Client    client.Client
+	GetConfig config.Getter
+	// HTTPClient is used to get the digest and the advisories of the image
+	// from its registry
+	HTTPClient *http.Client
+
+	// The watched image, its digest and its advisories when it was last
+	// checked. The first database seen after the controller starts is only
+	// recorded, as it's not known which advisories it adds.
+	image      string
+	digest     string
+	advisories osv.Advisories
+}
+
+// SetupWithManager adds the watcher to the Manager, it only runs on the leader.
+func (w *OSVDatabaseWatcher) SetupWithManager(mgr ctrl.Manager) error {
+	return mgr.Add(w)
+}
+
+// Start watches the OSV database image until the context is cancelled
+func (w *OSVDatabaseWatcher) Start(ctx context.Context) error {
+	log := ctrllog.FromContext(ctx).WithName("OSVDatabaseWatcher")
+	ctx = ctrllog.IntoContext(ctx, log)
+
+	for {
+		plrConfig := w.GetConfig().PipelineRunConfig
+		fastLane := plrConfig.VulnerabilityFastLane
+		interval := fastLane.Interval
+		if interval == 0 {
+			// A digest recorded before the fast lane was disabled is outdated
+			w.image, w.digest, w.advisories = "", "", nil
+			interval = disabledFastLaneInterval
+		} else if err := w.check(ctx, &fastLane, plrConfig.Renovate.OSVDatabaseImage); err != nil {
+			log.Error(err, "failed to check the OSV database image")
+		}
+		// The interval is read again after each check, so it can be reloaded
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(interval):
+		}
+	}
+}
+
+// +kubebuilder:rbac:groups=appstudio.redhat.com,resources=dependencyupdatechecks,verbs=create
+// +kubebuilder:rbac:groups=appstudio.redhat.com,resources=dependencyupdatereports,verbs=list
+// +kubebuilder:rbac:groups=appstudio.redhat.com,resources=components,verbs=get
+
+// check creates a DependencyUpdateCheck for the components affected by the
+// advisories added to the database when the digest of the image changed. The
+// new database is only recorded once the check is created, so a failure is
+// retried with the next check.
+func (w *OSVDatabaseWatcher) check(ctx context.Context, fastLane *config.VulnerabilityFastLane, image string) error {
+	log := ctrllog.FromContext(ctx)
+
+	digest, err := registry.Digest(ctx, w.HTTPClient, image)
+	if err != nil {
+		return fmt.Errorf("failed to get the digest of %s: %w", image, err)
+	}
+	if image == w.image && digest == w.digest {
+		return nil
+	}
+	ref, err := registry.ParseImage(image)
+	if err != nil {
+		return err
+	}
+	// The database is read by digest, the tag may already point to a newer one
+	advisories, err := osv.LoadAdvisories(ctx, w.HTTPClient, ref.Pinned(digest))
+	if err != nil {
+		return fmt.Errorf("failed to read the advisories of %s: %w", image, err)
+	}
+	if image != w.image {
+		log.Info("watching the OSV database image", "image", image, "digest", digest, "advisories", len(advisories))
+		w.image, w.digest, w.advisories = image, digest, advisories
+		return nil
+	}
+
+	added := addedAdvisories(advisories, w.advisories, fastLane.Registries)
+	log.Info("the OSV database image was updated", "image", image, "digest", digest, "newAdvisories", len(added))
+	components, err := w.affectedComponents(ctx, added)
+	if err != nil {
+		return err
+	}
+	if len(components) == 0 {
+		log.Info("no component is affected by the updated OSV database")
+		w.digest, w.advisories = digest, advisories
+		return nil
+	}
+
+	dependencyupdatecheck := newVulnerabilityFixCheck(components, int32(fastLane.Priority), digest)
+	if err := w.Client.Create(ctx, dependencyupdatecheck); err != nil {
+		return fmt.Errorf("failed to create the DependencyUpdateCheck of the vulnerability fast lane: %w", err)
+	}
+	log.Info(fmt.Sprintf("created DependencyUpdateCheck %s for %d components affected by the updated OSV database",
+		dependencyupdatecheck.Name, len(components)))
+	w.digest, w.advisories = digest, advisories
+	return nil
+}
+
+// addedAdvisories returns the advisories of the database which aren't in the
+// previous one, with only the images from the given registries
+func addedAdvisories(advisories, previous osv.Advisories, registries []string) []*osv.Advisory {
+	added := []*osv.Advisory{}
+	for _, advisory := range advisories.Added(previous) {
+		covered := &osv.Advisory{ID: advisory.ID}
+		for _, affected := range advisory.Affected {
+			for _, registry := range registries {
+				if strings.HasPrefix(affected.Package.Name, registry+"/") {
+					covered.Affected = append(covered.Affected, affected)
+					break
+				}
+			}
+		}
+		if len(covered.Affected) > 0 {
+			added = append(added, covered)
+		}
+	}
+	return added
+}
+
+// affectedComponents returns the components whose DependencyUpdateReport has
+// a pending update of an image covered by the advisories, which isn't known
+// to fix a vulnerability yet. Images without a newer version can't be
+// remediated anyway. A report listing only the first updates is matched on
+// these updates only, as selecting every such report would check most of
+// the large repositories for any new advisory.
+func (w *OSVDatabaseWatcher) affectedComponents(ctx context.Context, advisories []*osv.Advisory) ([]appstudiov1alpha1.Component, error) {
+	log := ctrllog.FromContext(ctx)
+
+	components := []appstudiov1alpha1.Component{}
+	if len(advisories) == 0 {
+		return components, nil
+	}
+	var reportList mmv1alpha1.DependencyUpdateReportList
+	if err := w.Client.List(ctx, &reportList); err != nil {
+		return nil, fmt.Errorf("failed to list DependencyUpdateReports: %w", err)
+	}
+
+	for _, report := range reportList.Items {
+		if !isAffected(&report.Status, advisories) {
+			continue
+		}
+		component := appstudiov1alpha1.Component{}
+		key := types.NamespacedName{Namespace: report.Namespace, Name: report.Status.Component}
+		if err := w.Client.Get(ctx, key, &component); err != nil {
+			if apierrors.IsNotFound(err) {
+				log.Info(fmt.Sprintf("component %v of DependencyUpdateReport %s not found", key, report.Name))
+				continue
+			}
+			return nil, err
+		}
+		components = append(components, component)
+	}
+	return components, nil
+}
+
+// isAffected checks if a report has a pending update of an image covered by
+// the advisories at its current version, which doesn't fix a known
+// vulnerability. Only the listed updates are checked.
+func isAffected(report *mmv1alpha1.DependencyUpdateReportStatus, advisories []*osv.Advisory) bool {
+	for _, update := range report.Updates {
+		if update.Vulnerable {
+			continue
+		}
+		for _, advisory := range advisories {
+			if advisory.Affects(update.Name, update.CurrentVersion) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// newVulnerabilityFixCheck returns a DependencyUpdateCheck targeting only the
+// given components, annotated with the digest of the OSV database which
+// triggered it
+func newVulnerabilityFixCheck(components []appstudiov1alpha1.Component, priority int32, digest string) *mmv1alpha1.DependencyUpdateCheck {
+	// Components are selected by namespace, application and component name
+	selected := map[string]map[string][]mmv1alpha1.Component{}
+	for _, component := range components {
+		applications, ok := selected[component.Namespace]
+		if !ok {
+			applications = map[string][]mmv1alpha1.Component{}
+			selected[component.Namespace] = applications
+		}
+		applications[component.Spec.Application] = append(applications[component.Spec.Application],
+			mmv1alpha1.Component(component.Spec.ComponentName))
+	}
+
+	namespaces := []mmv1alpha1.NamespaceSpec{}
+	for namespace, applications := range selected {
+		namespaceSpec := mmv1alpha1.NamespaceSpec{Namespace: namespace}
+		for application, names := range applications {
+			sort.Slice(names, func(i, j int) bool { return names[i] < names[j] })
+			namespaceSpec.Applications = append(namespaceSpec.Applications, mmv1alpha1.ApplicationSpec{
+				Application: application,
+				Components:  names,
+			})
+		}
+		sort.Slice(namespaceSpec.Applications, func(i, j int) bool {
+			return namespaceSpec.Applications[i].Application < namespaceSpec.Applications[j].Application
+		})
+		namespaces = append(namespaces, namespaceSpec)
+	}
+	sort.Slice(namespaces, func(i, j int) bool { return namespaces[i].Namespace < namespaces[j].Namespace })
+
+	return &mmv1alpha1.DependencyUpdateCheck{
+		ObjectMeta: metav1.ObjectMeta{
+			GenerateName: "vulnerability-fix-",
+			Namespace:    MintMakerNamespaceName,
+			Annotations:  map[string]string{MintMakerOSVDatabaseDigestAnnotation: digest},
+		},
+		Spec: mmv1alpha1.DependencyUpdateCheckSpec{
+			Namespaces: namespaces,
+			Priority:   priority,
+		},
+	}
+}
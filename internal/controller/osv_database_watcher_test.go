@@ -0,0 +1,275 @@
+// Copyright 2024 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controller
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	appstudiov1alpha1 "github.com/konflux-ci/application-api/api/v1alpha1"
+
+	mmv1alpha1 "github.com/konflux-ci/mintmaker/api/v1alpha1"
+	"github.com/konflux-ci/mintmaker/internal/pkg/config"
+	. "github.com/konflux-ci/mintmaker/internal/pkg/constant"
+	"github.com/konflux-ci/mintmaker/internal/pkg/osv"
+)
+
+// osvDatabaseLayer returns an image layer holding the container advisories
+// of an OSV database, one JSON document per line
+func osvDatabaseLayer(advisories ...string) []byte {
+	content := strings.Join(advisories, "\n")
+	var layer bytes.Buffer
+	gzipWriter := gzip.NewWriter(&layer)
+	archive := tar.NewWriter(gzipWriter)
+	Expect(archive.WriteHeader(&tar.Header{
+		Name: strings.TrimPrefix(osv.ContainerDatabaseFile, "/"), Mode: 0o644, Size: int64(len(content)), Typeflag: tar.TypeReg,
+	})).To(Succeed())
+	_, err := archive.Write([]byte(content))
+	Expect(err).NotTo(HaveOccurred())
+	Expect(archive.Close()).To(Succeed())
+	Expect(gzipWriter.Close()).To(Succeed())
+	return layer.Bytes()
+}
+
+// osvAdvisory returns an advisory of the OSV database fixed by the given image and tag
+func osvAdvisory(id, image, tag string) string {
+	return fmt.Sprintf(`{"id": %q, "affected": [{"package": {"ecosystem": "Docker", "name": %q, "purl": "pkg:oci/image?repository_url=%s&tag=%s"}}]}`,
+		id, image, image, tag)
+}
+
+var _ = Describe("OSV Database Watcher", func() {
+
+	affectedKey := types.NamespacedName{Name: "osvcomp", Namespace: "testnamespace"}
+	unaffectedKey := types.NamespacedName{Name: "osvcomp-upstream", Namespace: "testnamespace"}
+
+	var (
+		server   *httptest.Server
+		digest   string
+		image    string
+		fastLane config.VulnerabilityFastLane
+		// The layers of the database by digest
+		databases map[string][]byte
+	)
+
+	createReport := func(componentKey types.NamespacedName, updates ...mmv1alpha1.DependencyUpdate) {
+		report := &mmv1alpha1.DependencyUpdateReport{
+			ObjectMeta: metav1.ObjectMeta{Name: componentKey.Name, Namespace: componentKey.Namespace},
+		}
+		Expect(k8sClient.Create(ctx, report)).To(Succeed())
+		report.Status = mmv1alpha1.DependencyUpdateReportStatus{
+			Component:       componentKey.Name,
+			PipelineRun:     "renovate-" + componentKey.Name,
+			UpdateTimestamp: metav1.Now(),
+			TotalUpdates:    int32(len(updates)),
+			Updates:         updates,
+		}
+		Expect(k8sClient.Status().Update(ctx, report)).To(Succeed())
+	}
+
+	listVulnerabilityFixChecks := func() []mmv1alpha1.DependencyUpdateCheck {
+		checks := &mmv1alpha1.DependencyUpdateCheckList{}
+		Expect(k8sClient.List(ctx, checks, client.InNamespace(MintMakerNamespaceName))).To(Succeed())
+		result := []mmv1alpha1.DependencyUpdateCheck{}
+		for _, check := range checks.Items {
+			if _, ok := check.Annotations[MintMakerOSVDatabaseDigestAnnotation]; ok {
+				result = append(result, check)
+			}
+		}
+		return result
+	}
+
+	_ = BeforeEach(func() {
+		createNamespace(MintMakerNamespaceName)
+		createNamespace(affectedKey.Namespace)
+		createComponent(affectedKey, "app", "https://github.com/osvcomp.git", "gitrevision", "gitsourcecontext")
+		createComponent(unaffectedKey, "app", "https://github.com/osvcomp-upstream.git", "gitrevision", "gitsourcecontext")
+		createReport(affectedKey, mmv1alpha1.DependencyUpdate{
+			Manager: "dockerfile", Name: "registry.access.redhat.com/ubi9/ubi-minimal",
+			CurrentVersion: "9.4", NewVersion: "9.5", UpdateType: "minor",
+		})
+		createReport(unaffectedKey, mmv1alpha1.DependencyUpdate{
+			Manager: "dockerfile", Name: "docker.io/library/alpine",
+			CurrentVersion: "3.19", NewVersion: "3.20", UpdateType: "minor",
+		}, mmv1alpha1.DependencyUpdate{
+			Manager: "dockerfile", Name: "registry.access.redhat.com/ubi8",
+			CurrentVersion: "8.9", NewVersion: "8.10", UpdateType: "minor", Vulnerable: true,
+		})
+
+		digest = "sha256:1"
+		databases = map[string][]byte{
+			"sha256:1": osvDatabaseLayer(osvAdvisory("CVE-2024-1", "registry.access.redhat.com/ubi8", "8.10")),
+			"sha256:2": osvDatabaseLayer(
+				osvAdvisory("CVE-2024-1", "registry.access.redhat.com/ubi8", "8.10"),
+				osvAdvisory("CVE-2024-2", "registry.access.redhat.com/ubi9/ubi-minimal", "9.5"),
+			),
+			"sha256:3": osvDatabaseLayer(
+				osvAdvisory("CVE-2024-1", "registry.access.redhat.com/ubi8", "8.10"),
+				osvAdvisory("CVE-2024-3", "registry.access.redhat.com/ubi9/nodejs-20", "1-59"),
+			),
+		}
+		server = httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			path := strings.TrimPrefix(r.URL.Path, "/v2/konflux-ci/mintmaker-osv-database/")
+			switch {
+			case path == "manifests/latest":
+				w.Header().Set("Docker-Content-Digest", digest)
+			case strings.HasPrefix(path, "manifests/"):
+				fmt.Fprintf(w, `{"mediaType": "application/vnd.oci.image.manifest.v1+json", "layers": [{"digest": "layer-%s"}]}`,
+					strings.TrimPrefix(path, "manifests/"))
+			case databases[strings.TrimPrefix(path, "blobs/layer-")] != nil:
+				_, _ = w.Write(databases[strings.TrimPrefix(path, "blobs/layer-")])
+			default:
+				w.WriteHeader(http.StatusNotFound)
+			}
+		}))
+		image = strings.TrimPrefix(server.URL, "https://") + "/konflux-ci/mintmaker-osv-database:latest"
+		fastLane = config.DefaultConfig().PipelineRunConfig.VulnerabilityFastLane
+	})
+
+	_ = AfterEach(func() {
+		server.Close()
+		for _, check := range listVulnerabilityFixChecks() {
+			deleteDependencyUpdateCheck(types.NamespacedName{Name: check.Name, Namespace: check.Namespace})
+		}
+		for _, key := range []types.NamespacedName{affectedKey, unaffectedKey} {
+			report := &mmv1alpha1.DependencyUpdateReport{}
+			if err := k8sClient.Get(ctx, key, report); err == nil {
+				Expect(k8sClient.Delete(ctx, report)).To(Succeed())
+			}
+			deleteComponent(key)
+		}
+	})
+
+	It("should create a check for the affected components when the OSV database is updated", func() {
+		watcher := &OSVDatabaseWatcher{Client: k8sClient, HTTPClient: server.Client()}
+
+		// The first digest is the baseline
+		Expect(watcher.check(ctx, &fastLane, image)).To(Succeed())
+		Expect(listVulnerabilityFixChecks()).To(BeEmpty())
+		Expect(watcher.check(ctx, &fastLane, image)).To(Succeed())
+		Expect(listVulnerabilityFixChecks()).To(BeEmpty())
+
+		digest = "sha256:2"
+		Expect(watcher.check(ctx, &fastLane, image)).To(Succeed())
+		checks := listVulnerabilityFixChecks()
+		Expect(checks).To(HaveLen(1))
+		Expect(checks[0].Annotations).To(HaveKeyWithValue(MintMakerOSVDatabaseDigestAnnotation, "sha256:2"))
+		Expect(checks[0].Spec.Priority).To(BeEquivalentTo(fastLane.Priority))
+		Expect(checks[0].Spec.Namespaces).To(Equal([]mmv1alpha1.NamespaceSpec{{
+			Namespace: affectedKey.Namespace,
+			Applications: []mmv1alpha1.ApplicationSpec{{
+				Application: "app",
+				Components:  []mmv1alpha1.Component{mmv1alpha1.Component(affectedKey.Name)},
+			}},
+		}}))
+
+		// The same database doesn't trigger another check
+		Expect(watcher.check(ctx, &fastLane, image)).To(Succeed())
+		Expect(listVulnerabilityFixChecks()).To(HaveLen(1))
+	})
+
+	It("should not create a check when no component is affected", func() {
+		watcher := &OSVDatabaseWatcher{Client: k8sClient, HTTPClient: server.Client()}
+		Expect(watcher.check(ctx, &fastLane, image)).To(Succeed())
+
+		// The new advisory covers an image no component uses
+		digest = "sha256:3"
+		Expect(watcher.check(ctx, &fastLane, image)).To(Succeed())
+		Expect(listVulnerabilityFixChecks()).To(BeEmpty())
+		Expect(watcher.digest).To(Equal("sha256:3"))
+	})
+
+	It("should not create a check for advisories of images from other registries", func() {
+		fastLane.Registries = []string{"quay.io"}
+		watcher := &OSVDatabaseWatcher{Client: k8sClient, HTTPClient: server.Client()}
+		Expect(watcher.check(ctx, &fastLane, image)).To(Succeed())
+
+		digest = "sha256:2"
+		Expect(watcher.check(ctx, &fastLane, image)).To(Succeed())
+		Expect(listVulnerabilityFixChecks()).To(BeEmpty())
+	})
+
+	It("should retry a database which can't be read", func() {
+		watcher := &OSVDatabaseWatcher{Client: k8sClient, HTTPClient: server.Client()}
+		Expect(watcher.check(ctx, &fastLane, image)).To(Succeed())
+
+		digest = "sha256:missing"
+		Expect(watcher.check(ctx, &fastLane, image)).NotTo(Succeed())
+		Expect(watcher.digest).To(Equal("sha256:1"))
+	})
+
+	DescribeTable("should select the reports with a pending update of an affected image",
+		func(report mmv1alpha1.DependencyUpdateReportStatus, expected bool) {
+			advisories := []*osv.Advisory{{ID: "CVE-2024-2", Affected: []osv.Affected{{Package: osv.Package{
+				Name: "registry.access.redhat.com/ubi9/ubi-minimal",
+				Purl: "pkg:oci/ubi-minimal?repository_url=registry.access.redhat.com/ubi9/ubi-minimal&tag=9.5",
+			}}}}}
+			Expect(isAffected(&report, advisories)).To(Equal(expected))
+		},
+		Entry("with an update of the image", mmv1alpha1.DependencyUpdateReportStatus{TotalUpdates: 1, Updates: []mmv1alpha1.DependencyUpdate{
+			{Name: "registry.access.redhat.com/ubi9/ubi-minimal", CurrentVersion: "9.4", NewVersion: "9.5"},
+		}}, true),
+		Entry("with the image at the fixed version", mmv1alpha1.DependencyUpdateReportStatus{TotalUpdates: 1, Updates: []mmv1alpha1.DependencyUpdate{
+			{Name: "registry.access.redhat.com/ubi9/ubi-minimal", CurrentVersion: "9.5", NewVersion: "9.6"},
+		}}, false),
+		Entry("with an update already fixing a vulnerability", mmv1alpha1.DependencyUpdateReportStatus{TotalUpdates: 1, Updates: []mmv1alpha1.DependencyUpdate{
+			{Name: "registry.access.redhat.com/ubi9/ubi-minimal", CurrentVersion: "9.4", NewVersion: "9.5", Vulnerable: true},
+		}}, false),
+		Entry("with updates of other images", mmv1alpha1.DependencyUpdateReportStatus{TotalUpdates: 1, Updates: []mmv1alpha1.DependencyUpdate{
+			{Name: "registry.access.redhat.com/ubi9", CurrentVersion: "9.4", NewVersion: "9.5"},
+		}}, false),
+		Entry("with updates which aren't listed", mmv1alpha1.DependencyUpdateReportStatus{TotalUpdates: 2, Updates: []mmv1alpha1.DependencyUpdate{
+			{Name: "registry.access.redhat.com/ubi9", CurrentVersion: "9.4", NewVersion: "9.5"},
+		}}, false),
+		Entry("with a listed update of the image and updates which aren't listed", mmv1alpha1.DependencyUpdateReportStatus{TotalUpdates: 2, Updates: []mmv1alpha1.DependencyUpdate{
+			{Name: "registry.access.redhat.com/ubi9/ubi-minimal", CurrentVersion: "9.4", NewVersion: "9.5"},
+		}}, true),
+	)
+
+	It("should group the components by namespace and application", func() {
+		newComponent := func(namespace, application, name string) appstudiov1alpha1.Component {
+			return appstudiov1alpha1.Component{
+				ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace},
+				Spec:       appstudiov1alpha1.ComponentSpec{ComponentName: name, Application: application},
+			}
+		}
+		check := newVulnerabilityFixCheck([]appstudiov1alpha1.Component{
+			newComponent("ns2", "app", "c"),
+			newComponent("ns1", "app2", "b"),
+			newComponent("ns1", "app1", "a"),
+			newComponent("ns1", "app2", "a"),
+		}, 900, "sha256:1")
+		Expect(check.Namespace).To(Equal(MintMakerNamespaceName))
+		Expect(check.Spec.Namespaces).To(Equal([]mmv1alpha1.NamespaceSpec{
+			{Namespace: "ns1", Applications: []mmv1alpha1.ApplicationSpec{
+				{Application: "app1", Components: []mmv1alpha1.Component{"a"}},
+				{Application: "app2", Components: []mmv1alpha1.Component{"a", "b"}},
+			}},
+			{Namespace: "ns2", Applications: []mmv1alpha1.ApplicationSpec{
+				{Application: "app", Components: []mmv1alpha1.Component{"c"}},
+			}},
+		}))
+	})
+})
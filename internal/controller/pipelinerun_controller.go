@@ -17,35 +17,41 @@ package controller
 import (
 	"context"
 	"fmt"
-	"sort"
 	"time"
 
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
 	"knative.dev/pkg/apis"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
 	ctrllog "sigs.k8s.io/controller-runtime/pkg/log"
 	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 
 	tektonv1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
 
 	"github.com/konflux-ci/mintmaker/internal/pkg/config"
 	. "github.com/konflux-ci/mintmaker/internal/pkg/constant"
 	mintmakermetrics "github.com/konflux-ci/mintmaker/internal/pkg/metrics"
+	"github.com/konflux-ci/mintmaker/internal/pkg/scheduler"
 )
 
-var (
-	MintMakerGitPlatformLabel        = "mintmaker.appstudio.redhat.com/git-platform"
-	MintMakerComponentNameLabel      = "mintmaker.appstudio.redhat.com/component"
-	MintMakerComponentNamespaceLabel = "mintmaker.appstudio.redhat.com/namespace"
-)
+// schedulerRequest is the only request of the PipelineRun controller. All
+// PipelineRun events are mapped to it, so the work queue holds at most one
+// item and scheduling never runs concurrently, which could start more
+// PipelineRuns than allowed.
+var schedulerRequest = reconcile.Request{
+	NamespacedName: types.NamespacedName{Namespace: MintMakerNamespaceName, Name: "pipelinerun-scheduler"},
+}
 
 // PipelineRunReconciler reconciles a PipelineRun object
 type PipelineRunReconciler struct {
-	Client client.Client
-	Scheme *runtime.Scheme
-	Config *config.ControllerConfig
+	Client    client.Client
+	Scheme    *runtime.Scheme
+	GetConfig config.Getter
 }
 
 // updatePipelineRunState updates the status of a PipelineRun
@@ -62,6 +68,11 @@ func (r *PipelineRunReconciler) updatePipelineRunState(
 	// If pipelinerun is to be cancelled, add reason with the error message
 	if status == tektonv1.PipelineRunSpecStatusCancelled {
 		pipelineRun.Status.MarkFailed(string(tektonv1.PipelineRunReasonCancelled), "%s", errmsg)
+		// The status is owned by Tekton, keep the reason in an annotation too
+		if pipelineRun.Annotations == nil {
+			pipelineRun.Annotations = make(map[string]string)
+		}
+		pipelineRun.Annotations[MintMakerCancelReasonAnnotation] = errmsg
 	}
 
 	patch := client.MergeFrom(originalPipelineRun)
@@ -91,44 +102,38 @@ func (r *PipelineRunReconciler) Reconcile(ctx context.Context, req ctrl.Request)
 	log := ctrllog.FromContext(ctx).WithName("PipelineRunController")
 	ctx = ctrllog.IntoContext(ctx, log)
 
-	// Get all PipelineRuns in the namespace
-	var pipelineRunList tektonv1.PipelineRunList
-	if err := r.Client.List(ctx, &pipelineRunList, client.InNamespace(req.Namespace)); err != nil {
-		log.Error(err, "unable to list PipelineRuns")
+	// Get the running and pending PipelineRuns from the cache index, so the
+	// completed PipelineRuns aren't scanned
+	runningRuns, err := listPipelineRunsByState(ctx, r.Client, req.Namespace, pipelineRunStateRunning)
+	if err != nil {
+		log.Error(err, "unable to list running PipelineRuns")
+		return ctrl.Result{}, err
+	}
+	pendingRuns, err := listPipelineRunsByState(ctx, r.Client, req.Namespace, pipelineRunStatePending)
+	if err != nil {
+		log.Error(err, "unable to list pending PipelineRuns")
 		return ctrl.Result{}, err
 	}
 
-	// Count running PipelineRuns
-	runningCount := 0
-	var pendingRuns []tektonv1.PipelineRun
+	// The config is read once, so a reload doesn't change it midway
+	cfg := r.GetConfig()
 
-	for i := range pipelineRunList.Items {
-		run := pipelineRunList.Items[i]
+	// Free the slots of PipelineRuns which are stuck
+	runningRuns = r.cancelStuckPipelineRuns(ctx, runningRuns, cfg.PipelineRunConfig.StuckDeadline)
 
-		// Count running PipelineRuns - a running pipelinerun is one that is not pending and not done
-		if !run.IsPending() && !run.IsDone() {
-			runningCount++
-		}
-
-		// Collect pending PipelineRuns
-		if run.IsPending() {
-			pendingRuns = append(pendingRuns, run)
+	// Start the pending runs selected by the scheduler, up to the maximum allowed
+	pipelineRunScheduler := scheduler.NewScheduler(&cfg.PipelineRunConfig)
+	for _, window := range pipelineRunScheduler.BlackoutWindows() {
+		mintmakermetrics.SetBlackoutWindowActive(window.Name, window.Active)
+		if window.Active {
+			log.Info("blackout window is active", "window", window.Name, "until", window.Until.Format(time.RFC3339))
 		}
 	}
-
-	// Sort pending runs by creation timestamp (oldest first)
-	sort.Slice(pendingRuns, func(i, j int) bool {
-		return pendingRuns[i].CreationTimestamp.Before(&pendingRuns[j].CreationTimestamp)
-	})
-
-	// Calculate how many more runs we can start
-	availableSlots := r.Config.PipelineRunConfig.MaxParallelPipelineruns - runningCount
-
-	// Start as many pending runs as possible, up to the maximum allowed
-	if availableSlots > 0 {
+	runsToStart := pipelineRunScheduler.Schedule(runningRuns, pendingRuns)
+	if len(runsToStart) > 0 {
 		started := 0
-		for i := 0; i < len(pendingRuns) && started < availableSlots; i++ {
-			if r.startPipelineRun(ctx, pendingRuns[i]) {
+		for _, run := range runsToStart {
+			if r.startPipelineRun(ctx, run) {
 				started++
 				mintmakermetrics.CountScheduledRunSuccess()
 			} else {
@@ -138,54 +143,105 @@ func (r *PipelineRunReconciler) Reconcile(ctx context.Context, req ctrl.Request)
 		log.Info("started PipelineRuns", "count", started)
 	}
 
-	return ctrl.Result{}, nil
+	// Slots freed without an event, e.g. after a failed start, are refilled
+	// periodically. PipelineRuns held because of an exhausted API quota, a
+	// retry backoff or a blackout window are scheduled again once they can be
+	// started.
+	requeueAfter := cfg.PipelineRunConfig.SchedulerResyncPeriod
+	if len(pendingRuns) > len(runsToStart) {
+		if reset, ok := pipelineRunScheduler.NextRateLimitReset(pendingRuns); ok {
+			log.Info("holding PipelineRuns until API quota is reset", "reset", reset.Format(time.RFC3339))
+			requeueAfter = earliestRequeue(requeueAfter, time.Until(reset))
+		}
+		if notBefore, ok := pipelineRunScheduler.NextNotBefore(pendingRuns); ok {
+			requeueAfter = earliestRequeue(requeueAfter, time.Until(notBefore))
+		}
+		if end, ok := pipelineRunScheduler.NextBlackoutEnd(pendingRuns); ok {
+			requeueAfter = earliestRequeue(requeueAfter, time.Until(end))
+		}
+	}
+
+	return ctrl.Result{RequeueAfter: requeueAfter}, nil
 }
 
-// SetupWithManager sets up the controller with the Manager.
-func (r *PipelineRunReconciler) SetupWithManager(mgr ctrl.Manager) error {
-	return ctrl.NewControllerManagedBy(mgr).
-		For(&tektonv1.PipelineRun{}).
-		WithEventFilter(predicate.Funcs{
-			CreateFunc: func(e event.CreateEvent) bool {
-				if e.Object.GetNamespace() != MintMakerNamespaceName {
-					return false
-				}
-				if pipelineRun, ok := e.Object.(*tektonv1.PipelineRun); ok {
-					return pipelineRun.IsPending()
-				}
+// earliestRequeue returns the shorter of two requeue delays, 0 means no requeue
+func earliestRequeue(current, next time.Duration) time.Duration {
+	// The time may have passed already, but 0 would disable the requeue
+	next = max(next, time.Second)
+	if current <= 0 || next < current {
+		return next
+	}
+	return current
+}
+
+// mapToSchedulerRequest coalesces all the PipelineRun events into the
+// schedulerRequest
+func mapToSchedulerRequest(ctx context.Context, obj client.Object) []reconcile.Request {
+	return []reconcile.Request{schedulerRequest}
+}
+
+// schedulingPredicate filters the PipelineRun events which may allow starting
+// a pending PipelineRun: a PipelineRun is queued, or a slot is freed
+func schedulingPredicate() predicate.Funcs {
+	return predicate.Funcs{
+		CreateFunc: func(e event.CreateEvent) bool {
+			if e.Object.GetNamespace() != MintMakerNamespaceName {
+				return false
+			}
+			if pipelineRun, ok := e.Object.(*tektonv1.PipelineRun); ok {
+				return pipelineRun.IsPending()
+			}
+			return false
+		},
+		DeleteFunc: func(e event.DeleteEvent) bool {
+			if e.Object.GetNamespace() != MintMakerNamespaceName {
 				return false
-			},
-			DeleteFunc: func(e event.DeleteEvent) bool {
+			}
+			// A slot is freed when a running PipelineRun is deleted
+			if pipelineRun, ok := e.Object.(*tektonv1.PipelineRun); ok {
+				return !pipelineRun.IsPending() && !pipelineRun.IsDone()
+			}
+			return false
+		},
+		UpdateFunc: func(e event.UpdateEvent) bool {
+			if e.ObjectNew.GetNamespace() != MintMakerNamespaceName {
 				return false
-			},
-			UpdateFunc: func(e event.UpdateEvent) bool {
-				if e.ObjectNew.GetNamespace() != MintMakerNamespaceName {
-					return false
-				}
-				if oldPipelineRun, ok := e.ObjectOld.(*tektonv1.PipelineRun); ok {
-					if newPipelineRun, ok := e.ObjectNew.(*tektonv1.PipelineRun); ok {
-						if !oldPipelineRun.IsDone() && newPipelineRun.IsDone() {
-							if newPipelineRun.Status.CompletionTime != nil {
-								log := ctrl.Log.WithName("PipelineRunController")
-								log.Info(
-									fmt.Sprintf("PipelineRun finished: %s", newPipelineRun.Name),
-									"completionTime",
-									newPipelineRun.Status.CompletionTime.Format(time.RFC3339),
-									"success",
-									newPipelineRun.Status.GetCondition(apis.ConditionSucceeded).IsTrue(),
-									"reason",
-									newPipelineRun.Status.GetCondition(apis.ConditionSucceeded).GetReason(),
-								)
-							}
-							return true
+			}
+			if oldPipelineRun, ok := e.ObjectOld.(*tektonv1.PipelineRun); ok {
+				if newPipelineRun, ok := e.ObjectNew.(*tektonv1.PipelineRun); ok {
+					if !oldPipelineRun.IsDone() && newPipelineRun.IsDone() {
+						if newPipelineRun.Status.CompletionTime != nil {
+							log := ctrl.Log.WithName("PipelineRunController")
+							log.Info(
+								fmt.Sprintf("PipelineRun finished: %s", newPipelineRun.Name),
+								"completionTime",
+								newPipelineRun.Status.CompletionTime.Format(time.RFC3339),
+								"success",
+								newPipelineRun.Status.GetCondition(apis.ConditionSucceeded).IsTrue(),
+								"reason",
+								newPipelineRun.Status.GetCondition(apis.ConditionSucceeded).GetReason(),
+							)
 						}
+						return true
 					}
 				}
-				return false
-			},
-			GenericFunc: func(e event.GenericEvent) bool {
-				return false
-			},
-		}).
+			}
+			return false
+		},
+		GenericFunc: func(e event.GenericEvent) bool {
+			return false
+		},
+	}
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *PipelineRunReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		Named("pipelinerun").
+		Watches(
+			&tektonv1.PipelineRun{},
+			handler.EnqueueRequestsFromMapFunc(mapToSchedulerRequest),
+			builder.WithPredicates(schedulingPredicate()),
+		).
 		Complete(r)
 }
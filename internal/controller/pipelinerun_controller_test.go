@@ -24,6 +24,8 @@ import (
 	tektonv1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 
 	"github.com/konflux-ci/mintmaker/internal/pkg/config"
 	. "github.com/konflux-ci/mintmaker/internal/pkg/constant"
@@ -182,4 +184,87 @@ var _ = Describe("PipelineRun Controller", func() {
 			Expect(logBuffer.String()).To(ContainSubstring(expected, plr.Name, plr.Status.CompletionTime.Format(time.RFC3339)))
 		})
 	})
+
+	Context("When scheduling is triggered", func() {
+
+		newPipelineRun := func(namespace string, status tektonv1.PipelineRunSpecStatus, done bool) *tektonv1.PipelineRun {
+			pipelineRun := &tektonv1.PipelineRun{
+				ObjectMeta: metav1.ObjectMeta{Name: "plr", Namespace: namespace},
+				Spec:       tektonv1.PipelineRunSpec{Status: status},
+			}
+			if done {
+				pipelineRun.Status.MarkSucceeded(string(tektonv1.PipelineRunReasonSuccessful), "done")
+			}
+			return pipelineRun
+		}
+
+		It("should coalesce all the events into the scheduler request", func() {
+			for _, pipelineRun := range []*tektonv1.PipelineRun{
+				newPipelineRun(MintMakerNamespaceName, tektonv1.PipelineRunSpecStatusPending, false),
+				newPipelineRun(MintMakerNamespaceName, "", false),
+				newPipelineRun("testnamespace", "", true),
+			} {
+				Expect(mapToSchedulerRequest(ctx, pipelineRun)).To(Equal([]reconcile.Request{schedulerRequest}))
+			}
+		})
+
+		It("should schedule when a pending PipelineRun is created", func() {
+			Expect(schedulingPredicate().Create(event.CreateEvent{
+				Object: newPipelineRun(MintMakerNamespaceName, tektonv1.PipelineRunSpecStatusPending, false),
+			})).To(BeTrue())
+			Expect(schedulingPredicate().Create(event.CreateEvent{
+				Object: newPipelineRun(MintMakerNamespaceName, "", false),
+			})).To(BeFalse())
+			Expect(schedulingPredicate().Create(event.CreateEvent{
+				Object: newPipelineRun("testnamespace", tektonv1.PipelineRunSpecStatusPending, false),
+			})).To(BeFalse())
+		})
+
+		It("should schedule when a running PipelineRun is deleted", func() {
+			Expect(schedulingPredicate().Delete(event.DeleteEvent{
+				Object: newPipelineRun(MintMakerNamespaceName, "", false),
+			})).To(BeTrue())
+			// Deleting a pending or finished PipelineRun doesn't free a slot
+			Expect(schedulingPredicate().Delete(event.DeleteEvent{
+				Object: newPipelineRun(MintMakerNamespaceName, tektonv1.PipelineRunSpecStatusPending, false),
+			})).To(BeFalse())
+			Expect(schedulingPredicate().Delete(event.DeleteEvent{
+				Object: newPipelineRun(MintMakerNamespaceName, "", true),
+			})).To(BeFalse())
+			Expect(schedulingPredicate().Delete(event.DeleteEvent{
+				Object: newPipelineRun("testnamespace", "", false),
+			})).To(BeFalse())
+		})
+
+		It("should schedule when a PipelineRun finishes", func() {
+			running := newPipelineRun(MintMakerNamespaceName, "", false)
+			done := newPipelineRun(MintMakerNamespaceName, "", true)
+			Expect(schedulingPredicate().Update(event.UpdateEvent{ObjectOld: running, ObjectNew: done})).To(BeTrue())
+			Expect(schedulingPredicate().Update(event.UpdateEvent{ObjectOld: running, ObjectNew: running})).To(BeFalse())
+			Expect(schedulingPredicate().Update(event.UpdateEvent{ObjectOld: done, ObjectNew: done})).To(BeFalse())
+		})
+
+		It("should requeue the scheduling after the resync period", func() {
+			createNamespace(MintMakerNamespaceName)
+			cfg := config.DefaultConfig()
+			cfg.PipelineRunConfig.SchedulerResyncPeriod = 42 * time.Second
+			reconciler := &PipelineRunReconciler{
+				Client:    cachedClient,
+				Scheme:    cachedClient.Scheme(),
+				GetConfig: func() *config.ControllerConfig { return cfg },
+			}
+
+			result, err := reconciler.Reconcile(ctx, schedulerRequest)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result.RequeueAfter).To(Equal(42 * time.Second))
+		})
+
+		It("should requeue the scheduling before the resync period when a PipelineRun is held", func() {
+			Expect(earliestRequeue(time.Minute, 10*time.Second)).To(Equal(10 * time.Second))
+			Expect(earliestRequeue(time.Minute, time.Hour)).To(Equal(time.Minute))
+			// A time which passed already is retried shortly
+			Expect(earliestRequeue(time.Minute, -time.Second)).To(Equal(time.Second))
+			Expect(earliestRequeue(0, time.Hour)).To(Equal(time.Hour))
+		})
+	})
 })
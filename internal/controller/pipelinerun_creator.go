@@ -0,0 +1,404 @@
+// Copyright 2024 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	ctrllog "sigs.k8s.io/controller-runtime/pkg/log"
+
+	tektonv1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+
+	"github.com/konflux-ci/mintmaker/internal/pkg/component"
+	"github.com/konflux-ci/mintmaker/internal/pkg/config"
+	. "github.com/konflux-ci/mintmaker/internal/pkg/constant"
+	"github.com/konflux-ci/mintmaker/internal/pkg/tekton"
+	"github.com/konflux-ci/mintmaker/internal/pkg/utils"
+)
+
+// pipelineRunCreator creates the Renovate PipelineRuns, it's shared by the
+// controllers which start new runs for components
+type pipelineRunCreator struct {
+	client client.Client
+	scheme *runtime.Scheme
+	config *config.PipelineRunConfig
+}
+
+func newPipelineRunCreator(client client.Client, scheme *runtime.Scheme, config *config.PipelineRunConfig) *pipelineRunCreator {
+	return &pipelineRunCreator{client: client, scheme: scheme, config: config}
+}
+
+// getCAConfigMap returns the first ConfigMap found in mintmaker namespace
+// that has the label 'config.openshift.io/inject-trusted-cabundle: "true"'.
+// If no such ConfigMap is found, it returns nil.
+func (r *pipelineRunCreator) getCAConfigMap(ctx context.Context) (*corev1.ConfigMap, error) {
+	configMapList := &corev1.ConfigMapList{}
+	labelSelector := client.MatchingLabels{"config.openshift.io/inject-trusted-cabundle": "true"}
+	listOptions := []client.ListOption{
+		client.InNamespace(MintMakerNamespaceName),
+		labelSelector,
+	}
+	err := r.client.List(ctx, configMapList, listOptions...)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(configMapList.Items) > 0 {
+		// Just return the configmap
+		return &configMapList.Items[0], nil
+	}
+
+	return nil, nil
+}
+
+// Create a secret that merges all secret with the label:
+// mintmaker.appstudio.redhat.com/secret-type: registry
+// and return the new secret
+func (r *pipelineRunCreator) createMergedPullSecret(ctx context.Context) (*corev1.Secret, error) {
+	log := ctrllog.FromContext(ctx)
+
+	secretList := &corev1.SecretList{}
+	labelSelector := client.MatchingLabels{"mintmaker.appstudio.redhat.com/secret-type": "registry"}
+	listOptions := []client.ListOption{
+		client.InNamespace(MintMakerNamespaceName),
+		labelSelector,
+	}
+
+	err := r.client.List(ctx, secretList, listOptions...)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if len(secretList.Items) == 0 {
+		// No secrets to merge
+		return nil, nil
+	}
+
+	log.Info(fmt.Sprintf("Found %d secrets to merge", len(secretList.Items)))
+
+	mergedAuths := make(map[string]interface{})
+	for _, secret := range secretList.Items {
+		if secret.Type == corev1.SecretTypeDockerConfigJson {
+			data, exists := secret.Data[".dockerconfigjson"]
+			if !exists {
+				// No .dockerconfigjson section
+				log.Info("Found secret without .dockerconfigjson section")
+				return nil, nil
+			}
+
+			var dockerConfig map[string]interface{}
+			if err := json.Unmarshal(data, &dockerConfig); err != nil {
+				return nil, err
+			}
+
+			auths, exists := dockerConfig["auths"].(map[string]interface{})
+			if !exists {
+				continue
+			}
+
+			for registry, creds := range auths {
+				mergedAuths[registry] = creds
+			}
+		}
+	}
+
+	mergedDockerConfig := map[string]interface{}{
+		"auths": mergedAuths,
+	}
+
+	if len(mergedAuths) == 0 {
+		log.Info("Merged auths empty, skipping creation of secret")
+		return nil, nil
+	}
+
+	mergedConfigJson, err := json.Marshal(mergedDockerConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	timestamp := time.Now().UTC().Format("01021504") // MMDDhhmm, from Go's time formatting reference date "20060102150405"
+	name := fmt.Sprintf("renovate-image-pull-secrets-%s-%s", timestamp, utils.RandomString(5))
+
+	newSecret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: MintMakerNamespaceName,
+		},
+		Type: corev1.SecretTypeDockerConfigJson,
+		Data: map[string][]byte{
+			".dockerconfigjson": []byte(mergedConfigJson),
+		},
+	}
+
+	if err := r.client.Create(ctx, newSecret); err != nil {
+		return nil, err
+	}
+
+	return newSecret, nil
+}
+
+// createPipelineRun creates and returns a new pending PipelineRun for the
+// component, together with the ConfigMap and Secrets it mounts. The labels
+// and annotations are added to the ones set for every PipelineRun.
+func (r *pipelineRunCreator) createPipelineRun(name string, comp component.GitComponent, ctx context.Context, registrySecret *corev1.Secret, labels, annotations map[string]string) (*tektonv1.PipelineRun, error) {
+
+	log := ctrllog.FromContext(ctx)
+
+	var resources []client.Object
+	defer func() {
+		if len(resources) > 0 {
+			for _, resource := range resources {
+				// Ignore error
+				r.client.Delete(ctx, resource)
+			}
+		}
+	}()
+
+	renovateConfig, err := comp.GetRenovateConfig(registrySecret)
+	if err != nil {
+		return nil, err
+	}
+	renovateJsConfig := "module.exports = " + renovateConfig
+	// Create ConfigMap for Renovate global configuration
+	renovateConfigMap := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: MintMakerNamespaceName,
+		},
+		Data: map[string]string{
+			"config.js": renovateJsConfig,
+		},
+	}
+
+	if err := r.client.Create(ctx, renovateConfigMap); err != nil {
+		return nil, err
+	}
+	resources = append(resources, renovateConfigMap)
+
+	// Secret for Renovate token (repository access token)
+	renovateSecret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: MintMakerNamespaceName,
+		},
+		Type:       corev1.SecretTypeOpaque,
+		StringData: map[string]string{},
+	}
+
+	// For GitHub repositories, we intentionally do not set the "renovate-token"
+	// key. GitHub tokens generated from the Konflux GitHub application have a
+	// maximum lifespan of 1 hour. Instead of generating a token that might expire
+	// before the pipelinerun starts, we wait for events with "FailedMount" reason,
+	// which happens when pod try to mount the secret but can't find the key in
+	// secret. Then we populate the token in the event controller at that time to
+	// ensure it's valid for the pipelinerun execution.
+	if comp.GetPlatform() != "github" {
+		renovateToken, err := comp.GetToken()
+		if err != nil {
+			return nil, err
+		}
+		renovateSecret.StringData["renovate-token"] = renovateToken
+	}
+
+	if err := r.client.Create(ctx, renovateSecret); err != nil {
+		return nil, err
+	}
+	resources = append(resources, renovateSecret)
+
+	// Create Secret for RPM activation key to access RPMs that require subscription
+	activationKey, org, rpmKeyErr := comp.GetRPMActivationKey(r.client, ctx)
+	var rpmSecret *corev1.Secret = nil
+	if rpmKeyErr != nil {
+		log.Info(rpmKeyErr.Error())
+	} else {
+		rpmSecret = &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name + "-rpm-key",
+				Namespace: MintMakerNamespaceName,
+			},
+			Type: corev1.SecretTypeOpaque,
+			StringData: map[string]string{
+				"activationkey": activationKey,
+				"org":           org,
+			},
+		}
+
+		if err := r.client.Create(ctx, rpmSecret); err != nil {
+			return nil, err
+		}
+		resources = append(resources, rpmSecret)
+	}
+
+	// Creating the pipelineRun definition. A referenced pipeline has to declare
+	// the shared-data, renovate-config and renovate-token workspaces, and the
+	// optional rpm-activation-key, ca-bundle and registry-auth workspaces.
+	builder := tekton.NewPipelineRunBuilder(name, MintMakerNamespaceName).
+		WithLabels(map[string]string{
+			MintMakerApplicationLabel:        comp.GetApplication(),
+			MintMakerComponentNameLabel:      comp.GetName(),
+			MintMakerComponentNamespaceLabel: comp.GetNamespace(),
+			MintMakerGitPlatformLabel:        comp.GetPlatform(), // (github, gitlab)
+			MintMakerGitHostLabel:            comp.GetHost(),     // github.com, gitlab.com, gitlab.other.com
+			MintMakerRepositoryLabel:         utils.NormalizeLabelValue(comp.GetRepository()),
+		}).
+		WithLabels(labels).
+		WithAnnotations(annotations).
+		WithPipelineRef(r.config.PipelineRef, r.config.PipelineRefTask)
+
+	// The images, resources, log level and timeout can be configured, and
+	// overridden per namespace and per component
+	run, err := r.config.RenovateRunFor(comp.GetNamespace(), comp.GetAnnotations())
+	if err != nil {
+		log.Info(fmt.Sprintf("ignoring invalid overrides of component %s: %s", comp.GetName(), err.Error()))
+	}
+	// A referenced pipeline brings its own images and environment, and has to
+	// provide the renovate-summary result itself
+	if r.config.PipelineRef == nil {
+		builder.WithStepImage("prepare-db", run.OSVDatabaseImage).
+			WithStepImage("prepare-rpm-cert", run.RPMCertImage).
+			WithStepImage("renovate", run.Image).
+			WithStepEnv("renovate", corev1.EnvVar{Name: "LOG_LEVEL", Value: run.LogLevel}).
+			WithSummaryStep(run.Image)
+	}
+	builder.WithStepResources("renovate", run.Resources).
+		WithTimeouts(&tektonv1.TimeoutFields{Pipeline: &metav1.Duration{Duration: run.Timeout}})
+	// Renovate pods can be pinned to dedicated nodes
+	builder.WithNodeSelector(r.config.PodTemplate.NodeSelector).
+		WithTolerations(r.config.PodTemplate.Tolerations...).
+		WithAffinity(r.config.PodTemplate.Affinity)
+	// The installation is used by the scheduler to limit parallel runs per installation
+	if installationComp, ok := comp.(component.AppInstallationComponent); ok {
+		if installationID, err := installationComp.GetInstallationID(); err == nil {
+			builder.WithLabels(map[string]string{MintMakerInstallationLabel: strconv.FormatInt(installationID, 10)})
+		} else {
+			log.Info(fmt.Sprintf("failed to get installation ID for %s: %s", comp.GetName(), err.Error()))
+		}
+	}
+	builder.WithServiceAccount("mintmaker-controller-manager")
+
+	cmItems := []corev1.KeyToPath{
+		{
+			Key:  "config.js",
+			Path: "config.js",
+		},
+	}
+	cmOpts := tekton.NewMountOptions().WithTaskName("build").WithStepNames([]string{"renovate"}).WithWorkspaceName("renovate-config")
+	builder.WithConfigMap(name, "/etc/renovate/config", cmItems, cmOpts)
+
+	secretItems := []corev1.KeyToPath{
+		{
+			Key:  "renovate-token",
+			Path: "renovate-token",
+		},
+	}
+	secretOpts := tekton.NewMountOptions().WithTaskName("build").WithStepNames([]string{"renovate"}).WithWorkspaceName("renovate-token")
+	builder.WithSecret(name, "/etc/renovate/secret", secretItems, secretOpts)
+
+	if rpmKeyErr == nil {
+		rpmSecretItems := []corev1.KeyToPath{
+			{
+				Key:  "activationkey",
+				Path: "rpm-activationkey",
+			},
+			{
+				Key:  "org",
+				Path: "rpm-org",
+			},
+		}
+		rpmSecretOpts := tekton.NewMountOptions().WithTaskName("build").WithStepNames([]string{"prepare-rpm-cert"}).WithWorkspaceName("rpm-activation-key")
+		builder.WithSecret(name+"-rpm-key", "/etc/renovate/secret", rpmSecretItems, rpmSecretOpts)
+	}
+
+	// Check if a ConfigMap with the label `config.openshift.io/inject-trusted-cabundle: "true"` exists.
+	// If such a ConfigMap is found, add a volume to the PipelineRun specification to mount this ConfigMap.
+	// The volume will be mounted at '/etc/pki/ca-trust/extracted/pem' within the PipelineRun Pod.
+	caConfigMap, err := r.getCAConfigMap(ctx)
+	if err != nil {
+		log.Error(err, "Failed to get CAConfigMap - moving on")
+	}
+	if caConfigMap != nil {
+		caConfigMapItems := []corev1.KeyToPath{
+			{
+				Key:  "ca-bundle.crt",
+				Path: "tls-ca-bundle.pem",
+			},
+		}
+		caConfigMapOpts := tekton.NewMountOptions().WithTaskName("build").WithStepNames([]string{"renovate"}).WithReadOnly(true).
+			WithWorkspaceName("ca-bundle")
+		builder.WithConfigMap(caConfigMap.ObjectMeta.Name, "/etc/pki/ca-trust/extracted/pem", caConfigMapItems, caConfigMapOpts)
+	}
+
+	if registrySecret != nil {
+		secretItems := []corev1.KeyToPath{
+			{
+				Key:  ".dockerconfigjson",
+				Path: "config.json",
+			},
+		}
+		secretOpts := tekton.NewMountOptions().WithTaskName("build").WithStepNames([]string{"renovate"}).WithReadOnly(true).
+			WithWorkspaceName("registry-auth")
+		builder.WithSecret(registrySecret.ObjectMeta.Name, "/home/renovate/.docker", secretItems, secretOpts)
+	}
+
+	pipelineRun, err := builder.Build()
+	if err != nil {
+		log.Error(err, "failed to build pipeline definition")
+		return nil, err
+	}
+	if err := r.client.Create(ctx, pipelineRun); err != nil {
+		return nil, err
+	}
+	resources = append(resources, pipelineRun)
+
+	// Set ownership so all resources get deleted once the job is deleted
+	// ownership for renovateSecret
+	if err := controllerutil.SetOwnerReference(pipelineRun, renovateSecret, r.scheme); err != nil {
+		return nil, err
+	}
+	if err := r.client.Update(ctx, renovateSecret); err != nil {
+		return nil, err
+	}
+
+	// ownership for RPM secret
+	if rpmKeyErr == nil {
+		if err := controllerutil.SetOwnerReference(pipelineRun, rpmSecret, r.scheme); err != nil {
+			return nil, err
+		}
+		if err := r.client.Update(ctx, rpmSecret); err != nil {
+			return nil, err
+		}
+	}
+
+	// ownership for the renovateConfigMap
+	if err := controllerutil.SetOwnerReference(pipelineRun, renovateConfigMap, r.scheme); err != nil {
+		return nil, err
+	}
+	if err := r.client.Update(ctx, renovateConfigMap); err != nil {
+		return nil, err
+	}
+
+	resources = nil
+	return pipelineRun, nil
+}
@@ -0,0 +1,102 @@
+// Copyright 2024 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controller
+
+import (
+	"context"
+	"time"
+
+	tektonv1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	ctrllog "sigs.k8s.io/controller-runtime/pkg/log"
+
+	"github.com/konflux-ci/mintmaker/internal/pkg/config"
+	. "github.com/konflux-ci/mintmaker/internal/pkg/constant"
+	mintmakermetrics "github.com/konflux-ci/mintmaker/internal/pkg/metrics"
+	"github.com/konflux-ci/mintmaker/internal/pkg/retention"
+)
+
+// PipelineRunGarbageCollector periodically deletes the completed PipelineRuns
+// which aren't kept by the retention policy. The Secrets and ConfigMaps owned
+// by the PipelineRuns are deleted with them.
+type PipelineRunGarbageCollector struct {
+	Client    client.Client
+	GetConfig config.Getter
+}
+
+// SetupWithManager adds the garbage collector to the Manager, it only runs
+// on the leader.
+func (gc *PipelineRunGarbageCollector) SetupWithManager(mgr ctrl.Manager) error {
+	return mgr.Add(gc)
+}
+
+// Start runs the garbage collection until the context is cancelled
+func (gc *PipelineRunGarbageCollector) Start(ctx context.Context) error {
+	log := ctrllog.FromContext(ctx).WithName("PipelineRunGarbageCollector")
+	ctx = ctrllog.IntoContext(ctx, log)
+
+	for {
+		policy := gc.GetConfig().PipelineRunConfig.RetentionPolicy
+		if err := gc.collect(ctx, &policy); err != nil {
+			log.Error(err, "failed to garbage collect PipelineRuns")
+		}
+		// The interval is read again after each collection, so it can be reloaded
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(policy.Interval):
+		}
+	}
+}
+
+// +kubebuilder:rbac:groups=tekton.dev,resources=pipelineruns,verbs=get;list;watch;delete
+
+// collect deletes the completed PipelineRuns which expired
+func (gc *PipelineRunGarbageCollector) collect(ctx context.Context, policy *config.RetentionPolicy) error {
+	log := ctrllog.FromContext(ctx)
+
+	var pipelineRunList tektonv1.PipelineRunList
+	if err := gc.Client.List(ctx, &pipelineRunList,
+		client.InNamespace(MintMakerNamespaceName),
+		client.HasLabels{MintMakerComponentNameLabel},
+	); err != nil {
+		return err
+	}
+
+	result := retention.Apply(policy, pipelineRunList.Items, time.Now())
+	mintmakermetrics.SetRetainedPipelineRuns(len(result.Retained))
+
+	deleted := 0
+	for i := range result.Expired {
+		run := &result.Expired[i]
+		if err := gc.Client.Delete(ctx, run, client.PropagationPolicy(metav1.DeletePropagationBackground)); err != nil {
+			if apierrors.IsNotFound(err) {
+				continue
+			}
+			log.Error(err, "failed to delete PipelineRun", "name", run.Name)
+			mintmakermetrics.CountGarbageCollectedPipelineRun(false)
+			continue
+		}
+		mintmakermetrics.CountGarbageCollectedPipelineRun(true)
+		deleted++
+	}
+	if deleted > 0 {
+		log.Info("deleted expired PipelineRuns", "count", deleted, "retained", len(result.Retained))
+	}
+	return nil
+}
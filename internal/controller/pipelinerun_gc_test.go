@@ -0,0 +1,77 @@
+// Copyright 2024 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controller
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	tektonv1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	"github.com/konflux-ci/mintmaker/internal/pkg/config"
+	. "github.com/konflux-ci/mintmaker/internal/pkg/constant"
+)
+
+var _ = Describe("PipelineRun Garbage Collector", func() {
+
+	_ = BeforeEach(func() {
+		createNamespace(MintMakerNamespaceName)
+	})
+
+	_ = AfterEach(func() {
+		teardownPipelineRuns()
+	})
+
+	completePipelineRun := func(name string, completedAgo time.Duration) {
+		key := types.NamespacedName{Name: name, Namespace: MintMakerNamespaceName}
+		plr := &tektonv1.PipelineRun{}
+		Expect(k8sClient.Get(ctx, key, plr)).To(Succeed())
+		plr.Status.MarkSucceeded(string(tektonv1.PipelineRunReasonSuccessful), "%s", "succeeded")
+		plr.Status.CompletionTime = &metav1.Time{Time: time.Now().Add(-completedAgo)}
+		Expect(k8sClient.Status().Update(ctx, plr)).Should(Succeed())
+		Eventually(func(g Gomega) {
+			g.Expect(k8sClient.Get(ctx, key, plr)).To(Succeed())
+			g.Expect(plr.IsDone()).To(BeTrue())
+		}, timeout, interval).Should(Succeed())
+	}
+
+	It("should delete the completed pipelineruns not kept by the retention policy", func() {
+		labels := map[string]string{
+			MintMakerComponentNameLabel: "testcomp",
+			MintMakerGitHostLabel:       "github.com",
+			MintMakerRepositoryLabel:    "testcomp",
+		}
+		setupPipelineRun("gc-old", labels, 2*time.Hour)
+		completePipelineRun("gc-old", time.Hour)
+		setupPipelineRun("gc-new", labels, time.Hour)
+		completePipelineRun("gc-new", time.Minute)
+
+		policy := config.DefaultConfig().PipelineRunConfig.RetentionPolicy
+		policy.KeepLastPerRepository = 1
+		gc := &PipelineRunGarbageCollector{Client: k8sClient}
+		Expect(gc.collect(ctx, &policy)).To(Succeed())
+
+		Eventually(func() []string {
+			names := []string{}
+			for _, plr := range listPipelineRuns(MintMakerNamespaceName) {
+				names = append(names, plr.Name)
+			}
+			return names
+		}, timeout, interval).Should(Equal([]string{"gc-new"}))
+	})
+})
@@ -0,0 +1,70 @@
+// Copyright 2024 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controller
+
+import (
+	"context"
+
+	tektonv1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// The state of PipelineRuns is indexed in the cache, so the scheduler only
+// reads the pending and running PipelineRuns, not all the completed ones
+const (
+	pipelineRunStateField   = "mintmaker.appstudio.redhat.com/state"
+	pipelineRunStatePending = "pending"
+	pipelineRunStateRunning = "running"
+	pipelineRunStateDone    = "done"
+)
+
+// pipelineRunState returns the state of a PipelineRun for the index
+func pipelineRunState(obj client.Object) []string {
+	pipelineRun, ok := obj.(*tektonv1.PipelineRun)
+	if !ok {
+		return nil
+	}
+	switch {
+	case pipelineRun.IsPending():
+		return []string{pipelineRunStatePending}
+	case pipelineRun.IsDone():
+		return []string{pipelineRunStateDone}
+	default:
+		return []string{pipelineRunStateRunning}
+	}
+}
+
+// SetupIndexes adds the indexes used by the controllers to the cache of the Manager
+func SetupIndexes(ctx context.Context, mgr ctrl.Manager) error {
+	return indexPipelineRunState(ctx, mgr.GetFieldIndexer())
+}
+
+// indexPipelineRunState adds the state index of PipelineRuns to the cache
+func indexPipelineRunState(ctx context.Context, indexer client.FieldIndexer) error {
+	return indexer.IndexField(ctx, &tektonv1.PipelineRun{}, pipelineRunStateField, pipelineRunState)
+}
+
+// listPipelineRunsByState lists the PipelineRuns of a namespace in the given state
+func listPipelineRunsByState(ctx context.Context, reader client.Reader, namespace, state string) ([]tektonv1.PipelineRun, error) {
+	var pipelineRunList tektonv1.PipelineRunList
+	if err := reader.List(ctx, &pipelineRunList,
+		client.InNamespace(namespace),
+		client.MatchingFields{pipelineRunStateField: state},
+	); err != nil {
+		return nil, err
+	}
+	return pipelineRunList.Items, nil
+}
@@ -0,0 +1,159 @@
+// Copyright 2024 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	tektonv1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/rest"
+	toolscache "k8s.io/client-go/tools/cache"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	. "github.com/konflux-ci/mintmaker/internal/pkg/constant"
+)
+
+// newBenchmarkCache returns a started cache holding the given PipelineRuns.
+// The informers are fed from a static list instead of an API server.
+func newBenchmarkCache(b *testing.B, ctx context.Context, pipelineRuns []tektonv1.PipelineRun) cache.Cache {
+	scheme := runtime.NewScheme()
+	if err := tektonv1.AddToScheme(scheme); err != nil {
+		b.Fatal(err)
+	}
+	mapper := meta.NewDefaultRESTMapper(nil)
+	mapper.Add(tektonv1.SchemeGroupVersion.WithKind("PipelineRun"), meta.RESTScopeNamespace)
+
+	listWatch := &toolscache.ListWatch{
+		ListFunc: func(options metav1.ListOptions) (runtime.Object, error) {
+			return &tektonv1.PipelineRunList{Items: pipelineRuns}, nil
+		},
+		WatchFunc: func(options metav1.ListOptions) (watch.Interface, error) {
+			return watch.NewFake(), nil
+		},
+	}
+	informerCache, err := cache.New(&rest.Config{Host: "http://localhost"}, cache.Options{
+		Scheme: scheme,
+		Mapper: mapper,
+		NewInformer: func(_ toolscache.ListerWatcher, obj runtime.Object, resync time.Duration, indexers toolscache.Indexers) toolscache.SharedIndexInformer {
+			return toolscache.NewSharedIndexInformer(listWatch, obj, resync, indexers)
+		},
+	})
+	if err != nil {
+		b.Fatal(err)
+	}
+	if err := indexPipelineRunState(ctx, informerCache); err != nil {
+		b.Fatal(err)
+	}
+
+	go func() {
+		if err := informerCache.Start(ctx); err != nil {
+			b.Error(err)
+		}
+	}()
+	// Getting the informer also creates it, so the cache can be synced
+	if _, err := informerCache.GetInformer(ctx, &tektonv1.PipelineRun{}); err != nil {
+		b.Fatal(err)
+	}
+	if !informerCache.WaitForCacheSync(ctx) {
+		b.Fatal("cache not synced")
+	}
+	return informerCache
+}
+
+// newBenchmarkPipelineRuns returns PipelineRuns as found in a busy cluster,
+// where completed PipelineRuns outnumber the pending and running ones
+func newBenchmarkPipelineRuns(done, running, pending int) []tektonv1.PipelineRun {
+	var pipelineRuns []tektonv1.PipelineRun
+	newPipelineRun := func(name string) tektonv1.PipelineRun {
+		return tektonv1.PipelineRun{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: MintMakerNamespaceName,
+				Labels:    map[string]string{MintMakerComponentNamespaceLabel: "testnamespace"},
+			},
+		}
+	}
+	for i := range done {
+		run := newPipelineRun(fmt.Sprintf("done-%d", i))
+		run.Status.MarkSucceeded(string(tektonv1.PipelineRunReasonSuccessful), "succeeded")
+		pipelineRuns = append(pipelineRuns, run)
+	}
+	for i := range running {
+		pipelineRuns = append(pipelineRuns, newPipelineRun(fmt.Sprintf("running-%d", i)))
+	}
+	for i := range pending {
+		run := newPipelineRun(fmt.Sprintf("pending-%d", i))
+		run.Spec.Status = tektonv1.PipelineRunSpecStatusPending
+		pipelineRuns = append(pipelineRuns, run)
+	}
+	return pipelineRuns
+}
+
+// BenchmarkListSchedulablePipelineRuns compares scanning all PipelineRuns,
+// as the scheduler used to do, with listing them by the state index.
+// Run it with: go test ./internal/controller -run '^$' -bench .
+func BenchmarkListSchedulablePipelineRuns(b *testing.B) {
+	for _, done := range []int{1000, 10000} {
+		ctx, cancel := context.WithCancel(context.Background())
+		reader := newBenchmarkCache(b, ctx, newBenchmarkPipelineRuns(done, 40, 100))
+
+		b.Run(fmt.Sprintf("scan/done=%d", done), func(b *testing.B) {
+			for range b.N {
+				var pipelineRunList tektonv1.PipelineRunList
+				if err := reader.List(ctx, &pipelineRunList, client.InNamespace(MintMakerNamespaceName)); err != nil {
+					b.Fatal(err)
+				}
+				var running, pending []tektonv1.PipelineRun
+				for _, run := range pipelineRunList.Items {
+					if !run.IsPending() && !run.IsDone() {
+						running = append(running, run)
+					}
+					if run.IsPending() {
+						pending = append(pending, run)
+					}
+				}
+				if len(running) != 40 || len(pending) != 100 {
+					b.Fatalf("unexpected PipelineRuns: %d running, %d pending", len(running), len(pending))
+				}
+			}
+		})
+
+		b.Run(fmt.Sprintf("index/done=%d", done), func(b *testing.B) {
+			for range b.N {
+				running, err := listPipelineRunsByState(ctx, reader, MintMakerNamespaceName, pipelineRunStateRunning)
+				if err != nil {
+					b.Fatal(err)
+				}
+				pending, err := listPipelineRunsByState(ctx, reader, MintMakerNamespaceName, pipelineRunStatePending)
+				if err != nil {
+					b.Fatal(err)
+				}
+				if len(running) != 40 || len(pending) != 100 {
+					b.Fatalf("unexpected PipelineRuns: %d running, %d pending", len(running), len(pending))
+				}
+			}
+		})
+
+		cancel()
+	}
+}
@@ -0,0 +1,265 @@
+// Copyright 2024 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	tektonv1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"knative.dev/pkg/apis"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	ctrllog "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+
+	appstudiov1alpha1 "github.com/konflux-ci/application-api/api/v1alpha1"
+
+	mmv1alpha1 "github.com/konflux-ci/mintmaker/api/v1alpha1"
+	. "github.com/konflux-ci/mintmaker/internal/pkg/constant"
+	mintmakermetrics "github.com/konflux-ci/mintmaker/internal/pkg/metrics"
+	"github.com/konflux-ci/mintmaker/internal/pkg/ratelimit"
+	"github.com/konflux-ci/mintmaker/internal/pkg/scheduler"
+	"github.com/konflux-ci/mintmaker/internal/pkg/tekton"
+)
+
+const (
+	// Reasons of the events published on components when their PipelineRun finishes
+	renovateSucceededReason = "RenovateSucceeded"
+	renovateFailedReason    = "RenovateFailed"
+)
+
+// PipelineRunResultReconciler surfaces the outcome of finished PipelineRuns,
+// as Kubernetes events on their component, as metrics and as the
+// DependencyUpdateReport of their component
+type PipelineRunResultReconciler struct {
+	Client   client.Client
+	Scheme   *runtime.Scheme
+	Recorder record.EventRecorder
+}
+
+// +kubebuilder:rbac:groups=appstudio.redhat.com,resources=dependencyupdatereports,verbs=get;list;watch;create;update;patch
+// +kubebuilder:rbac:groups=appstudio.redhat.com,resources=dependencyupdatereports/status,verbs=get;update;patch
+
+// Reconcile reads the renovate-summary result of a finished PipelineRun,
+// publishes an event on the component the PipelineRun ran for and updates
+// the metrics of its repository. The renovate-dependencies result of a
+// successful PipelineRun replaces the DependencyUpdateReport of the
+// component. PipelineRuns without a summary, e.g. the ones which failed
+// before Renovate ran, only report their success or failure.
+func (r *PipelineRunResultReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	log := ctrllog.FromContext(ctx).WithName("PipelineRunResultController")
+	ctx = ctrllog.IntoContext(ctx, log)
+
+	pipelineRun := &tektonv1.PipelineRun{}
+	if err := r.Client.Get(ctx, req.NamespacedName, pipelineRun); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+	if !pipelineRun.IsDone() {
+		return ctrl.Result{}, nil
+	}
+
+	succeeded := pipelineRun.Status.GetCondition(apis.ConditionSucceeded).IsTrue()
+	summary, err := tekton.RenovateSummaryOf(pipelineRun)
+	if err != nil {
+		// The outcome is still reported, without the summary
+		log.Error(err, "invalid Renovate summary", "pipelinerun", pipelineRun.Name)
+	}
+
+	state := "unknown"
+	if summary != nil {
+		state = string(summary.State())
+	}
+	if !succeeded {
+		state = string(tekton.RepositoryError)
+	}
+	namespace := pipelineRun.Labels[MintMakerComponentNamespaceLabel]
+
+	appstudioComponent := &appstudiov1alpha1.Component{}
+	componentKey := types.NamespacedName{
+		Namespace: namespace,
+		Name:      pipelineRun.Labels[MintMakerComponentNameLabel],
+	}
+	if err := r.Client.Get(ctx, componentKey, appstudioComponent); err != nil {
+		if apierrors.IsNotFound(err) {
+			log.Info(fmt.Sprintf("component %v not found, not publishing the outcome of PipelineRun %s", componentKey, pipelineRun.Name))
+			recordOutcome(ctx, pipelineRun, summary, succeeded, state)
+			return ctrl.Result{}, nil
+		}
+		return ctrl.Result{}, err
+	}
+
+	// A failed run keeps the report of the last successful run
+	if succeeded {
+		dependencies, err := tekton.RenovateDependenciesOf(pipelineRun)
+		if err != nil {
+			log.Error(err, "invalid Renovate dependencies", "pipelinerun", pipelineRun.Name)
+		}
+		if dependencies != nil {
+			if err := r.updateReport(ctx, appstudioComponent, pipelineRun, dependencies); err != nil {
+				log.Error(err, "failed to update the dependency update report", "component", componentKey)
+				return ctrl.Result{}, err
+			}
+		}
+	}
+
+	// The outcome is published once nothing can fail anymore, so a requeued
+	// PipelineRun isn't counted twice
+	if succeeded && state != string(tekton.RepositoryError) {
+		r.Recorder.Event(appstudioComponent, corev1.EventTypeNormal, renovateSucceededReason, outcomeMessage(pipelineRun, summary))
+	} else {
+		r.Recorder.Event(appstudioComponent, corev1.EventTypeWarning, renovateFailedReason, outcomeMessage(pipelineRun, summary))
+	}
+	recordOutcome(ctx, pipelineRun, summary, succeeded, state)
+	return ctrl.Result{}, nil
+}
+
+// recordOutcome updates the metrics of the repository of a finished
+// PipelineRun and the API quota left by the run. It's called once per
+// PipelineRun, when its reconcile can't fail anymore.
+func recordOutcome(ctx context.Context, pipelineRun *tektonv1.PipelineRun, summary *tekton.RenovateSummary, succeeded bool, state string) {
+	namespace := pipelineRun.Labels[MintMakerComponentNamespaceLabel]
+	repository := pipelineRun.Labels[MintMakerRepositoryLabel]
+	mintmakermetrics.CountRenovateRun(namespace, repository, state)
+	if summary != nil {
+		mintmakermetrics.SetRenovateOutcome(namespace, repository,
+			summary.BranchesUpdated, summary.PRsCreated, summary.PRsUpdated, summary.Dependencies)
+		recordRateLimit(pipelineRun, summary)
+	}
+	ctrllog.FromContext(ctx).Info(fmt.Sprintf("Renovate finished for repository %s", repository),
+		"pipelinerun", pipelineRun.Name, "success", succeeded, "status", state)
+}
+
+// updateReport replaces the DependencyUpdateReport of the component with the
+// updates found by the PipelineRun. The report is owned by the component, so
+// it's deleted together with it. The updates are written to the status of the
+// report once it exists.
+func (r *PipelineRunResultReconciler) updateReport(
+	ctx context.Context,
+	appstudioComponent *appstudiov1alpha1.Component,
+	pipelineRun *tektonv1.PipelineRun,
+	dependencies *tekton.RenovateDependencies,
+) error {
+	report := &mmv1alpha1.DependencyUpdateReport{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      appstudioComponent.Name,
+			Namespace: appstudioComponent.Namespace,
+		},
+	}
+	_, err := controllerutil.CreateOrUpdate(ctx, r.Client, report, func() error {
+		return controllerutil.SetOwnerReference(appstudioComponent, report, r.Scheme)
+	})
+	if err != nil {
+		return err
+	}
+
+	report.Status = mmv1alpha1.DependencyUpdateReportStatus{
+		Component:       appstudioComponent.Name,
+		PipelineRun:     pipelineRun.Name,
+		UpdateTimestamp: metav1.Now(),
+		TotalUpdates:    int32(dependencies.Total),
+	}
+	for _, dependency := range dependencies.Updates {
+		report.Status.Updates = append(report.Status.Updates, mmv1alpha1.DependencyUpdate{
+			Manager:               dependency.Manager,
+			PackageFile:           dependency.PackageFile,
+			Name:                  dependency.Name,
+			CurrentVersion:        dependency.CurrentVersion,
+			NewVersion:            dependency.NewVersion,
+			UpdateType:            dependency.UpdateType,
+			PullRequest:           int32(dependency.PullRequest),
+			Vulnerable:            dependency.Vulnerable,
+			VulnerabilitySeverity: dependency.VulnerabilitySeverity,
+		})
+		if dependency.Vulnerable {
+			report.Status.VulnerableUpdates++
+		}
+	}
+	return r.Client.Status().Update(ctx, report)
+}
+
+// recordRateLimit records the API quota Renovate reported at the end of the
+// run, so the scheduler holds the pending runs of the same git host and
+// installation until it's reset
+func recordRateLimit(pipelineRun *tektonv1.PipelineRun, summary *tekton.RenovateSummary) {
+	key := scheduler.RateLimitKeyOf(pipelineRun)
+	if key.Host == "" {
+		return
+	}
+	end := time.Now()
+	if pipelineRun.Status.CompletionTime != nil {
+		end = pipelineRun.Status.CompletionTime.Time
+	}
+	if quota, ok := summary.Quota(end); ok {
+		ratelimit.DefaultTracker.Update(key, quota)
+	}
+}
+
+// outcomeMessage describes the outcome of a PipelineRun for the event on its component
+func outcomeMessage(pipelineRun *tektonv1.PipelineRun, summary *tekton.RenovateSummary) string {
+	if summary == nil {
+		condition := pipelineRun.Status.GetCondition(apis.ConditionSucceeded)
+		if condition.IsTrue() {
+			return fmt.Sprintf("PipelineRun %s succeeded", pipelineRun.Name)
+		}
+		return fmt.Sprintf("PipelineRun %s failed: %s", pipelineRun.Name, condition.GetReason())
+	}
+	message := fmt.Sprintf("PipelineRun %s: repository %s, %d branches updated, %d pull requests created and %d updated, %d dependencies",
+		pipelineRun.Name, summary.State(), summary.BranchesUpdated, summary.PRsCreated, summary.PRsUpdated, summary.Dependencies)
+	if len(summary.Errors) > 0 {
+		message += fmt.Sprintf(", errors: %s", strings.Join(summary.Errors, "; "))
+	}
+	return message
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *PipelineRunResultReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		Named("pipelinerun-result").
+		For(&tektonv1.PipelineRun{}).
+		WithEventFilter(predicate.Funcs{
+			CreateFunc: func(e event.CreateEvent) bool {
+				return false
+			},
+			DeleteFunc: func(e event.DeleteEvent) bool {
+				return false
+			},
+			UpdateFunc: func(e event.UpdateEvent) bool {
+				if e.ObjectNew.GetNamespace() != MintMakerNamespaceName {
+					return false
+				}
+				if oldPipelineRun, ok := e.ObjectOld.(*tektonv1.PipelineRun); ok {
+					if newPipelineRun, ok := e.ObjectNew.(*tektonv1.PipelineRun); ok {
+						return !oldPipelineRun.IsDone() && newPipelineRun.IsDone()
+					}
+				}
+				return false
+			},
+			GenericFunc: func(e event.GenericEvent) bool {
+				return false
+			},
+		}).
+		Complete(r)
+}
@@ -0,0 +1,165 @@
+// Copyright 2024 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controller
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	tektonv1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"knative.dev/pkg/apis"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	mmv1alpha1 "github.com/konflux-ci/mintmaker/api/v1alpha1"
+	. "github.com/konflux-ci/mintmaker/internal/pkg/constant"
+	"github.com/konflux-ci/mintmaker/internal/pkg/ratelimit"
+	"github.com/konflux-ci/mintmaker/internal/pkg/tekton"
+)
+
+var _ = Describe("PipelineRun Result Controller", func() {
+
+	componentKey := types.NamespacedName{Name: "resultcomp", Namespace: "testnamespace"}
+	plrName := "renovate-result"
+	plrLookupKey := types.NamespacedName{Name: plrName, Namespace: MintMakerNamespaceName}
+
+	_ = BeforeEach(func() {
+		createNamespace(MintMakerNamespaceName)
+		createNamespace(componentKey.Namespace)
+		createComponent(componentKey, "app", "https://github.com/resultcomp.git", "gitrevision", "gitsourcecontext")
+		setupPipelineRun(plrName, map[string]string{
+			MintMakerComponentNameLabel:      componentKey.Name,
+			MintMakerComponentNamespaceLabel: componentKey.Namespace,
+			MintMakerRepositoryLabel:         "resultcomp",
+			MintMakerGitHostLabel:            "github.com",
+			MintMakerInstallationLabel:       "resultcomp-installation",
+		}, 0)
+	})
+
+	_ = AfterEach(func() {
+		teardownPipelineRuns()
+		deleteComponent(componentKey)
+		report := &mmv1alpha1.DependencyUpdateReport{}
+		if err := k8sClient.Get(ctx, componentKey, report); err == nil {
+			Expect(k8sClient.Delete(ctx, report)).To(Succeed())
+		}
+	})
+
+	finishPipelineRun := func(status corev1.ConditionStatus, summary, dependencies string) {
+		plr := &tektonv1.PipelineRun{}
+		Expect(k8sClient.Get(ctx, plrLookupKey, plr)).To(Succeed())
+		plr.Status.SetCondition(&apis.Condition{
+			Type:   apis.ConditionSucceeded,
+			Status: status,
+			Reason: "Completed",
+		})
+		if summary != "" {
+			plr.Status.Results = append(plr.Status.Results, tektonv1.PipelineRunResult{
+				Name:  tekton.RenovateSummaryResult,
+				Value: *tektonv1.NewStructuredValues(summary),
+			})
+		}
+		if dependencies != "" {
+			plr.Status.Results = append(plr.Status.Results, tektonv1.PipelineRunResult{
+				Name:  tekton.RenovateDependenciesResult,
+				Value: *tektonv1.NewStructuredValues(dependencies),
+			})
+		}
+		Expect(k8sClient.Status().Update(ctx, plr)).Should(Succeed())
+	}
+
+	componentEvents := func() []corev1.Event {
+		events := &corev1.EventList{}
+		Expect(k8sClient.List(ctx, events, client.InNamespace(componentKey.Namespace))).To(Succeed())
+		var componentEvents []corev1.Event
+		for _, event := range events.Items {
+			if event.InvolvedObject.Kind == "Component" && event.InvolvedObject.Name == componentKey.Name {
+				componentEvents = append(componentEvents, event)
+			}
+		}
+		return componentEvents
+	}
+
+	It("should publish the summary of a successful run on the component", func() {
+		finishPipelineRun(corev1.ConditionTrue, `{"repository-status": "onboarded", "repository-result": "done",
+			"branches-updated": 2, "prs-created": 1, "prs-updated": 1, "dependencies": 12, "errors": []}`, "")
+
+		Eventually(componentEvents, timeout, interval).Should(ContainElement(And(
+			HaveField("Type", corev1.EventTypeNormal),
+			HaveField("Reason", "RenovateSucceeded"),
+			HaveField("Message", ContainSubstring("repository onboarded, 2 branches updated, 1 pull requests created and 1 updated, 12 dependencies")),
+		)))
+	})
+
+	It("should record the API quota reported to Renovate", func() {
+		finishPipelineRun(corev1.ConditionFalse, `{"repository-result": "external-host-error", "errors": [],
+			"rate-limit": {"limit": 5000, "remaining": 0, "reset": 1700000600}}`, "")
+
+		key := ratelimit.Key{Host: "github.com", Installation: "resultcomp-installation"}
+		Eventually(func() ratelimit.Quota {
+			quota, _ := ratelimit.DefaultTracker.Get(key)
+			return quota
+		}, timeout, interval).Should(Equal(ratelimit.Quota{Limit: 5000, Remaining: 0, Reset: time.Unix(1700000600, 0)}))
+	})
+
+	It("should publish a warning for a failed run", func() {
+		finishPipelineRun(corev1.ConditionFalse, "", "")
+
+		Eventually(componentEvents, timeout, interval).Should(ContainElement(And(
+			HaveField("Type", corev1.EventTypeWarning),
+			HaveField("Reason", "RenovateFailed"),
+		)))
+	})
+
+	It("should write the dependency update report of the component", func() {
+		finishPipelineRun(corev1.ConditionTrue, `{"repository-status": "onboarded", "repository-result": "done"}`,
+			`{"total": 5, "updates": [
+				{"m": "gomod", "f": "go.mod", "d": "golang.org/x/net", "c": "v0.20.0", "n": "v0.23.0", "t": "minor", "v": true, "s": "HIGH", "pr": 42},
+				{"m": "dockerfile", "f": "Dockerfile", "d": "registry.access.redhat.com/ubi9", "c": "9.4", "n": "9.5", "t": "minor"}
+			]}`)
+
+		report := &mmv1alpha1.DependencyUpdateReport{}
+		Eventually(func() error {
+			return k8sClient.Get(ctx, componentKey, report)
+		}, timeout, interval).Should(Succeed())
+		Expect(report.Status.Component).To(Equal(componentKey.Name))
+		Expect(report.Status.PipelineRun).To(Equal(plrName))
+		Expect(report.Status.TotalUpdates).To(BeEquivalentTo(5))
+		Expect(report.Status.VulnerableUpdates).To(BeEquivalentTo(1))
+		Expect(report.Status.Updates).To(HaveLen(2))
+		Expect(report.Status.Updates[0]).To(Equal(mmv1alpha1.DependencyUpdate{
+			Manager:               "gomod",
+			PackageFile:           "go.mod",
+			Name:                  "golang.org/x/net",
+			CurrentVersion:        "v0.20.0",
+			NewVersion:            "v0.23.0",
+			UpdateType:            "minor",
+			PullRequest:           42,
+			Vulnerable:            true,
+			VulnerabilitySeverity: "HIGH",
+		}))
+		Expect(report.OwnerReferences).To(ContainElement(HaveField("Name", componentKey.Name)))
+	})
+
+	It("should not write a report for a failed run", func() {
+		finishPipelineRun(corev1.ConditionFalse, "", `{"total": 0, "updates": []}`)
+
+		Consistently(func() error {
+			return k8sClient.Get(ctx, componentKey, &mmv1alpha1.DependencyUpdateReport{})
+		}, timeout, interval).ShouldNot(Succeed())
+	})
+})
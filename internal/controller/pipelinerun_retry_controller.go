@@ -0,0 +1,265 @@
+// Copyright 2024 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	tektonv1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"knative.dev/pkg/apis"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	ctrllog "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+
+	appstudiov1alpha1 "github.com/konflux-ci/application-api/api/v1alpha1"
+
+	"github.com/konflux-ci/mintmaker/internal/pkg/component"
+	"github.com/konflux-ci/mintmaker/internal/pkg/config"
+	. "github.com/konflux-ci/mintmaker/internal/pkg/constant"
+)
+
+// PipelineRunRetryReconciler retries PipelineRuns which failed for a
+// transient reason, e.g. an image pull error or an OOM kill
+type PipelineRunRetryReconciler struct {
+	Client    client.Client
+	Scheme    *runtime.Scheme
+	GetConfig config.Getter
+}
+
+// +kubebuilder:rbac:groups=tekton.dev,resources=taskruns,verbs=get;list;watch
+
+// Reconcile creates a new pending PipelineRun for a failed PipelineRun, if
+// its failure is retryable and the component has attempts left. The retry
+// gets a fresh Renovate config, token and registry secret, and is started by
+// the scheduler once the backoff has passed.
+func (r *PipelineRunRetryReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	log := ctrllog.FromContext(ctx).WithName("PipelineRunRetryController")
+	ctx = ctrllog.IntoContext(ctx, log)
+
+	pipelineRun := &tektonv1.PipelineRun{}
+	if err := r.Client.Get(ctx, req.NamespacedName, pipelineRun); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+	if !isFailed(pipelineRun) {
+		return ctrl.Result{}, nil
+	}
+	if retry, ok := pipelineRun.Annotations[MintMakerRetriedByAnnotation]; ok {
+		log.Info(fmt.Sprintf("PipelineRun %s has been retried by %s", pipelineRun.Name, retry))
+		return ctrl.Result{}, nil
+	}
+
+	cfg := r.GetConfig()
+	policy := &cfg.PipelineRunConfig.RetryPolicy
+	attempt := attemptOf(pipelineRun)
+	if attempt >= policy.MaxAttempts {
+		log.Info(fmt.Sprintf("PipelineRun %s failed, no attempts left", pipelineRun.Name), "attempt", attempt)
+		return ctrl.Result{}, nil
+	}
+
+	reasons, err := r.failureReasons(ctx, pipelineRun)
+	if err != nil {
+		log.Error(err, "failed to get failure reasons", "pipelinerun", pipelineRun.Name)
+		return ctrl.Result{}, err
+	}
+	if !policy.IsRetryable(reasons) {
+		log.Info(fmt.Sprintf("PipelineRun %s failed for a reason which is not retryable", pipelineRun.Name), "reasons", reasons)
+		return ctrl.Result{}, nil
+	}
+
+	retryName, err := r.createRetry(ctx, &cfg.PipelineRunConfig, pipelineRun, attempt+1)
+	if err != nil {
+		log.Error(err, "failed to retry PipelineRun", "pipelinerun", pipelineRun.Name)
+		return ctrl.Result{}, err
+	}
+	if retryName == "" {
+		return ctrl.Result{}, nil
+	}
+
+	// Mark the PipelineRun as retried, so it isn't retried again
+	original := pipelineRun.DeepCopy()
+	if pipelineRun.Annotations == nil {
+		pipelineRun.Annotations = make(map[string]string)
+	}
+	pipelineRun.Annotations[MintMakerRetriedByAnnotation] = retryName
+	if err := r.Client.Patch(ctx, pipelineRun, client.MergeFrom(original)); err != nil {
+		log.Error(err, "failed to mark PipelineRun as retried", "pipelinerun", pipelineRun.Name)
+		return ctrl.Result{}, err
+	}
+	return ctrl.Result{}, nil
+}
+
+// createRetry creates a pending PipelineRun retrying the failed one and
+// returns its name. The retry isn't started before the backoff has passed.
+// An empty name is returned if the component doesn't exist anymore.
+func (r *PipelineRunRetryReconciler) createRetry(ctx context.Context, cfg *config.PipelineRunConfig, pipelineRun *tektonv1.PipelineRun, attempt int) (string, error) {
+	log := ctrllog.FromContext(ctx)
+
+	appstudioComponent := &appstudiov1alpha1.Component{}
+	componentKey := types.NamespacedName{
+		Namespace: pipelineRun.Labels[MintMakerComponentNamespaceLabel],
+		Name:      pipelineRun.Labels[MintMakerComponentNameLabel],
+	}
+	if err := r.Client.Get(ctx, componentKey, appstudioComponent); err != nil {
+		if apierrors.IsNotFound(err) {
+			log.Info(fmt.Sprintf("component %v not found, not retrying PipelineRun %s", componentKey, pipelineRun.Name))
+			return "", nil
+		}
+		return "", err
+	}
+	if value, exists := appstudioComponent.Annotations[MintMakerDisabledAnnotationName]; exists && value == "true" {
+		log.Info(fmt.Sprintf("component %v has mintmaker disabled, not retrying PipelineRun %s", componentKey, pipelineRun.Name))
+		return "", nil
+	}
+	comp, err := component.NewGitComponent(appstudioComponent, r.Client, ctx)
+	if err != nil {
+		return "", err
+	}
+
+	finishedAt := time.Now()
+	if pipelineRun.Status.CompletionTime != nil {
+		finishedAt = pipelineRun.Status.CompletionTime.Time
+	}
+	notBefore := finishedAt.Add(cfg.RetryPolicy.Backoff(attempt))
+
+	creator := newPipelineRunCreator(r.Client, r.Scheme, cfg)
+	registrySecret, _ := creator.createMergedPullSecret(ctx)
+	// ignore the error, image pull secret is not required for all repositories
+
+	labels := map[string]string{
+		MintMakerPriorityLabel: pipelineRun.Labels[MintMakerPriorityLabel],
+		MintMakerAttemptLabel:  strconv.Itoa(attempt),
+	}
+	annotations := map[string]string{
+		MintMakerNotBeforeAnnotation: notBefore.UTC().Format(time.RFC3339),
+	}
+	retryName := retryNameOf(pipelineRun.Name, attempt)
+	retry, err := creator.createPipelineRun(retryName, comp, ctx, registrySecret, labels, annotations)
+	if err != nil {
+		if registrySecret != nil {
+			r.Client.Delete(ctx, registrySecret)
+		}
+		// The retry was created before the PipelineRun could be marked as retried
+		if apierrors.IsAlreadyExists(err) {
+			return retryName, nil
+		}
+		return "", err
+	}
+
+	// The registry secret is deleted together with the retry
+	if registrySecret != nil {
+		if err := controllerutil.SetOwnerReference(retry, registrySecret, r.Scheme); err != nil {
+			log.Info(fmt.Sprintf("failed to set ownership for the registry secret: %s", err.Error()))
+		} else if err := r.Client.Update(ctx, registrySecret); err != nil {
+			log.Info(fmt.Sprintf("failed to update the registry secret: %s", err.Error()))
+		}
+	}
+
+	log.Info(fmt.Sprintf("created PipelineRun %s to retry %s", retry.Name, pipelineRun.Name),
+		"attempt", attempt, "notBefore", annotations[MintMakerNotBeforeAnnotation])
+	return retry.Name, nil
+}
+
+// failureReasons returns the reasons of the PipelineRun failure, which are
+// the reasons of the PipelineRun, its TaskRuns and their terminated steps
+func (r *PipelineRunRetryReconciler) failureReasons(ctx context.Context, pipelineRun *tektonv1.PipelineRun) ([]string, error) {
+	var reasons []string
+	if reason := pipelineRun.Status.GetCondition(apis.ConditionSucceeded).GetReason(); reason != "" {
+		reasons = append(reasons, reason)
+	}
+
+	taskRuns := &tektonv1.TaskRunList{}
+	if err := r.Client.List(ctx, taskRuns,
+		client.InNamespace(pipelineRun.Namespace),
+		client.MatchingLabels{"tekton.dev/pipelineRun": pipelineRun.Name},
+	); err != nil {
+		return nil, err
+	}
+	for _, taskRun := range taskRuns.Items {
+		condition := taskRun.Status.GetCondition(apis.ConditionSucceeded)
+		if condition.IsTrue() {
+			continue
+		}
+		if reason := condition.GetReason(); reason != "" {
+			reasons = append(reasons, reason)
+		}
+		for _, step := range taskRun.Status.Steps {
+			if step.Terminated != nil && step.Terminated.Reason != "" {
+				reasons = append(reasons, step.Terminated.Reason)
+			}
+		}
+	}
+	return reasons, nil
+}
+
+// isFailed checks if the PipelineRun is done and didn't succeed
+func isFailed(pipelineRun *tektonv1.PipelineRun) bool {
+	return pipelineRun.IsDone() && !pipelineRun.Status.GetCondition(apis.ConditionSucceeded).IsTrue()
+}
+
+// attemptOf returns the attempt number of the PipelineRun, the first attempt
+// has no attempt label
+func attemptOf(pipelineRun *tektonv1.PipelineRun) int {
+	attempt, err := strconv.Atoi(pipelineRun.Labels[MintMakerAttemptLabel])
+	if err != nil || attempt < 1 {
+		return 1
+	}
+	return attempt
+}
+
+// retryNameOf returns the name of the PipelineRun retrying a PipelineRun. The
+// name is deterministic, so a PipelineRun is never retried twice.
+func retryNameOf(name string, attempt int) string {
+	return fmt.Sprintf("%s-%d", strings.TrimSuffix(name, fmt.Sprintf("-%d", attempt-1)), attempt)
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *PipelineRunRetryReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		Named("pipelinerun-retry").
+		For(&tektonv1.PipelineRun{}).
+		WithEventFilter(predicate.Funcs{
+			CreateFunc: func(e event.CreateEvent) bool {
+				return false
+			},
+			DeleteFunc: func(e event.DeleteEvent) bool {
+				return false
+			},
+			UpdateFunc: func(e event.UpdateEvent) bool {
+				if e.ObjectNew.GetNamespace() != MintMakerNamespaceName {
+					return false
+				}
+				if oldPipelineRun, ok := e.ObjectOld.(*tektonv1.PipelineRun); ok {
+					if newPipelineRun, ok := e.ObjectNew.(*tektonv1.PipelineRun); ok {
+						return !oldPipelineRun.IsDone() && isFailed(newPipelineRun)
+					}
+				}
+				return false
+			},
+			GenericFunc: func(e event.GenericEvent) bool {
+				return false
+			},
+		}).
+		Complete(r)
+}
@@ -0,0 +1,106 @@
+// Copyright 2024 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controller
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	tektonv1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	ghcomponent "github.com/konflux-ci/mintmaker/internal/pkg/component/github"
+	. "github.com/konflux-ci/mintmaker/internal/pkg/constant"
+)
+
+var _ = Describe("PipelineRun Retry Controller", func() {
+
+	var (
+		origGetRenovateConfig func(registrySecret *corev1.Secret) (string, error)
+		origGetTokenFn        func() (string, error)
+		origGetInstallationID func() (int64, error)
+	)
+
+	componentKey := types.NamespacedName{Name: "retrycomp", Namespace: "testnamespace"}
+	plrName := "renovate-retry"
+	plrLookupKey := types.NamespacedName{Name: plrName, Namespace: MintMakerNamespaceName}
+	retryLookupKey := types.NamespacedName{Name: plrName + "-2", Namespace: MintMakerNamespaceName}
+
+	_ = BeforeEach(func() {
+		createNamespace(MintMakerNamespaceName)
+		createNamespace(componentKey.Namespace)
+		createComponent(componentKey, "app", "https://github.com/retrycomp.git", "gitrevision", "gitsourcecontext")
+
+		origGetRenovateConfig = ghcomponent.GetRenovateConfigFn
+		ghcomponent.GetRenovateConfigFn = func(registrySecret *corev1.Secret) (string, error) {
+			return "mock config", nil
+		}
+		origGetTokenFn = ghcomponent.GetTokenFn
+		ghcomponent.GetTokenFn = func() (string, error) {
+			return "tokenstring", nil
+		}
+		origGetInstallationID = ghcomponent.GetInstallationIDFn
+		ghcomponent.GetInstallationIDFn = func() (int64, error) {
+			return 12345, nil
+		}
+
+		setupPipelineRun(plrName, map[string]string{
+			MintMakerComponentNameLabel:      componentKey.Name,
+			MintMakerComponentNamespaceLabel: componentKey.Namespace,
+			MintMakerPriorityLabel:           "10",
+		}, 0)
+	})
+
+	_ = AfterEach(func() {
+		teardownPipelineRuns()
+		deleteComponent(componentKey)
+		ghcomponent.GetRenovateConfigFn = origGetRenovateConfig
+		ghcomponent.GetTokenFn = origGetTokenFn
+		ghcomponent.GetInstallationIDFn = origGetInstallationID
+	})
+
+	failPipelineRun := func(reason string) {
+		plr := &tektonv1.PipelineRun{}
+		Expect(k8sClient.Get(ctx, plrLookupKey, plr)).To(Succeed())
+		plr.Status.MarkFailed(reason, "%s", "failed")
+		Expect(k8sClient.Status().Update(ctx, plr)).Should(Succeed())
+	}
+
+	It("should retry a PipelineRun which failed for a retryable reason", func() {
+		failPipelineRun("TaskRunImagePullFailed")
+
+		retry := &tektonv1.PipelineRun{}
+		Eventually(func() error {
+			return k8sClient.Get(ctx, retryLookupKey, retry)
+		}, timeout, interval).Should(Succeed())
+		Expect(retry.Labels).To(HaveKeyWithValue(MintMakerAttemptLabel, "2"))
+		Expect(retry.Labels).To(HaveKeyWithValue(MintMakerPriorityLabel, "10"))
+		Expect(retry.Annotations).To(HaveKey(MintMakerNotBeforeAnnotation))
+
+		Eventually(func(g Gomega) {
+			plr := &tektonv1.PipelineRun{}
+			g.Expect(k8sClient.Get(ctx, plrLookupKey, plr)).To(Succeed())
+			g.Expect(plr.Annotations).To(HaveKeyWithValue(MintMakerRetriedByAnnotation, retryLookupKey.Name))
+		}, timeout, interval).Should(Succeed())
+	})
+
+	It("should not retry a PipelineRun which failed for another reason", func() {
+		failPipelineRun(string(tektonv1.PipelineRunReasonFailed))
+
+		Consistently(func() int {
+			return len(listPipelineRuns(MintMakerNamespaceName))
+		}, timeout, interval).Should(Equal(1))
+	})
+})
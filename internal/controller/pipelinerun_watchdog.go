@@ -0,0 +1,146 @@
+// Copyright 2024 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	tektonv1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+	corev1 "k8s.io/api/core/v1"
+	"knative.dev/pkg/apis"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	ctrllog "sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// cancelStuckPipelineRuns cancels the running PipelineRuns which made no
+// progress within the deadline, e.g. because their pod can't be scheduled or
+// a volume can't be mounted. A deadline of 0 disables it. It returns the PipelineRuns still running, so
+// the slots of the cancelled ones can be reused right away.
+func (r *PipelineRunReconciler) cancelStuckPipelineRuns(ctx context.Context, running []tektonv1.PipelineRun, deadline time.Duration) []tektonv1.PipelineRun {
+	log := ctrllog.FromContext(ctx)
+
+	if deadline <= 0 {
+		return running
+	}
+
+	now := time.Now()
+	var stillRunning []tektonv1.PipelineRun
+	for _, run := range running {
+		// Check the PipelineRun only once it could be stuck, to avoid listing
+		// the TaskRuns and pods of every running PipelineRun
+		if run.Status.StartTime == nil || now.Sub(run.Status.StartTime.Time) < deadline {
+			stillRunning = append(stillRunning, run)
+			continue
+		}
+
+		reason, err := r.stuckReason(ctx, &run, now, deadline)
+		if err != nil {
+			log.Error(err, "failed to check if PipelineRun is stuck", "name", run.Name)
+			stillRunning = append(stillRunning, run)
+			continue
+		}
+		if reason == "" {
+			stillRunning = append(stillRunning, run)
+			continue
+		}
+
+		log.Info("cancelling stuck PipelineRun", "name", run.Name, "reason", reason)
+		if err := r.updatePipelineRunState(ctx, run, tektonv1.PipelineRunSpecStatusCancelled, reason); err != nil {
+			stillRunning = append(stillRunning, run)
+		}
+	}
+	return stillRunning
+}
+
+// stuckReason returns why the PipelineRun is stuck, or an empty string if it
+// is making progress
+func (r *PipelineRunReconciler) stuckReason(ctx context.Context, run *tektonv1.PipelineRun, now time.Time, deadline time.Duration) (string, error) {
+	selector := client.MatchingLabels{"tekton.dev/pipelineRun": run.Name}
+
+	taskRuns := &tektonv1.TaskRunList{}
+	if err := r.Client.List(ctx, taskRuns, client.InNamespace(run.Namespace), selector); err != nil {
+		return "", err
+	}
+	pods := &corev1.PodList{}
+	if err := r.Client.List(ctx, pods, client.InNamespace(run.Namespace), selector); err != nil {
+		return "", err
+	}
+
+	return stuckReasonOf(run, taskRuns.Items, pods.Items, now, deadline), nil
+}
+
+// stuckReasonOf returns why the PipelineRun is stuck, given its TaskRuns and
+// pods. A PipelineRun is stuck when none of its pods is running, and neither
+// the PipelineRun nor any of its TaskRuns started or finished within the
+// deadline. Finished pods don't count as progress, their TaskRuns do.
+func stuckReasonOf(run *tektonv1.PipelineRun, taskRuns []tektonv1.TaskRun, pods []corev1.Pod, now time.Time, deadline time.Duration) string {
+	if run.Status.StartTime == nil {
+		return ""
+	}
+	lastProgress := run.Status.StartTime.Time
+	for _, taskRun := range taskRuns {
+		if taskRun.Status.CompletionTime != nil && taskRun.Status.CompletionTime.After(lastProgress) {
+			lastProgress = taskRun.Status.CompletionTime.Time
+		}
+	}
+	for _, pod := range pods {
+		if pod.Status.Phase == corev1.PodRunning {
+			return ""
+		}
+	}
+	stuckFor := now.Sub(lastProgress)
+	if stuckFor < deadline {
+		return ""
+	}
+
+	message := fmt.Sprintf("PipelineRun made no progress for %s", stuckFor.Round(time.Second))
+	for _, pod := range pods {
+		if reason := pendingPodReason(&pod); reason != "" {
+			return fmt.Sprintf("%s: pod %s %s", message, pod.Name, reason)
+		}
+	}
+	for _, taskRun := range taskRuns {
+		condition := taskRun.Status.GetCondition(apis.ConditionSucceeded)
+		if taskRun.IsDone() || condition == nil || condition.GetReason() == "" {
+			continue
+		}
+		return fmt.Sprintf("%s: TaskRun %s is %s: %s", message, taskRun.Name, condition.GetReason(), condition.GetMessage())
+	}
+	if len(pods) > 0 {
+		return fmt.Sprintf("%s: no pod is running", message)
+	}
+	return fmt.Sprintf("%s: no pod has been started", message)
+}
+
+// pendingPodReason describes why a pod is pending, it's empty if the pod
+// doesn't report a reason
+func pendingPodReason(pod *corev1.Pod) string {
+	for _, condition := range pod.Status.Conditions {
+		if condition.Type == corev1.PodScheduled && condition.Status == corev1.ConditionFalse {
+			return fmt.Sprintf("can't be scheduled: %s", condition.Message)
+		}
+	}
+	for _, status := range slices.Concat(pod.Status.InitContainerStatuses, pod.Status.ContainerStatuses) {
+		if status.State.Waiting != nil && status.State.Waiting.Reason != "" {
+			return strings.TrimSpace(fmt.Sprintf("is waiting for container %s: %s %s",
+				status.Name, status.State.Waiting.Reason, status.State.Waiting.Message))
+		}
+	}
+	return ""
+}
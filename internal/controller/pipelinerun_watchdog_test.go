@@ -0,0 +1,110 @@
+// Copyright 2024 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controller
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	tektonv1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"knative.dev/pkg/apis"
+	duckv1 "knative.dev/pkg/apis/duck/v1"
+)
+
+var _ = Describe("PipelineRun watchdog", func() {
+
+	now := time.Now()
+	deadline := 15 * time.Minute
+
+	startedPipelineRun := func(age time.Duration) *tektonv1.PipelineRun {
+		run := &tektonv1.PipelineRun{ObjectMeta: metav1.ObjectMeta{Name: "renovate"}}
+		run.Status.StartTime = &metav1.Time{Time: now.Add(-age)}
+		return run
+	}
+
+	pod := func(phase corev1.PodPhase) corev1.Pod {
+		return corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: "renovate-pod"},
+			Status:     corev1.PodStatus{Phase: phase},
+		}
+	}
+
+	It("should not consider a PipelineRun stuck before the deadline", func() {
+		run := startedPipelineRun(10 * time.Minute)
+		Expect(stuckReasonOf(run, nil, []corev1.Pod{pod(corev1.PodPending)}, now, deadline)).To(BeEmpty())
+	})
+
+	It("should not consider a PipelineRun with a running pod stuck", func() {
+		run := startedPipelineRun(time.Hour)
+		Expect(stuckReasonOf(run, nil, []corev1.Pod{pod(corev1.PodRunning)}, now, deadline)).To(BeEmpty())
+	})
+
+	It("should consider a PipelineRun with only finished pods stuck", func() {
+		run := startedPipelineRun(time.Hour)
+		pods := []corev1.Pod{pod(corev1.PodSucceeded), pod(corev1.PodFailed)}
+		Expect(stuckReasonOf(run, nil, pods, now, deadline)).To(Equal(
+			"PipelineRun made no progress for 1h0m0s: no pod is running"))
+	})
+
+	It("should measure the deadline from the last finished TaskRun", func() {
+		run := startedPipelineRun(time.Hour)
+		taskRun := tektonv1.TaskRun{}
+		taskRun.Status.CompletionTime = &metav1.Time{Time: now.Add(-5 * time.Minute)}
+
+		Expect(stuckReasonOf(run, []tektonv1.TaskRun{taskRun}, nil, now, deadline)).To(BeEmpty())
+	})
+
+	It("should report an unschedulable pod", func() {
+		run := startedPipelineRun(20 * time.Minute)
+		unschedulable := pod(corev1.PodPending)
+		unschedulable.Status.Conditions = []corev1.PodCondition{{
+			Type:    corev1.PodScheduled,
+			Status:  corev1.ConditionFalse,
+			Message: "0/3 nodes are available",
+		}}
+
+		Expect(stuckReasonOf(run, nil, []corev1.Pod{unschedulable}, now, deadline)).To(Equal(
+			"PipelineRun made no progress for 20m0s: pod renovate-pod can't be scheduled: 0/3 nodes are available"))
+	})
+
+	It("should report a waiting container", func() {
+		run := startedPipelineRun(20 * time.Minute)
+		waiting := pod(corev1.PodPending)
+		waiting.Status.ContainerStatuses = []corev1.ContainerStatus{{
+			Name:  "step-renovate",
+			State: corev1.ContainerState{Waiting: &corev1.ContainerStateWaiting{Reason: "ContainerCreating"}},
+		}}
+
+		Expect(stuckReasonOf(run, nil, []corev1.Pod{waiting}, now, deadline)).To(Equal(
+			"PipelineRun made no progress for 20m0s: pod renovate-pod is waiting for container step-renovate: ContainerCreating"))
+	})
+
+	It("should report a pending TaskRun without pod", func() {
+		run := startedPipelineRun(20 * time.Minute)
+		taskRun := tektonv1.TaskRun{ObjectMeta: metav1.ObjectMeta{Name: "renovate-build"}}
+		taskRun.Status.Conditions = duckv1.Conditions{{
+			Type:    apis.ConditionSucceeded,
+			Status:  corev1.ConditionUnknown,
+			Reason:  "ExceededResourceQuota",
+			Message: "quota exceeded",
+		}}
+
+		Expect(stuckReasonOf(run, []tektonv1.TaskRun{taskRun}, nil, now, deadline)).To(Equal(
+			"PipelineRun made no progress for 20m0s: TaskRun renovate-build is ExceededResourceQuota: quota exceeded"))
+	})
+})
@@ -48,10 +48,12 @@ const (
 
 var (
 	k8sClient client.Client
-	testEnv   *envtest.Environment
-	ctx       context.Context
-	cancel    context.CancelFunc
-	log       logr.Logger
+	// cachedClient reads from the cache of the manager, e.g. with its indexes
+	cachedClient client.Client
+	testEnv      *envtest.Environment
+	ctx          context.Context
+	cancel       context.CancelFunc
+	log          logr.Logger
 )
 
 func TestAPIs(t *testing.T) {
@@ -99,16 +101,29 @@ var _ = BeforeSuite(func() {
 
 	k8sManager, err := ctrl.NewManager(cfg, ctrl.Options{
 		Scheme: scheme.Scheme,
+		Cache:  CacheOptions(),
 	})
 	Expect(err).ToNot(HaveOccurred())
+	cachedClient = k8sManager.GetClient()
 
-	Config := config.GetConfig()
-	Expect(Config).NotTo(BeNil())
+	Expect(config.GetConfig()).NotTo(BeNil())
 
-	err = (NewDependencyUpdateCheckReconciler(k8sManager.GetClient(), k8sManager.GetScheme(), Config, k8sManager.GetEventRecorderFor("DependencyUpdateCheckController"))).SetupWithManager(k8sManager)
+	err = SetupIndexes(ctx, k8sManager)
 	Expect(err).ToNot(HaveOccurred())
 
-	err = (&PipelineRunReconciler{Client: k8sManager.GetClient(), Scheme: k8sManager.GetScheme(), Config: Config}).SetupWithManager(k8sManager)
+	err = (NewDependencyUpdateCheckReconciler(k8sManager.GetClient(), k8sManager.GetScheme(), config.GetConfig, k8sManager.GetEventRecorderFor("DependencyUpdateCheckController"))).SetupWithManager(k8sManager)
+	Expect(err).ToNot(HaveOccurred())
+
+	err = (&PipelineRunReconciler{Client: k8sManager.GetClient(), Scheme: k8sManager.GetScheme(), GetConfig: config.GetConfig}).SetupWithManager(k8sManager)
+	Expect(err).ToNot(HaveOccurred())
+
+	err = (&PipelineRunRetryReconciler{Client: k8sManager.GetClient(), Scheme: k8sManager.GetScheme(), GetConfig: config.GetConfig}).SetupWithManager(k8sManager)
+	Expect(err).ToNot(HaveOccurred())
+
+	err = (&PipelineRunResultReconciler{Client: k8sManager.GetClient(), Scheme: k8sManager.GetScheme(), Recorder: k8sManager.GetEventRecorderFor("mintmaker")}).SetupWithManager(k8sManager)
+	Expect(err).ToNot(HaveOccurred())
+
+	err = (&ConfigReconciler{Client: k8sManager.GetClient()}).SetupWithManager(k8sManager)
 	Expect(err).ToNot(HaveOccurred())
 
 	err = (&EventReconciler{Client: k8sManager.GetClient(), Scheme: k8sManager.GetScheme()}).SetupWithManager(k8sManager)
@@ -48,6 +48,11 @@ type BaseComponent struct {
 	// Temporary field to make the implementation easy, it's part of GitURL, so they're duplicated
 	Repository string
 	Branch     string
+	// Annotations of the Component, e.g. to override how its PipelineRuns are run
+	Annotations map[string]string
+	// Name of the component in its application, from its spec, which
+	// DependencyUpdateChecks and RenovateConfigOverrides select
+	ComponentName string
 }
 
 func (c *BaseComponent) GetName() string {
@@ -78,6 +83,10 @@ func (c *BaseComponent) GetRepository() string {
 	return c.Repository
 }
 
+func (c *BaseComponent) GetAnnotations() map[string]string {
+	return c.Annotations
+}
+
 type HostRule map[string]string
 
 func (c *BaseComponent) TransformHostRules(ctx context.Context, registrySecret *corev1.Secret) ([]HostRule, error) {
@@ -127,7 +136,62 @@ func (c *BaseComponent) TransformHostRules(ctx context.Context, registrySecret *
 	return hostRules, nil
 }
 
+// hostRulesConfig converts host rules to config values, so they're merged
+// like the host rules decoded from JSON
+func hostRulesConfig(hostRules []HostRule) []interface{} {
+	rules := make([]interface{}, 0, len(hostRules))
+	for _, hostRule := range hostRules {
+		rule := make(map[string]interface{}, len(hostRule))
+		for key, value := range hostRule {
+			rule[key] = value
+		}
+		rules = append(rules, rule)
+	}
+	return rules
+}
+
+// layerRenovateConfig merges self_hosted.json into renovate.json, then adds
+// the host rules of the registries to the host rules of the config
+func layerRenovateConfig(renovateJSON, selfHostedJSON string, hostRules []HostRule) (map[string]interface{}, error) {
+	var config map[string]interface{}
+	if err := json.Unmarshal([]byte(renovateJSON), &config); err != nil {
+		return nil, fmt.Errorf("error unmarshaling Renovate config: %v", err)
+	}
+	var selfHostedConfig map[string]interface{}
+	if err := json.Unmarshal([]byte(selfHostedJSON), &selfHostedConfig); err != nil {
+		return nil, fmt.Errorf("error unmarshaling Renovate self-hosted config: %v", err)
+	}
+	if config == nil {
+		config = map[string]interface{}{}
+	}
+
+	mergeConfig(config, selfHostedConfig)
+	if len(hostRules) > 0 {
+		mergeConfig(config, map[string]interface{}{"hostRules": hostRulesConfig(hostRules)})
+	}
+	return config, nil
+}
+
+// GetRenovateBaseConfig returns the Renovate config of the component: the
+// config of the renovate-config ConfigMap, with the RenovateConfigOverrides
+// selecting the component merged into it. The returned config is a copy, it
+// can be modified by the caller.
 func (c *BaseComponent) GetRenovateBaseConfig(client client.Client, ctx context.Context, registrySecret *corev1.Secret) (map[string]interface{}, error) {
+	baseConfig, err := c.loadRenovateBaseConfig(client, ctx, registrySecret)
+	if err != nil {
+		return nil, err
+	}
+
+	config := copyConfig(baseConfig)
+	if err := c.applyConfigOverrides(client, ctx, config); err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+// loadRenovateBaseConfig reads the config of the renovate-config ConfigMap,
+// it's read once and shared by all components
+func (c *BaseComponent) loadRenovateBaseConfig(client client.Client, ctx context.Context, registrySecret *corev1.Secret) (map[string]interface{}, error) {
 
 	if renovateBaseConfig != nil {
 		return renovateBaseConfig, nil
@@ -139,25 +203,14 @@ func (c *BaseComponent) GetRenovateBaseConfig(client client.Client, ctx context.
 		return nil, err
 	}
 
-	var config map[string]interface{}
-	if err := json.Unmarshal([]byte(baseConfig.Data["renovate.json"]), &config); err != nil {
-		return nil, fmt.Errorf("error unmarshaling Renovate config: %v", err)
-	}
-	var selfHostedConfig map[string]interface{}
-	if err := json.Unmarshal([]byte(baseConfig.Data["self_hosted.json"]), &selfHostedConfig); err != nil {
-		return nil, fmt.Errorf("error unmarshaling Renovate self-hosted config: %v", err)
-	}
-
-	for k, v := range selfHostedConfig {
-		config[k] = v
-	}
-
+	var hostRules []HostRule
 	if registrySecret != nil {
-		hostRules, err := c.TransformHostRules(ctx, registrySecret)
-
-		if err == nil {
-			config["hostRules"] = hostRules
-		}
+		// The config is still generated without host rules if the secret is invalid
+		hostRules, _ = c.TransformHostRules(ctx, registrySecret)
+	}
+	config, err := layerRenovateConfig(baseConfig.Data["renovate.json"], baseConfig.Data["self_hosted.json"], hostRules)
+	if err != nil {
+		return nil, err
 	}
 
 	renovateBaseConfigMutex.Lock()
@@ -0,0 +1,85 @@
+// Copyright 2024 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package base
+
+// mergeableArrays are the options whose arrays are appended instead of being
+// replaced, as Renovate does when it merges presets and configs. Rules which
+// come later take precedence over the earlier ones.
+var mergeableArrays = map[string]bool{
+	"packageRules":   true,
+	"hostRules":      true,
+	"customManagers": true,
+	"regexManagers":  true,
+	"ignoreDeps":     true,
+	"ignorePaths":    true,
+	"ignorePresets":  true,
+	"addLabels":      true,
+}
+
+// mergeConfig deep-merges a Renovate config into another one, the values of
+// src take precedence over the ones of dst:
+//   - objects are merged recursively, e.g. customEnvVariables
+//   - the arrays of mergeableArrays are appended, e.g. packageRules
+//   - the other values replace the ones of dst, including other arrays such
+//     as schedule or enabledManagers, and null
+//
+// The values of src are copied, so the configs don't share any value.
+func mergeConfig(dst, src map[string]interface{}) {
+	for key, value := range src {
+		switch srcValue := value.(type) {
+		case map[string]interface{}:
+			if dstObject, ok := dst[key].(map[string]interface{}); ok {
+				mergeConfig(dstObject, srcValue)
+				continue
+			}
+		case []interface{}:
+			if dstArray, ok := dst[key].([]interface{}); ok && mergeableArrays[key] {
+				merged := make([]interface{}, 0, len(dstArray)+len(srcValue))
+				merged = append(merged, dstArray...)
+				dst[key] = append(merged, copyValue(srcValue).([]interface{})...)
+				continue
+			}
+		}
+		dst[key] = copyValue(value)
+	}
+}
+
+// copyConfig returns a deep copy of a Renovate config
+func copyConfig(config map[string]interface{}) map[string]interface{} {
+	if config == nil {
+		return nil
+	}
+	return copyValue(config).(map[string]interface{})
+}
+
+// copyValue returns a deep copy of a value decoded from JSON
+func copyValue(value interface{}) interface{} {
+	switch value := value.(type) {
+	case map[string]interface{}:
+		copied := make(map[string]interface{}, len(value))
+		for key, item := range value {
+			copied[key] = copyValue(item)
+		}
+		return copied
+	case []interface{}:
+		copied := make([]interface{}, len(value))
+		for i, item := range value {
+			copied[i] = copyValue(item)
+		}
+		return copied
+	default:
+		return value
+	}
+}
@@ -0,0 +1,195 @@
+package base
+
+import (
+	"encoding/json"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Renovate config merge", func() {
+
+	parse := func(config string) map[string]interface{} {
+		var parsed map[string]interface{}
+		Expect(json.Unmarshal([]byte(config), &parsed)).To(Succeed())
+		return parsed
+	}
+
+	DescribeTable("should merge configs",
+		func(dst, src, expected string) {
+			config := parse(dst)
+			mergeConfig(config, parse(src))
+			Expect(config).To(Equal(parse(expected)))
+		},
+		Entry("into an empty config",
+			`{}`,
+			`{"automerge": true, "packageRules": [{"matchManagers": ["gomod"]}]}`,
+			`{"automerge": true, "packageRules": [{"matchManagers": ["gomod"]}]}`),
+		Entry("from an empty config",
+			`{"automerge": true}`,
+			`{}`,
+			`{"automerge": true}`),
+		Entry("replacing scalars",
+			`{"automerge": true, "prHourlyLimit": 2, "timezone": "UTC", "gitAuthor": "bot"}`,
+			`{"automerge": false, "prHourlyLimit": 0, "timezone": "Europe/Prague"}`,
+			`{"automerge": false, "prHourlyLimit": 0, "timezone": "Europe/Prague", "gitAuthor": "bot"}`),
+		Entry("replacing a value with null",
+			`{"baseBranches": ["main"]}`,
+			`{"baseBranches": null}`,
+			`{"baseBranches": null}`),
+		Entry("merging objects recursively",
+			`{"customEnvVariables": {"GOPROXY": "direct", "GOFLAGS": "-mod=mod"}}`,
+			`{"customEnvVariables": {"GOPROXY": "https://proxy.golang.org", "GONOSUMDB": "*"}}`,
+			`{"customEnvVariables": {"GOPROXY": "https://proxy.golang.org", "GOFLAGS": "-mod=mod", "GONOSUMDB": "*"}}`),
+		Entry("merging nested objects",
+			`{"gomod": {"enabled": true, "postUpdateOptions": ["gomodTidy"], "vulnerabilityAlerts": {"labels": ["security"], "enabled": true}}}`,
+			`{"gomod": {"vulnerabilityAlerts": {"enabled": false}}}`,
+			`{"gomod": {"enabled": true, "postUpdateOptions": ["gomodTidy"], "vulnerabilityAlerts": {"labels": ["security"], "enabled": false}}}`),
+		Entry("appending packageRules",
+			`{"packageRules": [{"matchManagers": ["gomod"], "enabled": false}]}`,
+			`{"packageRules": [{"matchManagers": ["gomod"], "enabled": true}, {"matchUpdateTypes": ["major"], "automerge": false}]}`,
+			`{"packageRules": [{"matchManagers": ["gomod"], "enabled": false}, {"matchManagers": ["gomod"], "enabled": true}, {"matchUpdateTypes": ["major"], "automerge": false}]}`),
+		Entry("appending hostRules",
+			`{"hostRules": [{"matchHost": "quay.io", "hostType": "docker"}]}`,
+			`{"hostRules": [{"matchHost": "registry.redhat.io", "hostType": "docker"}]}`,
+			`{"hostRules": [{"matchHost": "quay.io", "hostType": "docker"}, {"matchHost": "registry.redhat.io", "hostType": "docker"}]}`),
+		Entry("appending the other mergeable arrays",
+			`{"customManagers": [{"fileMatch": ["a"]}], "regexManagers": [{"fileMatch": ["b"]}], "ignoreDeps": ["x"], "ignorePaths": ["vendor/**"], "ignorePresets": [":a"], "addLabels": ["bot"]}`,
+			`{"customManagers": [{"fileMatch": ["c"]}], "regexManagers": [{"fileMatch": ["d"]}], "ignoreDeps": ["y"], "ignorePaths": ["test/**"], "ignorePresets": [":b"], "addLabels": ["deps"]}`,
+			`{"customManagers": [{"fileMatch": ["a"]}, {"fileMatch": ["c"]}], "regexManagers": [{"fileMatch": ["b"]}, {"fileMatch": ["d"]}], "ignoreDeps": ["x", "y"], "ignorePaths": ["vendor/**", "test/**"], "ignorePresets": [":a", ":b"], "addLabels": ["bot", "deps"]}`),
+		Entry("appending mergeable arrays of nested objects",
+			`{"lockFileMaintenance": {"packageRules": [{"enabled": false}]}}`,
+			`{"lockFileMaintenance": {"packageRules": [{"enabled": true}]}}`,
+			`{"lockFileMaintenance": {"packageRules": [{"enabled": false}, {"enabled": true}]}}`),
+		Entry("appending to an empty array",
+			`{"packageRules": []}`,
+			`{"packageRules": [{"enabled": true}]}`,
+			`{"packageRules": [{"enabled": true}]}`),
+		Entry("appending an empty array",
+			`{"packageRules": [{"enabled": true}]}`,
+			`{"packageRules": []}`,
+			`{"packageRules": [{"enabled": true}]}`),
+		Entry("replacing the other arrays",
+			`{"schedule": ["at any time"], "enabledManagers": ["gomod", "dockerfile"], "labels": ["a"], "extends": ["config:recommended"]}`,
+			`{"schedule": ["before 6am"], "enabledManagers": ["tekton"], "labels": ["b"], "extends": [":disableDependencyDashboard"]}`,
+			`{"schedule": ["before 6am"], "enabledManagers": ["tekton"], "labels": ["b"], "extends": [":disableDependencyDashboard"]}`),
+		Entry("replacing an object with a scalar",
+			`{"vulnerabilityAlerts": {"enabled": true}}`,
+			`{"vulnerabilityAlerts": false}`,
+			`{"vulnerabilityAlerts": false}`),
+		Entry("replacing a scalar with an object",
+			`{"vulnerabilityAlerts": false}`,
+			`{"vulnerabilityAlerts": {"enabled": true}}`,
+			`{"vulnerabilityAlerts": {"enabled": true}}`),
+		Entry("replacing a mergeable option which isn't an array",
+			`{"packageRules": null}`,
+			`{"packageRules": [{"enabled": true}]}`,
+			`{"packageRules": [{"enabled": true}]}`),
+		Entry("replacing a mergeable array with a value which isn't an array",
+			`{"ignoreDeps": ["x"]}`,
+			`{"ignoreDeps": null}`,
+			`{"ignoreDeps": null}`),
+	)
+
+	It("should not share values between the configs", func() {
+		base := map[string]interface{}{
+			"force":        map[string]interface{}{"automerge": false},
+			"packageRules": []interface{}{map[string]interface{}{"enabled": true}},
+		}
+		config := copyConfig(base)
+		mergeConfig(config, map[string]interface{}{
+			"force":        map[string]interface{}{"automerge": true},
+			"packageRules": []interface{}{map[string]interface{}{"enabled": false}},
+		})
+		Expect(base).To(Equal(map[string]interface{}{
+			"force":        map[string]interface{}{"automerge": false},
+			"packageRules": []interface{}{map[string]interface{}{"enabled": true}},
+		}))
+
+		override := map[string]interface{}{
+			"labels":       []interface{}{"security"},
+			"packageRules": []interface{}{map[string]interface{}{"automerge": true}},
+		}
+		mergeConfig(config, override)
+		override["labels"].([]interface{})[0] = "changed"
+		override["packageRules"].([]interface{})[0].(map[string]interface{})["automerge"] = false
+		Expect(config["labels"]).To(Equal([]interface{}{"security"}))
+		Expect(config["packageRules"]).To(Equal([]interface{}{
+			map[string]interface{}{"enabled": true},
+			map[string]interface{}{"enabled": false},
+			map[string]interface{}{"automerge": true},
+		}))
+	})
+
+	It("should not modify the source config", func() {
+		src := parse(`{"customEnvVariables": {"A": "1"}, "packageRules": [{"enabled": true}]}`)
+		mergeConfig(parse(`{"customEnvVariables": {"B": "2"}, "packageRules": [{"enabled": false}]}`), src)
+		Expect(src).To(Equal(parse(`{"customEnvVariables": {"A": "1"}, "packageRules": [{"enabled": true}]}`)))
+	})
+
+	Describe("layering the base config", func() {
+
+		It("should deep-merge self_hosted.json into renovate.json", func() {
+			config, err := layerRenovateConfig(
+				`{"extends": ["config:recommended"], "customEnvVariables": {"GOPROXY": "direct"},
+				  "packageRules": [{"matchManagers": ["gomod"], "enabled": false}],
+				  "hostRules": [{"matchHost": "github.com", "hostType": "github"}]}`,
+				`{"onboarding": false, "customEnvVariables": {"GOFLAGS": "-mod=mod"},
+				  "packageRules": [{"matchManagers": ["tekton"], "schedule": ["at any time"]}],
+				  "hostRules": [{"matchHost": "gitlab.com", "hostType": "gitlab"}]}`,
+				nil)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(config).To(Equal(parse(`{
+				"extends": ["config:recommended"],
+				"onboarding": false,
+				"customEnvVariables": {"GOPROXY": "direct", "GOFLAGS": "-mod=mod"},
+				"packageRules": [
+					{"matchManagers": ["gomod"], "enabled": false},
+					{"matchManagers": ["tekton"], "schedule": ["at any time"]}
+				],
+				"hostRules": [
+					{"matchHost": "github.com", "hostType": "github"},
+					{"matchHost": "gitlab.com", "hostType": "gitlab"}
+				]
+			}`)))
+		})
+
+		It("should add the registry host rules after the host rules of the config", func() {
+			config, err := layerRenovateConfig(
+				`{"hostRules": [{"matchHost": "github.com", "hostType": "github"}]}`,
+				`{"hostRules": [{"matchHost": "quay.io", "hostType": "docker", "username": "base"}]}`,
+				[]HostRule{{"matchHost": "quay.io", "hostType": "docker", "username": "user", "password": "pass"}})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(config["hostRules"]).To(Equal([]interface{}{
+				map[string]interface{}{"matchHost": "github.com", "hostType": "github"},
+				map[string]interface{}{"matchHost": "quay.io", "hostType": "docker", "username": "base"},
+				map[string]interface{}{"matchHost": "quay.io", "hostType": "docker", "username": "user", "password": "pass"},
+			}))
+		})
+
+		It("should add the registry host rules to a config without host rules", func() {
+			config, err := layerRenovateConfig(`{}`, `{}`, []HostRule{{"matchHost": "quay.io"}})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(config).To(Equal(map[string]interface{}{
+				"hostRules": []interface{}{map[string]interface{}{"matchHost": "quay.io"}},
+			}))
+
+			config, err = layerRenovateConfig(`{"hostRules": [{"matchHost": "github.com"}]}`, `{}`, nil)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(config["hostRules"]).To(Equal([]interface{}{map[string]interface{}{"matchHost": "github.com"}}))
+		})
+
+		It("should accept an empty renovate.json", func() {
+			config, err := layerRenovateConfig(`null`, `{"onboarding": false}`, nil)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(config).To(Equal(map[string]interface{}{"onboarding": false}))
+		})
+
+		It("should reject invalid configs", func() {
+			_, err := layerRenovateConfig(`{`, `{}`, nil)
+			Expect(err).To(MatchError(ContainSubstring("error unmarshaling Renovate config")))
+			_, err = layerRenovateConfig(`{}`, `[]`, nil)
+			Expect(err).To(MatchError(ContainSubstring("error unmarshaling Renovate self-hosted config")))
+		})
+	})
+})
@@ -0,0 +1,117 @@
+// Copyright 2024 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package base
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	mmv1alpha1 "github.com/konflux-ci/mintmaker/api/v1alpha1"
+	"github.com/konflux-ci/mintmaker/internal/pkg/constant"
+)
+
+// Specificity of the selector of a RenovateConfigOverride, more specific
+// overrides are applied last, so they take precedence
+const (
+	selectsAll = iota
+	selectsNamespace
+	selectsApplication
+	selectsComponent
+)
+
+// selectorSpecificity returns how specifically the selector of an override
+// selects the component, and false if it doesn't select it
+func (c *BaseComponent) selectorSpecificity(namespaces []mmv1alpha1.NamespaceSpec) (int, bool) {
+	if len(namespaces) == 0 {
+		return selectsAll, true
+	}
+	specificity, selected := selectsAll, false
+	for _, namespace := range namespaces {
+		if namespace.Namespace != c.Namespace {
+			continue
+		}
+		if len(namespace.Applications) == 0 {
+			specificity, selected = max(specificity, selectsNamespace), true
+			continue
+		}
+		for _, application := range namespace.Applications {
+			if application.Application != c.Application {
+				continue
+			}
+			if len(application.Components) == 0 {
+				specificity, selected = max(specificity, selectsApplication), true
+				continue
+			}
+			for _, component := range application.Components {
+				if string(component) == c.ComponentName {
+					specificity, selected = selectsComponent, true
+				}
+			}
+		}
+	}
+	return specificity, selected
+}
+
+// selectOverrides returns the overrides which select the component, in the
+// order they're applied: from the least to the most specific, and by name
+func (c *BaseComponent) selectOverrides(overrides []mmv1alpha1.RenovateConfigOverride) []mmv1alpha1.RenovateConfigOverride {
+	type selectedOverride struct {
+		override    mmv1alpha1.RenovateConfigOverride
+		specificity int
+	}
+	var selected []selectedOverride
+	for _, override := range overrides {
+		if specificity, ok := c.selectorSpecificity(override.Spec.Namespaces); ok {
+			selected = append(selected, selectedOverride{override: override, specificity: specificity})
+		}
+	}
+	sort.SliceStable(selected, func(i, j int) bool {
+		if selected[i].specificity != selected[j].specificity {
+			return selected[i].specificity < selected[j].specificity
+		}
+		return selected[i].override.Name < selected[j].override.Name
+	})
+
+	result := make([]mmv1alpha1.RenovateConfigOverride, 0, len(selected))
+	for _, s := range selected {
+		result = append(result, s.override)
+	}
+	return result
+}
+
+// +kubebuilder:rbac:groups=appstudio.redhat.com,resources=renovateconfigoverrides,verbs=get;list;watch
+
+// applyConfigOverrides merges the RenovateConfigOverrides selecting the
+// component into its config. Overrides are policies of the platform, so the
+// config isn't generated without them if they can't be read.
+func (c *BaseComponent) applyConfigOverrides(k8sClient client.Client, ctx context.Context, config map[string]interface{}) error {
+	overrideList := &mmv1alpha1.RenovateConfigOverrideList{}
+	if err := k8sClient.List(ctx, overrideList, client.InNamespace(constant.MintMakerNamespaceName)); err != nil {
+		return fmt.Errorf("failed to list RenovateConfigOverrides: %w", err)
+	}
+
+	for _, override := range c.selectOverrides(overrideList.Items) {
+		var overrideConfig map[string]interface{}
+		if err := json.Unmarshal(override.Spec.Config.Raw, &overrideConfig); err != nil {
+			return fmt.Errorf("invalid config in RenovateConfigOverride %s: %w", override.Name, err)
+		}
+		mergeConfig(config, overrideConfig)
+	}
+	return nil
+}
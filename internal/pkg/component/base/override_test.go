@@ -0,0 +1,86 @@
+package base
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+
+	mmv1alpha1 "github.com/konflux-ci/mintmaker/api/v1alpha1"
+)
+
+var _ = Describe("Renovate config overrides", func() {
+
+	// The name of the Component differs from the name in its spec, which is selected
+	component := &BaseComponent{Name: "comp-7xk2p", ComponentName: "comp", Namespace: "ns", Application: "app"}
+
+	newOverride := func(name, config string, namespaces ...mmv1alpha1.NamespaceSpec) mmv1alpha1.RenovateConfigOverride {
+		return mmv1alpha1.RenovateConfigOverride{
+			ObjectMeta: metav1.ObjectMeta{Name: name},
+			Spec: mmv1alpha1.RenovateConfigOverrideSpec{
+				Namespaces: namespaces,
+				Config:     runtime.RawExtension{Raw: []byte(config)},
+			},
+		}
+	}
+
+	names := func(overrides []mmv1alpha1.RenovateConfigOverride) []string {
+		result := []string{}
+		for _, override := range overrides {
+			result = append(result, override.Name)
+		}
+		return result
+	}
+
+	It("should select the overrides of the component", func() {
+		overrides := []mmv1alpha1.RenovateConfigOverride{
+			newOverride("all", `{}`),
+			newOverride("namespace", `{}`, mmv1alpha1.NamespaceSpec{Namespace: "ns"}),
+			newOverride("other-namespace", `{}`, mmv1alpha1.NamespaceSpec{Namespace: "other"}),
+			newOverride("application", `{}`, mmv1alpha1.NamespaceSpec{
+				Namespace:    "ns",
+				Applications: []mmv1alpha1.ApplicationSpec{{Application: "app"}},
+			}),
+			newOverride("other-application", `{}`, mmv1alpha1.NamespaceSpec{
+				Namespace:    "ns",
+				Applications: []mmv1alpha1.ApplicationSpec{{Application: "other"}},
+			}),
+			newOverride("component", `{}`, mmv1alpha1.NamespaceSpec{
+				Namespace:    "ns",
+				Applications: []mmv1alpha1.ApplicationSpec{{Application: "app", Components: []mmv1alpha1.Component{"comp"}}},
+			}),
+			newOverride("other-component", `{}`, mmv1alpha1.NamespaceSpec{
+				Namespace:    "ns",
+				Applications: []mmv1alpha1.ApplicationSpec{{Application: "app", Components: []mmv1alpha1.Component{"other"}}},
+			}),
+			newOverride("resource-name", `{}`, mmv1alpha1.NamespaceSpec{
+				Namespace:    "ns",
+				Applications: []mmv1alpha1.ApplicationSpec{{Application: "app", Components: []mmv1alpha1.Component{"comp-7xk2p"}}},
+			}),
+		}
+		Expect(names(component.selectOverrides(overrides))).To(Equal([]string{"all", "namespace", "application", "component"}))
+	})
+
+	It("should apply overrides from the least to the most specific, then by name", func() {
+		overrides := []mmv1alpha1.RenovateConfigOverride{
+			newOverride("b-component", `{}`, mmv1alpha1.NamespaceSpec{
+				Namespace:    "ns",
+				Applications: []mmv1alpha1.ApplicationSpec{{Application: "app", Components: []mmv1alpha1.Component{"comp"}}},
+			}),
+			newOverride("a-component", `{}`, mmv1alpha1.NamespaceSpec{
+				Namespace:    "ns",
+				Applications: []mmv1alpha1.ApplicationSpec{{Application: "app", Components: []mmv1alpha1.Component{"comp"}}},
+			}),
+			newOverride("z-all", `{}`),
+			// The most specific selector of the namespace applies
+			newOverride("namespace-and-application", `{}`,
+				mmv1alpha1.NamespaceSpec{Namespace: "other"},
+				mmv1alpha1.NamespaceSpec{Namespace: "ns", Applications: []mmv1alpha1.ApplicationSpec{{Application: "app"}}},
+			),
+			newOverride("namespace", `{}`, mmv1alpha1.NamespaceSpec{Namespace: "ns"}),
+		}
+		Expect(names(component.selectOverrides(overrides))).To(Equal([]string{
+			"z-all", "namespace", "namespace-and-application", "a-component", "b-component",
+		}))
+	})
+})
@@ -36,6 +36,7 @@ type GitComponent interface {
 	GetHost() string
 	GetGitURL() string
 	GetRepository() string
+	GetAnnotations() map[string]string
 	GetToken() (string, error)
 	GetBranch() (string, error)
 	GetAPIEndpoint() string
@@ -43,6 +44,12 @@ type GitComponent interface {
 	GetRPMActivationKey(client.Client, context.Context) (string, string, error)
 }
 
+// AppInstallationComponent is implemented by components whose repository is
+// accessed through an app installation, e.g. GitHub App
+type AppInstallationComponent interface {
+	GetInstallationID() (int64, error)
+}
+
 func NewGitComponent(comp *appstudiov1alpha1.Component, client client.Client, ctx context.Context) (GitComponent, error) {
     // First check if source url exists and is properly defined
     if comp.Spec.Source.GitSource == nil || comp.Spec.Source.GitSource.URL == "" {
@@ -15,6 +15,7 @@
 package github
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
@@ -26,6 +27,7 @@ import (
 
 	ghinstallation "github.com/bradleyfalzon/ghinstallation/v2"
 	"github.com/google/go-github/v45/github"
+	githubv69 "github.com/google/go-github/v69/github"
 	"golang.org/x/oauth2"
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/types"
@@ -35,6 +37,7 @@ import (
 
 	"github.com/konflux-ci/mintmaker/internal/pkg/component/base"
 	"github.com/konflux-ci/mintmaker/internal/pkg/config"
+	"github.com/konflux-ci/mintmaker/internal/pkg/ratelimit"
 	"github.com/konflux-ci/mintmaker/internal/pkg/utils"
 )
 
@@ -50,6 +53,7 @@ var (
 	// vars for mocking purposes, during testing
 	GetRenovateConfigFn func(registrySecret *corev1.Secret) (string, error)
 	GetTokenFn          func() (string, error)
+	GetInstallationIDFn func() (int64, error)
 )
 
 type AppInstallation struct {
@@ -108,14 +112,16 @@ func NewComponent(comp *appstudiov1alpha1.Component, client client.Client, ctx c
 
 	return &Component{
 		BaseComponent: base.BaseComponent{
-			Name:        comp.Name,
-			Namespace:   comp.Namespace,
-			Application: comp.Spec.Application,
-			Platform:    platform,
-			Host:        host,
-			GitURL:      giturl,
-			Repository:  repository,
-			Branch:      comp.Spec.Source.GitSource.Revision,
+			Name:          comp.Name,
+			ComponentName: comp.Spec.ComponentName,
+			Namespace:     comp.Namespace,
+			Application:   comp.Spec.Application,
+			Platform:      platform,
+			Host:          host,
+			GitURL:        giturl,
+			Repository:    repository,
+			Branch:        comp.Spec.Source.GitSource.Revision,
+			Annotations:   comp.Annotations,
 		},
 		AppID:         appID,
 		AppPrivateKey: appPrivateKey,
@@ -136,7 +142,13 @@ func (c *Component) GetBranch() (string, error) {
 	return branch, nil
 }
 
-func (c *Component) getInstallationID() (int64, error) {
+// GetInstallationID returns the ID of the GitHub App installation which has
+// access to the component repository
+func (c *Component) GetInstallationID() (int64, error) {
+	if GetInstallationIDFn != nil {
+		return GetInstallationIDFn()
+	}
+
 	var installationID int64
 	found := false
 
@@ -170,12 +182,13 @@ func (c *Component) GetToken() (string, error) {
 		return GetTokenFn()
 	}
 
-	installationID, err := c.getInstallationID()
+	installationID, err := c.GetInstallationID()
 	if err != nil {
 		return "", fmt.Errorf("failed to get installation ID: %w", err)
 	}
 
-	tokenKey := fmt.Sprintf("installation_%d", installationID)
+	// Tokens are scoped down to a single repository, so they are cached per repository
+	tokenKey := fmt.Sprintf("installation_%d_repository_%s", installationID, c.Repository)
 	cfg := config.GetConfig().GlobalConfig
 	if ghAppInstallationTokenCache.entries == nil {
 		ghAppInstallationTokenCache.entries = make(map[string]TokenInfo)
@@ -195,6 +208,11 @@ func (c *Component) GetToken() (string, error) {
 	if err != nil {
 		return "", fmt.Errorf("error creating installation transport: %w", err)
 	}
+	tokenOptions, err := newInstallationTokenOptions(c.Repository, cfg.GhTokenPermissions)
+	if err != nil {
+		return "", err
+	}
+	itr.InstallationTokenOptions = tokenOptions
 	token, err := itr.Token(context.Background())
 	if err != nil {
 		return "", fmt.Errorf("error getting installation token: %w", err)
@@ -207,6 +225,34 @@ func (c *Component) GetToken() (string, error) {
 	return tokenInfo.Token, nil
 }
 
+// newInstallationTokenOptions restricts an installation token to the given
+// repository and permissions. The repository is in the form of owner/name.
+func newInstallationTokenOptions(repository string, permissions map[string]string) (*githubv69.InstallationTokenOptions, error) {
+	parts := strings.Split(repository, "/")
+	if len(parts) != 2 || parts[1] == "" {
+		return nil, fmt.Errorf("invalid repository format: %s", repository)
+	}
+
+	// InstallationPermissions uses the permission names of the GitHub API as
+	// JSON keys, so the configured permissions can be decoded into it directly.
+	// Unknown names are rejected, the token would be minted without them.
+	data, err := json.Marshal(permissions)
+	if err != nil {
+		return nil, fmt.Errorf("failed to serialize GitHub token permissions: %w", err)
+	}
+	installationPermissions := &githubv69.InstallationPermissions{}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(installationPermissions); err != nil {
+		return nil, fmt.Errorf("failed to parse GitHub token permissions: %w", err)
+	}
+
+	return &githubv69.InstallationTokenOptions{
+		Repositories: []string{parts[1]},
+		Permissions:  installationPermissions,
+	}, nil
+}
+
 func (c *Component) getAppInstallations() ([]AppInstallation, error) {
 	// Initialize the cache if it hasn't been initialized yet
 	ghAppInstallationInitOnce.Do(func() {
@@ -268,7 +314,9 @@ func (c *Component) fetchAppInstallations() ([]AppInstallation, error) {
 				return nil, fmt.Errorf("error creating installation transport: %w", err)
 			}
 
-			installationClient := github.NewClient(&http.Client{Transport: itr})
+			// The token requests of the transport report the quota of the
+			// App, only the API responses report the one of the installation
+			installationClient := github.NewClient(&http.Client{Transport: c.rateLimitTransport(itr, installation.GetID())})
 			repoOpt := &github.ListOptions{PerPage: 100}
 			for {
 				repos, repoResp, err := installationClient.Apps.ListRepos(context.Background(), repoOpt)
@@ -302,10 +350,17 @@ func (c *Component) getDefaultBranch() (string, error) {
 	if err != nil {
 		return "", fmt.Errorf("failed to get GitHub token: %w", err)
 	}
+	installationID, err := c.GetInstallationID()
+	if err != nil {
+		return "", fmt.Errorf("failed to get installation ID: %w", err)
+	}
 	ts := oauth2.StaticTokenSource(
 		&oauth2.Token{AccessToken: token},
 	)
-	tc := oauth2.NewClient(context.Background(), ts)
+	ctx := context.WithValue(context.Background(), oauth2.HTTPClient, &http.Client{
+		Transport: c.rateLimitTransport(http.DefaultTransport, installationID),
+	})
+	tc := oauth2.NewClient(ctx, ts)
 	client := github.NewClient(tc)
 	parts := strings.Split(c.Repository, "/")
 	if len(parts) != 2 {
@@ -323,6 +378,15 @@ func (c *Component) getDefaultBranch() (string, error) {
 	return *repositoryInfo.DefaultBranch, nil
 }
 
+// rateLimitTransport records the API quota of an installation from the
+// responses of GitHub sent through the base transport
+func (c *Component) rateLimitTransport(base http.RoundTripper, installationID int64) http.RoundTripper {
+	return ratelimit.NewTransport(base, ratelimit.Key{
+		Host:         c.Host,
+		Installation: strconv.FormatInt(installationID, 10),
+	})
+}
+
 func (c *Component) GetAPIEndpoint() string {
 	return fmt.Sprintf("https://api.%s/", c.Host)
 }
@@ -0,0 +1,121 @@
+package github
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	ghinstallation "github.com/bradleyfalzon/ghinstallation/v2"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"k8s.io/utils/ptr"
+
+	"github.com/konflux-ci/mintmaker/internal/pkg/component/base"
+	"github.com/konflux-ci/mintmaker/internal/pkg/config"
+	"github.com/konflux-ci/mintmaker/internal/pkg/ratelimit"
+)
+
+var _ = Describe("Installation token options", func() {
+
+	It("should restrict the token to the component repository", func() {
+		opts, err := newInstallationTokenOptions("konflux-ci/mintmaker", map[string]string{"contents": "write"})
+
+		Expect(err).NotTo(HaveOccurred())
+		Expect(opts.Repositories).To(Equal([]string{"mintmaker"}))
+		Expect(opts.RepositoryIDs).To(BeEmpty())
+	})
+
+	It("should request only the configured permissions", func() {
+		opts, err := newInstallationTokenOptions("konflux-ci/mintmaker", map[string]string{
+			"contents":      "write",
+			"pull_requests": "write",
+			"issues":        "read",
+		})
+
+		Expect(err).NotTo(HaveOccurred())
+		Expect(opts.Permissions.Contents).To(Equal(ptr.To("write")))
+		Expect(opts.Permissions.PullRequests).To(Equal(ptr.To("write")))
+		Expect(opts.Permissions.Issues).To(Equal(ptr.To("read")))
+		Expect(opts.Permissions.Workflows).To(BeNil())
+		Expect(opts.Permissions.Administration).To(BeNil())
+	})
+
+	It("should request the default permissions", func() {
+		opts, err := newInstallationTokenOptions("konflux-ci/mintmaker", config.DefaultConfig().GlobalConfig.GhTokenPermissions)
+
+		Expect(err).NotTo(HaveOccurred())
+		Expect(opts.Permissions.Contents).To(Equal(ptr.To("write")))
+		Expect(opts.Permissions.PullRequests).To(Equal(ptr.To("write")))
+		Expect(opts.Permissions.Issues).To(Equal(ptr.To("write")))
+		Expect(opts.Permissions.Workflows).To(Equal(ptr.To("write")))
+	})
+
+	It("should reject unknown permissions", func() {
+		_, err := newInstallationTokenOptions("konflux-ci/mintmaker", map[string]string{"pull-requests": "write"})
+		Expect(err).To(MatchError(ContainSubstring(`unknown field "pull-requests"`)))
+	})
+
+	It("should fail for an invalid repository", func() {
+		_, err := newInstallationTokenOptions("mintmaker", nil)
+		Expect(err).To(HaveOccurred())
+	})
+})
+
+var _ = Describe("Installation rate limits", func() {
+
+	It("should record the quota of the installation and not the one of the token requests", func() {
+		server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			reset := time.Now().Add(time.Hour).Unix()
+			if r.URL.Path == "/app/installations/42/access_tokens" {
+				// The quota of the App, used by the token requests
+				w.Header().Set("X-RateLimit-Limit", "5000")
+				w.Header().Set("X-RateLimit-Remaining", "1")
+				w.Header().Set("X-RateLimit-Reset", fmt.Sprint(reset))
+				w.WriteHeader(http.StatusCreated)
+				fmt.Fprintf(w, `{"token": "installation-token", "expires_at": %q}`, time.Now().Add(time.Hour).Format(time.RFC3339))
+				return
+			}
+			Expect(r.Header.Get("Authorization")).To(Equal("token installation-token"))
+			if r.URL.Path != "/installation/repositories" {
+				fmt.Fprint(w, `{}`)
+				return
+			}
+			w.Header().Set("X-RateLimit-Limit", "5000")
+			w.Header().Set("X-RateLimit-Remaining", "4000")
+			w.Header().Set("X-RateLimit-Reset", fmt.Sprint(reset))
+			fmt.Fprint(w, `{}`)
+		}))
+		defer server.Close()
+
+		key, err := rsa.GenerateKey(rand.Reader, 2048)
+		Expect(err).NotTo(HaveOccurred())
+		privateKey := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+		itr, err := ghinstallation.New(server.Client().Transport, 1, 42, privateKey)
+		Expect(err).NotTo(HaveOccurred())
+		itr.BaseURL = server.URL
+
+		component := &Component{BaseComponent: base.BaseComponent{Host: "ratelimit.github.test"}}
+		client := &http.Client{Transport: component.rateLimitTransport(itr, 42)}
+		get := func(path string) {
+			resp, err := client.Get(server.URL + path)
+			Expect(err).NotTo(HaveOccurred())
+			resp.Body.Close()
+		}
+		rateLimitKey := ratelimit.Key{Host: "ratelimit.github.test", Installation: "42"}
+
+		// The token is requested with the first request, which reports no quota
+		get("/meta")
+		_, ok := ratelimit.DefaultTracker.Get(rateLimitKey)
+		Expect(ok).To(BeFalse())
+
+		get("/installation/repositories")
+		quota, ok := ratelimit.DefaultTracker.Get(rateLimitKey)
+		Expect(ok).To(BeTrue())
+		Expect(quota.Remaining).To(Equal(4000))
+	})
+})
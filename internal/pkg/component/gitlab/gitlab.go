@@ -18,6 +18,7 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"net/http"
 	"net/url"
 	"strings"
 
@@ -29,6 +30,7 @@ import (
 	appstudiov1alpha1 "github.com/konflux-ci/application-api/api/v1alpha1"
 
 	"github.com/konflux-ci/mintmaker/internal/pkg/component/base"
+	"github.com/konflux-ci/mintmaker/internal/pkg/ratelimit"
 	bslices "github.com/konflux-ci/mintmaker/internal/pkg/slices"
 	"github.com/konflux-ci/mintmaker/internal/pkg/utils"
 )
@@ -64,14 +66,16 @@ func NewComponent(comp *appstudiov1alpha1.Component, client client.Client, ctx c
 
 	return &Component{
 		BaseComponent: base.BaseComponent{
-			Name:        comp.Name,
-			Namespace:   comp.Namespace,
-			Application: comp.Spec.Application,
-			Platform:    platform,
-			Host:        host,
-			GitURL:      giturl,
-			Repository:  repository,
-			Branch:      comp.Spec.Source.GitSource.Revision,
+			Name:          comp.Name,
+			ComponentName: comp.Spec.ComponentName,
+			Namespace:     comp.Namespace,
+			Application:   comp.Spec.Application,
+			Platform:      platform,
+			Host:          host,
+			GitURL:        giturl,
+			Repository:    repository,
+			Branch:        comp.Spec.Source.GitSource.Revision,
+			Annotations:   comp.Annotations,
 		},
 		client: client,
 		ctx:    ctx,
@@ -194,7 +198,11 @@ func (c *Component) getDefaultBranch() (string, error) {
 		return "", fmt.Errorf("failed to parse git url: %w", err)
 	}
 	baseUrl := u.Scheme + "://" + c.Host
-	client, _ := gitlab.NewClient(token, gitlab.WithBaseURL(baseUrl))
+	// GitLab tokens aren't tied to an installation, so the quota is tracked per host
+	httpClient := &http.Client{
+		Transport: ratelimit.NewTransport(http.DefaultTransport, ratelimit.Key{Host: c.Host}),
+	}
+	client, _ := gitlab.NewClient(token, gitlab.WithBaseURL(baseUrl), gitlab.WithHTTPClient(httpClient))
 	project, _, err := client.Projects.GetProject(c.Repository, nil)
 	if err != nil {
 		return "", err
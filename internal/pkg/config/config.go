@@ -16,29 +16,174 @@ package config
 
 import (
 	"context"
-	"encoding/json"
-	"strconv"
+	"fmt"
+	"slices"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"github.com/robfig/cron/v3"
+	tektonv1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
 	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
 	"k8s.io/apimachinery/pkg/types"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	ctrllog "sigs.k8s.io/controller-runtime/pkg/log"
 
 	"github.com/konflux-ci/mintmaker/internal/pkg/constant"
+	mintmakermetrics "github.com/konflux-ci/mintmaker/internal/pkg/metrics"
 )
 
 const ConfigMapName = "mintmaker-controller-configmap"
 
 type PipelineRunConfig struct {
 	MaxParallelPipelineruns int
+	// Maximum number of pending PipelineRuns, the PipelineRuns of a DependencyUpdateCheck
+	// are only created once there is room in the queue
+	MaxPendingPipelineruns int
+	// Maximum number of PipelineRuns running in parallel for a single namespace, 0 means no limit
+	MaxParallelPipelinerunsPerNamespace int
+	// Per-namespace overrides of MaxParallelPipelinerunsPerNamespace
+	NamespaceMaxParallelPipelineruns map[string]int
+	// Number of slots which can't be taken by prioritized PipelineRuns, so routine runs aren't starved
+	LowPriorityReservedSlots int
+	// Maximum number of PipelineRuns running in parallel for a git host, e.g. {"github.com": 20}
+	GitHostMaxParallelPipelineruns map[string]int
+	// Maximum number of PipelineRuns running in parallel for a single GitHub App installation, 0 means no limit
+	MaxParallelPipelinerunsPerInstallation int
+	// Pending PipelineRuns are held while fewer API requests are left for their git host or
+	// installation, until the quota is reset. 0 disables holding PipelineRuns.
+	RateLimitMinRemaining int
+	// Period after which the scheduler runs again even if no PipelineRun changed
+	SchedulerResyncPeriod time.Duration
+	RetryPolicy           RetryPolicy
+	// Running PipelineRuns which made no progress for this long are cancelled, 0 disables it
+	StuckDeadline   time.Duration
+	RetentionPolicy RetentionPolicy
+	// Periods in which no PipelineRuns are started, e.g. release freezes or git host maintenance
+	BlackoutWindows []BlackoutWindow
+	// How the PipelineRuns running Renovate are built
+	Renovate RenovateRunConfig
+	// Per-namespace overrides of Renovate, e.g. for namespaces with large repositories
+	NamespaceRenovate map[string]RenovateRunConfig
+	// Maximums of the resources and timeout which the annotations of a component can set
+	AnnotationMaximums AnnotationMaximums
+	// Scheduling constraints of the Renovate pods, e.g. to run them on a dedicated node pool
+	PodTemplate PodTemplateConfig
+	// Pipeline run instead of the embedded one, e.g. a Tekton bundle. Only the
+	// resources and the timeout of Renovate apply to a referenced pipeline.
+	PipelineRef *tektonv1.PipelineRef
+	// Task of the referenced pipeline running Renovate, which gets its resources
+	PipelineRefTask string
+	// Checks created when the OSV database gets new advisories
+	VulnerabilityFastLane VulnerabilityFastLane
+}
+
+// PodTemplateConfig holds the scheduling constraints of the Renovate pods
+type PodTemplateConfig struct {
+	NodeSelector map[string]string
+	Tolerations  []corev1.Toleration
+	Affinity     *corev1.Affinity
+}
+
+// BlackoutWindow is a recurring period in which pending PipelineRuns are not started
+type BlackoutWindow struct {
+	Name string
+	// Cron expression of the window starts, and its parsed schedule
+	Spec     string
+	Schedule cron.Schedule
+	Duration time.Duration
+	// Git hosts and component namespaces the window applies to, all of them if empty
+	Hosts      []string
+	Namespaces []string
+}
+
+// ActiveAt checks if the window is active at the given time, and returns when it ends
+func (w *BlackoutWindow) ActiveAt(now time.Time) (bool, time.Time) {
+	// The window is active if it started within its duration before now. When
+	// it started several times, e.g. a window longer than its period, it ends
+	// a duration after the latest start.
+	var start time.Time
+	for next := w.Schedule.Next(now.Add(-w.Duration)); !next.IsZero() && !next.After(now); next = w.Schedule.Next(next) {
+		start = next
+	}
+	if start.IsZero() {
+		return false, time.Time{}
+	}
+	return true, start.Add(w.Duration)
+}
+
+// AppliesTo checks if the window applies to PipelineRuns of the git host and namespace
+func (w *BlackoutWindow) AppliesTo(host, namespace string) bool {
+	return (len(w.Hosts) == 0 || slices.Contains(w.Hosts, host)) &&
+		(len(w.Namespaces) == 0 || slices.Contains(w.Namespaces, namespace))
+}
+
+// RetentionPolicy defines which completed PipelineRuns are kept, the other
+// ones are deleted together with the Secrets and ConfigMaps they own
+type RetentionPolicy struct {
+	// Number of completed PipelineRuns kept for each repository, at least 1 so
+	// the last PipelineRun isn't deleted before its results are read
+	KeepLastPerRepository int
+	// Failed PipelineRuns are kept at least this long, so they can be investigated
+	KeepFailedFor time.Duration
+	// How often completed PipelineRuns are garbage collected
+	Interval time.Duration
+}
+
+// VulnerabilityFastLane defines how the components affected by new advisories
+// are checked outside of the regular schedule. The digest of the OSV database
+// image is watched, and when it changes a DependencyUpdateCheck is created for
+// the components with pending updates of the images covered by the new
+// advisories.
+type VulnerabilityFastLane struct {
+	// How often the digest of the OSV database image is checked, 0 disables the fast lane
+	Interval time.Duration
+	// Priority of the created DependencyUpdateChecks
+	Priority int
+	// Registries of the images whose advisories trigger a check
+	Registries []string
+}
+
+// RetryPolicy defines how failed PipelineRuns are retried
+type RetryPolicy struct {
+	// Maximum number of attempts for a component, including the first one, 1 disables retries
+	MaxAttempts int
+	// Backoff before the first retry, it's doubled for each further retry
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+	// Failure reasons of the PipelineRun, its TaskRuns or their steps which are retried
+	RetryableReasons []string
+}
+
+// Backoff returns the delay before the given attempt is started
+func (p *RetryPolicy) Backoff(attempt int) time.Duration {
+	backoff := p.InitialBackoff
+	for i := 2; i < attempt && backoff < p.MaxBackoff; i++ {
+		backoff *= 2
+	}
+	return min(backoff, p.MaxBackoff)
+}
+
+// IsRetryable checks if any of the failure reasons is retryable
+func (p *RetryPolicy) IsRetryable(reasons []string) bool {
+	for _, reason := range reasons {
+		if slices.Contains(p.RetryableReasons, reason) {
+			return true
+		}
+	}
+	return false
 }
 
 type GlobalConfig struct {
 	GhTokenValidity       time.Duration
 	GhTokenUsageWindow    time.Duration
 	GhTokenRenewThreshold time.Duration
+	// Permissions requested for GitHub installation tokens, e.g. {"contents": "write"}
+	GhTokenPermissions map[string]string
 }
 
 type ControllerConfig struct {
@@ -46,7 +191,9 @@ type ControllerConfig struct {
 	PipelineRunConfig PipelineRunConfig
 }
 
-var globalConfig *ControllerConfig
+// globalConfig is swapped as a whole when the ConfigMap changes, a loaded
+// config is never modified
+var globalConfig atomic.Pointer[ControllerConfig]
 var once sync.Once
 
 func DefaultConfig() *ControllerConfig {
@@ -54,32 +201,58 @@ func DefaultConfig() *ControllerConfig {
 	GhTokenUsageWindow := 30 * time.Minute
 
 	return &ControllerConfig{
-		PipelineRunConfig: PipelineRunConfig{MaxParallelPipelineruns: 40},
+		PipelineRunConfig: PipelineRunConfig{
+			MaxParallelPipelineruns: 40,
+			MaxPendingPipelineruns:  100,
+			RateLimitMinRemaining:   100,
+			SchedulerResyncPeriod:   time.Minute,
+			StuckDeadline:           15 * time.Minute,
+			Renovate:                defaultRenovateRunConfig(),
+			AnnotationMaximums:      defaultAnnotationMaximums(),
+			RetentionPolicy: RetentionPolicy{
+				KeepLastPerRepository: 3,
+				KeepFailedFor:         7 * 24 * time.Hour,
+				Interval:              10 * time.Minute,
+			},
+			PipelineRefTask: "build",
+			VulnerabilityFastLane: VulnerabilityFastLane{
+				Priority:   900,
+				Registries: []string{"registry.access.redhat.com", "registry.redhat.io"},
+			},
+			RetryPolicy: RetryPolicy{
+				MaxAttempts:    3,
+				InitialBackoff: 5 * time.Minute,
+				MaxBackoff:     time.Hour,
+				RetryableReasons: []string{
+					"TaskRunImagePullFailed",
+					"CreateContainerConfigError",
+					"PodCreationFailed",
+					"OOMKilled",
+					"Evicted",
+				},
+			},
+		},
 
 		GlobalConfig: GlobalConfig{
 			GhTokenValidity:       GhTokenValidity,
 			GhTokenUsageWindow:    GhTokenUsageWindow,
 			GhTokenRenewThreshold: GhTokenValidity - GhTokenUsageWindow,
+			GhTokenPermissions: map[string]string{
+				"contents":      "write",
+				"pull_requests": "write",
+				"issues":        "write",
+				"workflows":     "write",
+			},
 		},
 	}
 
 }
 
-func LoadConfig(ctx context.Context, client client.Reader) *ControllerConfig {
+// LoadConfig reads the config from the ConfigMap. The default config is
+// returned if the ConfigMap doesn't exist, an invalid config is rejected as a
+// whole and returned as ValidationErrors.
+func LoadConfig(ctx context.Context, client client.Reader) (*ControllerConfig, error) {
 	log := ctrllog.FromContext(ctx).WithName("ConfigLoader")
-	var configReader struct {
-		Global struct {
-			GhTokenValidity    string `json:"github-token-validity"`
-			GhTokenUsageWindow string `json:"github-token-usage-window"`
-		} `json:"global"`
-
-		PipelineRun struct {
-			MaxParallelPipelineruns string `json:"max-parallel-pipelineruns"`
-		} `json:"pipelinerun"`
-	}
-
-	defaultConfig := DefaultConfig()
-	config := &ControllerConfig{}
 
 	configMap := &corev1.ConfigMap{}
 	err := client.Get(ctx, types.NamespacedName{
@@ -87,58 +260,78 @@ func LoadConfig(ctx context.Context, client client.Reader) *ControllerConfig {
 		Name:      ConfigMapName,
 	}, configMap)
 	if err != nil {
-		log.Error(err, "ConfigMap not found, using default configuration", "configMap", ConfigMapName)
-		return defaultConfig
+		if apierrors.IsNotFound(err) {
+			log.Info("ConfigMap not found, using default configuration", "configMap", ConfigMapName)
+			return DefaultConfig(), nil
+		}
+		return nil, fmt.Errorf("failed to get ConfigMap %s: %w", ConfigMapName, err)
 	}
 
-	if err := json.Unmarshal([]byte(configMap.Data["config.json"]), &configReader); err != nil {
-		log.Error(err, "Could not unmarshal configuration, using default configuration", "configMap", ConfigMapName)
-		return defaultConfig
-	}
+	return Parse([]byte(configMap.Data["config.json"]))
+}
 
-	if parsed, err := strconv.Atoi(configReader.PipelineRun.MaxParallelPipelineruns); err == nil && parsed > 0 {
-		config.PipelineRunConfig.MaxParallelPipelineruns = parsed
-	} else {
-		config.PipelineRunConfig.MaxParallelPipelineruns = defaultConfig.PipelineRunConfig.MaxParallelPipelineruns
-	}
+// InitGlobalConfig loads the config when the controller starts. The default
+// config is used if the ConfigMap holds an invalid config.
+func InitGlobalConfig(ctx context.Context, client client.Reader) {
+	once.Do(func() {
+		log := ctrllog.FromContext(ctx).WithName("ConfigLoader")
 
-	if parsed, err := time.ParseDuration(configReader.Global.GhTokenValidity); err == nil && parsed > 0 {
-		config.GlobalConfig.GhTokenValidity = parsed
-	} else {
-		config.GlobalConfig.GhTokenValidity = defaultConfig.GlobalConfig.GhTokenValidity
-	}
+		config, err := LoadConfig(ctx, client)
+		mintmakermetrics.SetConfigAccepted(err == nil)
+		if err != nil {
+			log.Error(err, "Configuration rejected, using default configuration", "configMap", ConfigMapName)
+			config = DefaultConfig()
+		}
+		globalConfig.Store(config)
+	})
+}
 
-	if parsed, err := time.ParseDuration(configReader.Global.GhTokenUsageWindow); err == nil && parsed > 0 {
-		config.GlobalConfig.GhTokenUsageWindow = parsed
-	} else {
-		config.GlobalConfig.GhTokenUsageWindow = defaultConfig.GlobalConfig.GhTokenUsageWindow
-	}
+// ReloadGlobalConfig loads the config again and swaps it with the current one.
+// The differences between both configs are logged. An invalid config is
+// rejected and the current one is kept.
+func ReloadGlobalConfig(ctx context.Context, client client.Reader) (*ControllerConfig, error) {
+	log := ctrllog.FromContext(ctx).WithName("ConfigLoader")
 
-	if config.GlobalConfig.GhTokenUsageWindow >= config.GlobalConfig.GhTokenValidity {
-		config.GlobalConfig.GhTokenValidity = defaultConfig.GlobalConfig.GhTokenValidity
-		config.GlobalConfig.GhTokenUsageWindow = defaultConfig.GlobalConfig.GhTokenUsageWindow
-		log.Error(err, "Invalid value for GitHub token usage window, using default",
-			"default", defaultConfig.GlobalConfig.GhTokenUsageWindow)
+	config, err := LoadConfig(ctx, client)
+	mintmakermetrics.SetConfigAccepted(err == nil)
+	if err != nil {
+		log.Error(err, "Configuration rejected, keeping the current configuration", "configMap", ConfigMapName)
+		return GetConfig(), err
 	}
 
-	config.GlobalConfig.GhTokenRenewThreshold = config.GlobalConfig.GhTokenValidity - config.GlobalConfig.GhTokenUsageWindow
+	previous := globalConfig.Swap(config)
+	if previous == nil {
+		previous = DefaultConfig()
+	}
 
-	return config
+	if diff := Diff(previous, config); diff != "" {
+		log.Info("Reloaded configuration", "configMap", ConfigMapName, "diff", diff)
+	} else {
+		log.Info("Reloaded configuration, nothing changed", "configMap", ConfigMapName)
+	}
+	return config, nil
 }
 
-// Will not return empty configs but error for logging purposses
-func InitGlobalConfig(ctx context.Context, client client.Reader) {
-	once.Do(func() {
-		config := LoadConfig(ctx, client)
-		globalConfig = config
-	})
+// Diff returns the differences between two configs, it's empty if they are equal
+func Diff(previous, current *ControllerConfig) string {
+	// Schedules are compared by their cron expression
+	return cmp.Diff(previous, current,
+		cmpopts.IgnoreFields(BlackoutWindow{}, "Schedule"),
+		cmp.Comparer(func(a, b resource.Quantity) bool { return a.Cmp(b) == 0 }))
 }
 
+// Getter returns the current config, e.g. GetConfig. The config changes when
+// its ConfigMap is updated, so the controllers call it for each
+// reconciliation instead of keeping the config.
+type Getter func() *ControllerConfig
+
+// GetConfig returns the current config. Callers should get it again for each
+// reconciliation, so changes of the ConfigMap are picked up.
 func GetConfig() *ControllerConfig {
-	if globalConfig == nil {
-		return DefaultConfig()
+	if config := globalConfig.Load(); config != nil {
+		return config
 	}
-	return globalConfig
+	return DefaultConfig()
 }
 
 // Get testing config
@@ -0,0 +1,46 @@
+package config
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/robfig/cron/v3"
+)
+
+var _ = Describe("Blackout window", func() {
+
+	now := time.Date(2024, 3, 7, 12, 30, 0, 0, time.UTC)
+
+	newWindow := func(spec string, duration time.Duration) *BlackoutWindow {
+		schedule, err := cron.ParseStandard(spec)
+		Expect(err).NotTo(HaveOccurred())
+		return &BlackoutWindow{Name: "freeze", Spec: spec, Schedule: schedule, Duration: duration}
+	}
+
+	It("should be active within its duration after a start", func() {
+		active, until := newWindow("0 12 * * *", time.Hour).ActiveAt(now)
+		Expect(active).To(BeTrue())
+		Expect(until).To(Equal(time.Date(2024, 3, 7, 13, 0, 0, 0, time.UTC)))
+	})
+
+	It("should not be active after its duration", func() {
+		active, _ := newWindow("0 12 * * *", 30*time.Minute).ActiveAt(now)
+		Expect(active).To(BeFalse())
+		active, _ = newWindow("0 13 * * *", time.Hour).ActiveAt(now)
+		Expect(active).To(BeFalse())
+	})
+
+	It("should end a duration after the latest start", func() {
+		// Started at 10:00, 11:00 and 12:00
+		active, until := newWindow("0 * * * *", 3*time.Hour).ActiveAt(now)
+		Expect(active).To(BeTrue())
+		Expect(until).To(Equal(time.Date(2024, 3, 7, 15, 0, 0, 0, time.UTC)))
+	})
+
+	It("should be active from its start", func() {
+		active, until := newWindow("30 12 * * *", time.Hour).ActiveAt(now)
+		Expect(active).To(BeTrue())
+		Expect(until).To(Equal(time.Date(2024, 3, 7, 13, 30, 0, 0, time.UTC)))
+	})
+})
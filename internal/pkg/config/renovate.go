@@ -0,0 +1,230 @@
+// Copyright 2024 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"os"
+	"slices"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+
+	"github.com/konflux-ci/mintmaker/internal/pkg/constant"
+)
+
+// renovateLogLevels are the log levels supported by Renovate
+var renovateLogLevels = []string{"trace", "debug", "info", "warn", "error", "fatal"}
+
+// RenovateRunConfig is how the PipelineRuns running Renovate are built. In
+// overrides, only the fields which are set replace the defaults.
+type RenovateRunConfig struct {
+	Image            string
+	OSVDatabaseImage string
+	RPMCertImage     string
+	// Resources of the renovate step
+	Resources corev1.ResourceRequirements
+	LogLevel  string
+	Timeout   time.Duration
+}
+
+func defaultRenovateRunConfig() RenovateRunConfig {
+	image := os.Getenv(constant.RenovateImageEnvName)
+	if image == "" {
+		image = constant.DefaultRenovateImageURL
+	}
+	return RenovateRunConfig{
+		Image:            image,
+		OSVDatabaseImage: constant.DefaultOSVDatabaseImageURL,
+		RPMCertImage:     constant.DefaultRPMCertImageURL,
+		Resources: corev1.ResourceRequirements{
+			Requests: corev1.ResourceList{
+				corev1.ResourceCPU:    resource.MustParse("150m"),
+				corev1.ResourceMemory: resource.MustParse("512Mi"),
+			},
+			Limits: corev1.ResourceList{
+				corev1.ResourceCPU:    resource.MustParse("300m"),
+				corev1.ResourceMemory: resource.MustParse("2.5Gi"),
+			},
+		},
+		LogLevel: "debug",
+		Timeout:  time.Hour,
+	}
+}
+
+// AnnotationMaximums are the greatest resources and timeout which the
+// annotations of a component can set. The annotations are set by the owners
+// of the components, the maximums by the admins. Unset maximums don't apply.
+type AnnotationMaximums struct {
+	// Maximum of both the request and the limit of each resource
+	Resources corev1.ResourceList
+	Timeout   time.Duration
+}
+
+func defaultAnnotationMaximums() AnnotationMaximums {
+	return AnnotationMaximums{
+		Resources: corev1.ResourceList{
+			corev1.ResourceCPU:    resource.MustParse("4"),
+			corev1.ResourceMemory: resource.MustParse("16Gi"),
+		},
+		Timeout: 6 * time.Hour,
+	}
+}
+
+// Override returns the config with the fields set in the override replaced
+func (c RenovateRunConfig) Override(override RenovateRunConfig) RenovateRunConfig {
+	if override.Image != "" {
+		c.Image = override.Image
+	}
+	if override.OSVDatabaseImage != "" {
+		c.OSVDatabaseImage = override.OSVDatabaseImage
+	}
+	if override.RPMCertImage != "" {
+		c.RPMCertImage = override.RPMCertImage
+	}
+	c.Resources = corev1.ResourceRequirements{
+		Requests: mergeResources(c.Resources.Requests, override.Resources.Requests),
+		Limits:   mergeResources(c.Resources.Limits, override.Resources.Limits),
+	}
+	if override.LogLevel != "" {
+		c.LogLevel = override.LogLevel
+	}
+	if override.Timeout != 0 {
+		c.Timeout = override.Timeout
+	}
+	return c
+}
+
+// mergeResources returns a copy of the resources, with the overridden ones replaced
+func mergeResources(resources, overrides corev1.ResourceList) corev1.ResourceList {
+	merged := resources.DeepCopy()
+	if merged == nil && len(overrides) > 0 {
+		merged = corev1.ResourceList{}
+	}
+	for name, quantity := range overrides {
+		merged[name] = quantity.DeepCopy()
+	}
+	return merged
+}
+
+// RenovateRunFor returns how the PipelineRuns of a component are run: the
+// default config, overridden by the config of its namespace and then by the
+// annotations of the component. Images can't be overridden by annotations,
+// as they run with the secrets of the PipelineRun.
+// Invalid annotations, and the ones greater than the annotation maximums, are
+// ignored and returned as ValidationErrors.
+func (c *PipelineRunConfig) RenovateRunFor(namespace string, annotations map[string]string) (RenovateRunConfig, error) {
+	run := c.Renovate
+	if override, ok := c.NamespaceRenovate[namespace]; ok {
+		run = run.Override(override)
+	}
+
+	v := &validator{}
+	requests := map[corev1.ResourceName]fieldValue{
+		corev1.ResourceCPU:    annotationValue(annotations, constant.MintMakerCPURequestAnnotation),
+		corev1.ResourceMemory: annotationValue(annotations, constant.MintMakerMemoryRequestAnnotation),
+	}
+	limits := map[corev1.ResourceName]fieldValue{
+		corev1.ResourceCPU:    annotationValue(annotations, constant.MintMakerCPULimitAnnotation),
+		corev1.ResourceMemory: annotationValue(annotations, constant.MintMakerMemoryLimitAnnotation),
+	}
+	override := RenovateRunConfig{
+		Resources: corev1.ResourceRequirements{
+			Requests: v.resources(requests),
+			Limits:   v.resources(limits),
+		},
+		LogLevel: v.logLevel(annotationValue(annotations, constant.MintMakerLogLevelAnnotation)),
+		Timeout: v.duration(constant.MintMakerTimeoutAnnotation,
+			Value(annotations[constant.MintMakerTimeoutAnnotation]), time.Minute, 0),
+	}
+	v.maximumResources(override.Resources.Requests, requests, c.AnnotationMaximums.Resources)
+	v.maximumResources(override.Resources.Limits, limits, c.AnnotationMaximums.Resources)
+	if maximum := c.AnnotationMaximums.Timeout; maximum > 0 && override.Timeout > maximum {
+		v.fail(constant.MintMakerTimeoutAnnotation, Value(annotations[constant.MintMakerTimeoutAnnotation]),
+			"must be at most "+maximum.String())
+		override.Timeout = 0
+	}
+	// The resources of the annotations are ignored if a request would be
+	// greater than its limit, as the pod couldn't be created
+	invalid := len(v.errors)
+	v.requestsWithinLimits("annotations", run.Override(override).Resources)
+	if len(v.errors) > invalid {
+		override.Resources = corev1.ResourceRequirements{}
+	}
+
+	return run.Override(override), v.err()
+}
+
+// fieldValue is a value together with the path of its field
+type fieldValue struct {
+	field string
+	value Value
+}
+
+func annotationValue(annotations map[string]string, annotation string) fieldValue {
+	return fieldValue{field: annotation, value: Value(annotations[annotation])}
+}
+
+// resources parses resource quantities, the ones which aren't set are skipped
+func (v *validator) resources(values map[corev1.ResourceName]fieldValue) corev1.ResourceList {
+	var resources corev1.ResourceList
+	for name, fv := range values {
+		if fv.value == "" {
+			continue
+		}
+		quantity, err := resource.ParseQuantity(string(fv.value))
+		if err != nil || quantity.Sign() <= 0 {
+			v.fail(fv.field, fv.value, "must be a positive quantity, e.g. 500m or 2Gi")
+			continue
+		}
+		if resources == nil {
+			resources = corev1.ResourceList{}
+		}
+		resources[name] = quantity
+	}
+	return resources
+}
+
+// maximumResources removes the resources greater than their maximum
+func (v *validator) maximumResources(resources corev1.ResourceList, values map[corev1.ResourceName]fieldValue, maximums corev1.ResourceList) {
+	for name, quantity := range resources {
+		if maximum, ok := maximums[name]; ok && quantity.Cmp(maximum) > 0 {
+			v.fail(values[name].field, values[name].value, "must be at most "+maximum.String())
+			delete(resources, name)
+		}
+	}
+}
+
+// logLevel checks the value is a Renovate log level
+func (v *validator) logLevel(fv fieldValue) string {
+	if fv.value == "" {
+		return ""
+	}
+	if !slices.Contains(renovateLogLevels, string(fv.value)) {
+		v.fail(fv.field, fv.value, "must be one of trace, debug, info, warn, error or fatal")
+		return ""
+	}
+	return string(fv.value)
+}
+
+// requestsWithinLimits checks no resource request is greater than its limit
+func (v *validator) requestsWithinLimits(field string, resources corev1.ResourceRequirements) {
+	for name, request := range resources.Requests {
+		if limit, ok := resources.Limits[name]; ok && request.Cmp(limit) > 0 {
+			v.fail(field, Value(request.String()),
+				"the "+string(name)+" request must not be greater than its limit "+limit.String())
+		}
+	}
+}
@@ -0,0 +1,114 @@
+package config
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+
+	. "github.com/konflux-ci/mintmaker/internal/pkg/constant"
+)
+
+var _ = Describe("Renovate run config", func() {
+
+	var cfg *ControllerConfig
+
+	BeforeEach(func() {
+		var err error
+		cfg, err = Parse([]byte(`{
+			"pipelinerun": {
+				"renovate": {"memory-limit": "3Gi", "log-level": "info", "timeout": "90m"},
+				"namespace-renovate": {"monorepos": {"memory-limit": "8Gi", "timeout": "3h"}}
+			}
+		}`))
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	It("should use the configured values", func() {
+		run, err := cfg.PipelineRunConfig.RenovateRunFor("other", nil)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(run.Image).To(Equal(DefaultRenovateImageURL))
+		Expect(run.LogLevel).To(Equal("info"))
+		Expect(run.Timeout).To(Equal(90 * time.Minute))
+		Expect(run.Resources.Limits.Memory().Equal(resource.MustParse("3Gi"))).To(BeTrue())
+		// Resources which aren't configured keep their default
+		Expect(run.Resources.Requests.Memory().Equal(resource.MustParse("512Mi"))).To(BeTrue())
+	})
+
+	It("should apply the namespace overrides", func() {
+		run, err := cfg.PipelineRunConfig.RenovateRunFor("monorepos", nil)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(run.Timeout).To(Equal(3 * time.Hour))
+		Expect(run.LogLevel).To(Equal("info"))
+		Expect(run.Resources.Limits.Memory().Equal(resource.MustParse("8Gi"))).To(BeTrue())
+	})
+
+	It("should apply the component annotations over the namespace overrides", func() {
+		run, err := cfg.PipelineRunConfig.RenovateRunFor("monorepos", map[string]string{
+			MintMakerMemoryLimitAnnotation: "12Gi",
+			MintMakerCPURequestAnnotation:  "50m",
+			MintMakerLogLevelAnnotation:    "trace",
+		})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(run.LogLevel).To(Equal("trace"))
+		Expect(run.Timeout).To(Equal(3 * time.Hour))
+		Expect(run.Resources.Limits.Memory().Equal(resource.MustParse("12Gi"))).To(BeTrue())
+		Expect(run.Resources.Requests.Cpu().Equal(resource.MustParse("50m"))).To(BeTrue())
+		// The config isn't modified by the overrides
+		Expect(cfg.PipelineRunConfig.Renovate.Resources.Requests.Cpu().Equal(resource.MustParse("150m"))).To(BeTrue())
+	})
+
+	It("should ignore invalid annotations", func() {
+		run, err := cfg.PipelineRunConfig.RenovateRunFor("other", map[string]string{
+			MintMakerTimeoutAnnotation:       "forever",
+			MintMakerMemoryRequestAnnotation: "4Gi",
+		})
+		Expect(err).To(HaveOccurred())
+		Expect(run.Timeout).To(Equal(90 * time.Minute))
+		Expect(run.Resources.Requests[corev1.ResourceMemory].Equal(resource.MustParse("512Mi"))).To(BeTrue())
+	})
+
+	It("should ignore annotations greater than the annotation maximums", func() {
+		run, err := cfg.PipelineRunConfig.RenovateRunFor("monorepos", map[string]string{
+			MintMakerCPULimitAnnotation:      "8",
+			MintMakerMemoryRequestAnnotation: "64Gi",
+			MintMakerMemoryLimitAnnotation:   "64Gi",
+			MintMakerTimeoutAnnotation:       "24h",
+			MintMakerLogLevelAnnotation:      "trace",
+		})
+		Expect(err).To(MatchError(And(
+			ContainSubstring(MintMakerCPULimitAnnotation+": must be at most 4"),
+			ContainSubstring(MintMakerMemoryRequestAnnotation+": must be at most 16Gi"),
+			ContainSubstring(MintMakerMemoryLimitAnnotation+": must be at most 16Gi"),
+			ContainSubstring(MintMakerTimeoutAnnotation+": must be at most 6h0m0s"),
+		)))
+		// The namespace overrides apply instead
+		Expect(run.Timeout).To(Equal(3 * time.Hour))
+		Expect(run.Resources.Limits.Memory().Equal(resource.MustParse("8Gi"))).To(BeTrue())
+		Expect(run.Resources.Limits.Cpu().Equal(resource.MustParse("300m"))).To(BeTrue())
+		Expect(run.Resources.Requests.Memory().Equal(resource.MustParse("512Mi"))).To(BeTrue())
+		// The annotations within the maximums still apply
+		Expect(run.LogLevel).To(Equal("trace"))
+	})
+
+	It("should not cap the namespace overrides set by the admins", func() {
+		cfg, err := Parse([]byte(`{
+			"pipelinerun": {
+				"namespace-renovate": {"monorepos": {"memory-limit": "24Gi", "timeout": "8h"}},
+				"annotation-maximums": {"memory": "12Gi", "timeout": "4h"}
+			}
+		}`))
+		Expect(err).NotTo(HaveOccurred())
+		run, err := cfg.PipelineRunConfig.RenovateRunFor("monorepos", nil)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(run.Timeout).To(Equal(8 * time.Hour))
+		Expect(run.Resources.Limits.Memory().Equal(resource.MustParse("24Gi"))).To(BeTrue())
+	})
+
+	It("should reject requests greater than limits", func() {
+		_, err := Parse([]byte(`{"pipelinerun": {"renovate": {"cpu-request": "2", "cpu-limit": "1"}}}`))
+		Expect(err).To(MatchError(ContainSubstring("pipelinerun.renovate")))
+	})
+})
@@ -0,0 +1,500 @@
+// Copyright 2024 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	githubv69 "github.com/google/go-github/v69/github"
+	"github.com/robfig/cron/v3"
+	tektonv1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/yaml"
+)
+
+// SchemaVersion is the version of the config.json schema. A config without a
+// version is read as the current version.
+const SchemaVersion = "v1"
+
+// Value is a scalar config value. It can be given as a JSON string or number,
+// e.g. "40" or 40.
+type Value string
+
+func (v *Value) UnmarshalJSON(data []byte) error {
+	if bytes.HasPrefix(data, []byte(`"`)) {
+		var s string
+		if err := json.Unmarshal(data, &s); err != nil {
+			return err
+		}
+		*v = Value(s)
+		return nil
+	}
+	var n json.Number
+	if err := json.Unmarshal(data, &n); err != nil {
+		return fmt.Errorf("expected a string or a number, got %s", data)
+	}
+	*v = Value(n)
+	return nil
+}
+
+// Schema is the content of config.json in the ConfigMap. Values which aren't
+// set use their default.
+type Schema struct {
+	Version string `json:"version"`
+
+	Global struct {
+		GhTokenValidity    Value             `json:"github-token-validity"`
+		GhTokenUsageWindow Value             `json:"github-token-usage-window"`
+		GhTokenPermissions map[string]string `json:"github-token-permissions"`
+	} `json:"global"`
+
+	PipelineRun struct {
+		MaxParallelPipelineruns                Value            `json:"max-parallel-pipelineruns"`
+		MaxPendingPipelineruns                 Value            `json:"max-pending-pipelineruns"`
+		MaxParallelPipelinerunsPerNamespace    Value            `json:"max-parallel-pipelineruns-per-namespace"`
+		NamespaceMaxParallelPipelineruns       map[string]Value `json:"namespace-max-parallel-pipelineruns"`
+		LowPriorityReservedSlots               Value            `json:"low-priority-reserved-slots"`
+		GitHostMaxParallelPipelineruns         map[string]Value `json:"git-host-max-parallel-pipelineruns"`
+		MaxParallelPipelinerunsPerInstallation Value            `json:"max-parallel-pipelineruns-per-installation"`
+		RateLimitMinRemaining                  Value            `json:"rate-limit-min-remaining"`
+		SchedulerResyncPeriod                  Value            `json:"scheduler-resync-period"`
+		StuckDeadline                          Value            `json:"stuck-pipelinerun-deadline"`
+		Retry                                  struct {
+			MaxAttempts      Value    `json:"max-attempts"`
+			InitialBackoff   Value    `json:"initial-backoff"`
+			MaxBackoff       Value    `json:"max-backoff"`
+			RetryableReasons []string `json:"retryable-reasons"`
+		} `json:"retry"`
+		Retention struct {
+			KeepLastPerRepository Value `json:"keep-last-per-repository"`
+			KeepFailedDays        Value `json:"keep-failed-days"`
+			Interval              Value `json:"interval"`
+		} `json:"retention"`
+		Renovate           RenovateRunSchema            `json:"renovate"`
+		NamespaceRenovate  map[string]RenovateRunSchema `json:"namespace-renovate"`
+		AnnotationMaximums struct {
+			CPU     Value `json:"cpu"`
+			Memory  Value `json:"memory"`
+			Timeout Value `json:"timeout"`
+		} `json:"annotation-maximums"`
+		PodTemplate struct {
+			NodeSelector map[string]string   `json:"node-selector"`
+			Tolerations  []corev1.Toleration `json:"tolerations"`
+			Affinity     *corev1.Affinity    `json:"affinity"`
+		} `json:"pod-template"`
+		PipelineRef           *tektonv1.PipelineRef `json:"pipeline-ref"`
+		PipelineRefTask       Value                 `json:"pipeline-ref-task"`
+		VulnerabilityFastLane struct {
+			Interval   Value    `json:"interval"`
+			Priority   Value    `json:"priority"`
+			Registries []string `json:"registries"`
+		} `json:"vulnerability-fast-lane"`
+		BlackoutWindows []struct {
+			Name       string   `json:"name"`
+			Schedule   string   `json:"schedule"`
+			Duration   Value    `json:"duration"`
+			Hosts      []string `json:"hosts"`
+			Namespaces []string `json:"namespaces"`
+		} `json:"blackout-windows"`
+	} `json:"pipelinerun"`
+}
+
+// RenovateRunSchema configures how the PipelineRuns running Renovate are built
+type RenovateRunSchema struct {
+	Image            Value `json:"image"`
+	OSVDatabaseImage Value `json:"osv-database-image"`
+	RPMCertImage     Value `json:"rpm-cert-image"`
+	CPURequest       Value `json:"cpu-request"`
+	CPULimit         Value `json:"cpu-limit"`
+	MemoryRequest    Value `json:"memory-request"`
+	MemoryLimit      Value `json:"memory-limit"`
+	LogLevel         Value `json:"log-level"`
+	Timeout          Value `json:"timeout"`
+}
+
+// githubTokenPermissions are the names of the permissions which can be
+// requested for GitHub installation tokens, e.g. pull_requests
+var githubTokenPermissions = func() []string {
+	var names []string
+	permissions := reflect.TypeOf(githubv69.InstallationPermissions{})
+	for i := 0; i < permissions.NumField(); i++ {
+		name, _, _ := strings.Cut(permissions.Field(i).Tag.Get("json"), ",")
+		names = append(names, name)
+	}
+	return names
+}()
+
+// FieldError is a config value which is not valid
+type FieldError struct {
+	// Path of the field in config.json, e.g. pipelinerun.max-parallel-pipelineruns
+	Field   string
+	Value   string
+	Message string
+}
+
+func (e FieldError) Error() string {
+	if e.Value == "" {
+		return fmt.Sprintf("%s: %s", e.Field, e.Message)
+	}
+	return fmt.Sprintf("%s: %s, got %q", e.Field, e.Message, e.Value)
+}
+
+// ValidationErrors holds all the invalid values of a config
+type ValidationErrors []FieldError
+
+func (e ValidationErrors) Error() string {
+	messages := make([]string, 0, len(e))
+	for _, fieldError := range e {
+		messages = append(messages, fieldError.Error())
+	}
+	return fmt.Sprintf("invalid configuration: %s", strings.Join(messages, "; "))
+}
+
+// validator converts the values of a Schema, recording the invalid ones
+type validator struct {
+	errors ValidationErrors
+}
+
+func (v *validator) fail(field string, value Value, message string) {
+	v.errors = append(v.errors, FieldError{Field: field, Value: string(value), Message: message})
+}
+
+// err returns the recorded errors sorted by field, or nil if there are none
+func (v *validator) err() error {
+	if len(v.errors) == 0 {
+		return nil
+	}
+	sort.SliceStable(v.errors, func(i, j int) bool {
+		return v.errors[i].Field < v.errors[j].Field
+	})
+	return v.errors
+}
+
+// integer parses an integer of at least min, an empty value returns the default
+func (v *validator) integer(field string, value Value, min, defaultValue int) int {
+	if value == "" {
+		return defaultValue
+	}
+	parsed, err := strconv.Atoi(string(value))
+	if err != nil {
+		v.fail(field, value, "must be an integer")
+		return defaultValue
+	}
+	if parsed < min {
+		v.fail(field, value, fmt.Sprintf("must be at least %d", min))
+		return defaultValue
+	}
+	return parsed
+}
+
+// duration parses a duration of at least min, an empty value returns the default
+func (v *validator) duration(field string, value Value, min, defaultValue time.Duration) time.Duration {
+	if value == "" {
+		return defaultValue
+	}
+	parsed, err := time.ParseDuration(string(value))
+	if err != nil {
+		v.fail(field, value, "must be a duration, e.g. 30m")
+		return defaultValue
+	}
+	if parsed < min {
+		v.fail(field, value, fmt.Sprintf("must be at least %s", min))
+		return defaultValue
+	}
+	return parsed
+}
+
+// limits parses a map of positive limits
+func (v *validator) limits(field string, values map[string]Value) map[string]int {
+	if len(values) == 0 {
+		return nil
+	}
+	limits := make(map[string]int, len(values))
+	// Sorted, so the errors are reported in a stable order
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		if limit := v.integer(field+"."+key, values[key], 1, 0); limit > 0 {
+			limits[key] = limit
+		}
+	}
+	return limits
+}
+
+// renovateRun parses a RenovateRunSchema, only the fields which are set are
+// set in the returned config
+func (v *validator) renovateRun(field string, schema *RenovateRunSchema) RenovateRunConfig {
+	return RenovateRunConfig{
+		Image:            string(schema.Image),
+		OSVDatabaseImage: string(schema.OSVDatabaseImage),
+		RPMCertImage:     string(schema.RPMCertImage),
+		Resources: corev1.ResourceRequirements{
+			Requests: v.resources(map[corev1.ResourceName]fieldValue{
+				corev1.ResourceCPU:    {field: field + ".cpu-request", value: schema.CPURequest},
+				corev1.ResourceMemory: {field: field + ".memory-request", value: schema.MemoryRequest},
+			}),
+			Limits: v.resources(map[corev1.ResourceName]fieldValue{
+				corev1.ResourceCPU:    {field: field + ".cpu-limit", value: schema.CPULimit},
+				corev1.ResourceMemory: {field: field + ".memory-limit", value: schema.MemoryLimit},
+			}),
+		},
+		LogLevel: v.logLevel(fieldValue{field: field + ".log-level", value: schema.LogLevel}),
+		Timeout:  v.duration(field+".timeout", schema.Timeout, time.Minute, 0),
+	}
+}
+
+// toleration checks the operator and effect of a toleration
+func (v *validator) toleration(field string, toleration *corev1.Toleration) {
+	switch toleration.Operator {
+	case "", corev1.TolerationOpEqual:
+	case corev1.TolerationOpExists:
+		if toleration.Value != "" {
+			v.fail(field+".value", Value(toleration.Value), "must be empty when the operator is Exists")
+		}
+	default:
+		v.fail(field+".operator", Value(toleration.Operator), "must be Equal or Exists")
+	}
+	switch toleration.Effect {
+	case "", corev1.TaintEffectNoSchedule, corev1.TaintEffectPreferNoSchedule, corev1.TaintEffectNoExecute:
+	default:
+		v.fail(field+".effect", Value(toleration.Effect), "must be NoSchedule, PreferNoSchedule or NoExecute")
+	}
+}
+
+// Parse reads and validates the content of config.json. Unknown fields and
+// invalid values are rejected, all of them are returned as ValidationErrors.
+// An empty config is the default config.
+func Parse(data []byte) (*ControllerConfig, error) {
+	if len(bytes.TrimSpace(data)) == 0 {
+		return DefaultConfig(), nil
+	}
+
+	var schema Schema
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&schema); err != nil {
+		return nil, ValidationErrors{{Field: "config.json", Message: err.Error()}}
+	}
+
+	v := &validator{}
+	if schema.Version != "" && schema.Version != SchemaVersion {
+		v.fail("version", Value(schema.Version), fmt.Sprintf("must be %s", SchemaVersion))
+	}
+
+	defaultConfig := DefaultConfig()
+	config := &ControllerConfig{}
+
+	plr := &schema.PipelineRun
+	plrConfig := &config.PipelineRunConfig
+	defaultPlrConfig := &defaultConfig.PipelineRunConfig
+	plrConfig.MaxParallelPipelineruns = v.integer("pipelinerun.max-parallel-pipelineruns",
+		plr.MaxParallelPipelineruns, 1, defaultPlrConfig.MaxParallelPipelineruns)
+	plrConfig.MaxPendingPipelineruns = v.integer("pipelinerun.max-pending-pipelineruns",
+		plr.MaxPendingPipelineruns, 1, defaultPlrConfig.MaxPendingPipelineruns)
+	plrConfig.MaxParallelPipelinerunsPerNamespace = v.integer("pipelinerun.max-parallel-pipelineruns-per-namespace",
+		plr.MaxParallelPipelinerunsPerNamespace, 0, defaultPlrConfig.MaxParallelPipelinerunsPerNamespace)
+	plrConfig.NamespaceMaxParallelPipelineruns = v.limits("pipelinerun.namespace-max-parallel-pipelineruns",
+		plr.NamespaceMaxParallelPipelineruns)
+	plrConfig.LowPriorityReservedSlots = v.integer("pipelinerun.low-priority-reserved-slots",
+		plr.LowPriorityReservedSlots, 0, defaultPlrConfig.LowPriorityReservedSlots)
+	if plrConfig.LowPriorityReservedSlots >= plrConfig.MaxParallelPipelineruns {
+		v.fail("pipelinerun.low-priority-reserved-slots", plr.LowPriorityReservedSlots,
+			"must be less than max-parallel-pipelineruns")
+	}
+	plrConfig.GitHostMaxParallelPipelineruns = v.limits("pipelinerun.git-host-max-parallel-pipelineruns",
+		plr.GitHostMaxParallelPipelineruns)
+	plrConfig.MaxParallelPipelinerunsPerInstallation = v.integer("pipelinerun.max-parallel-pipelineruns-per-installation",
+		plr.MaxParallelPipelinerunsPerInstallation, 0, defaultPlrConfig.MaxParallelPipelinerunsPerInstallation)
+	plrConfig.RateLimitMinRemaining = v.integer("pipelinerun.rate-limit-min-remaining",
+		plr.RateLimitMinRemaining, 0, defaultPlrConfig.RateLimitMinRemaining)
+	plrConfig.SchedulerResyncPeriod = v.duration("pipelinerun.scheduler-resync-period",
+		plr.SchedulerResyncPeriod, time.Second, defaultPlrConfig.SchedulerResyncPeriod)
+	plrConfig.StuckDeadline = v.duration("pipelinerun.stuck-pipelinerun-deadline",
+		plr.StuckDeadline, 0, defaultPlrConfig.StuckDeadline)
+
+	retry := &plr.Retry
+	retryPolicy := &plrConfig.RetryPolicy
+	defaultRetryPolicy := &defaultPlrConfig.RetryPolicy
+	retryPolicy.MaxAttempts = v.integer("pipelinerun.retry.max-attempts",
+		retry.MaxAttempts, 1, defaultRetryPolicy.MaxAttempts)
+	retryPolicy.InitialBackoff = v.duration("pipelinerun.retry.initial-backoff",
+		retry.InitialBackoff, time.Second, defaultRetryPolicy.InitialBackoff)
+	retryPolicy.MaxBackoff = v.duration("pipelinerun.retry.max-backoff",
+		retry.MaxBackoff, time.Second, defaultRetryPolicy.MaxBackoff)
+	if retry.MaxBackoff != "" && retryPolicy.MaxBackoff < retryPolicy.InitialBackoff {
+		v.fail("pipelinerun.retry.max-backoff", retry.MaxBackoff, "must not be less than initial-backoff")
+	}
+	retryPolicy.MaxBackoff = max(retryPolicy.MaxBackoff, retryPolicy.InitialBackoff)
+	retryPolicy.RetryableReasons = defaultRetryPolicy.RetryableReasons
+	if retry.RetryableReasons != nil {
+		retryPolicy.RetryableReasons = retry.RetryableReasons
+	}
+
+	retention := &plr.Retention
+	retentionPolicy := &plrConfig.RetentionPolicy
+	defaultRetentionPolicy := &defaultPlrConfig.RetentionPolicy
+	retentionPolicy.KeepLastPerRepository = v.integer("pipelinerun.retention.keep-last-per-repository",
+		retention.KeepLastPerRepository, 1, defaultRetentionPolicy.KeepLastPerRepository)
+	retentionPolicy.KeepFailedFor = defaultRetentionPolicy.KeepFailedFor
+	if retention.KeepFailedDays != "" {
+		days := v.integer("pipelinerun.retention.keep-failed-days", retention.KeepFailedDays, 0, -1)
+		if days >= 0 {
+			retentionPolicy.KeepFailedFor = time.Duration(days) * 24 * time.Hour
+		}
+	}
+	retentionPolicy.Interval = v.duration("pipelinerun.retention.interval",
+		retention.Interval, time.Second, defaultRetentionPolicy.Interval)
+
+	plrConfig.Renovate = defaultPlrConfig.Renovate.Override(v.renovateRun("pipelinerun.renovate", &plr.Renovate))
+	v.requestsWithinLimits("pipelinerun.renovate", plrConfig.Renovate.Resources)
+	if len(plr.NamespaceRenovate) > 0 {
+		plrConfig.NamespaceRenovate = make(map[string]RenovateRunConfig, len(plr.NamespaceRenovate))
+		for namespace, schema := range plr.NamespaceRenovate {
+			field := "pipelinerun.namespace-renovate." + namespace
+			override := v.renovateRun(field, &schema)
+			v.requestsWithinLimits(field, plrConfig.Renovate.Override(override).Resources)
+			plrConfig.NamespaceRenovate[namespace] = override
+		}
+	}
+
+	maximums := &plr.AnnotationMaximums
+	defaultMaximums := &defaultPlrConfig.AnnotationMaximums
+	plrConfig.AnnotationMaximums = AnnotationMaximums{
+		Resources: mergeResources(defaultMaximums.Resources, v.resources(map[corev1.ResourceName]fieldValue{
+			corev1.ResourceCPU:    {field: "pipelinerun.annotation-maximums.cpu", value: maximums.CPU},
+			corev1.ResourceMemory: {field: "pipelinerun.annotation-maximums.memory", value: maximums.Memory},
+		})),
+		Timeout: v.duration("pipelinerun.annotation-maximums.timeout",
+			maximums.Timeout, time.Minute, defaultMaximums.Timeout),
+	}
+
+	plrConfig.PodTemplate = PodTemplateConfig{
+		NodeSelector: plr.PodTemplate.NodeSelector,
+		Tolerations:  plr.PodTemplate.Tolerations,
+		Affinity:     plr.PodTemplate.Affinity,
+	}
+	for i, toleration := range plr.PodTemplate.Tolerations {
+		v.toleration(fmt.Sprintf("pipelinerun.pod-template.tolerations[%d]", i), &toleration)
+	}
+
+	if ref := plr.PipelineRef; ref != nil {
+		if (ref.Name == "") == (ref.Resolver == "") {
+			v.fail("pipelinerun.pipeline-ref", "", "must set either a name or a resolver")
+		}
+		plrConfig.PipelineRef = ref
+	}
+	plrConfig.PipelineRefTask = defaultPlrConfig.PipelineRefTask
+	if plr.PipelineRefTask != "" {
+		plrConfig.PipelineRefTask = string(plr.PipelineRefTask)
+	}
+
+	fastLane := &plr.VulnerabilityFastLane
+	fastLaneConfig := &plrConfig.VulnerabilityFastLane
+	defaultFastLane := &defaultPlrConfig.VulnerabilityFastLane
+	fastLaneConfig.Interval = v.duration("pipelinerun.vulnerability-fast-lane.interval",
+		fastLane.Interval, time.Minute, defaultFastLane.Interval)
+	fastLaneConfig.Priority = v.integer("pipelinerun.vulnerability-fast-lane.priority",
+		fastLane.Priority, 1, defaultFastLane.Priority)
+	if fastLaneConfig.Priority > 1000 {
+		v.fail("pipelinerun.vulnerability-fast-lane.priority", fastLane.Priority, "must be at most 1000")
+		fastLaneConfig.Priority = defaultFastLane.Priority
+	}
+	fastLaneConfig.Registries = defaultFastLane.Registries
+	if fastLane.Registries != nil {
+		fastLaneConfig.Registries = fastLane.Registries
+	}
+
+	for i, window := range plr.BlackoutWindows {
+		field := fmt.Sprintf("pipelinerun.blackout-windows[%d]", i)
+		if window.Name == "" {
+			v.fail(field+".name", "", "must be set")
+		}
+		schedule, err := cron.ParseStandard(window.Schedule)
+		if err != nil {
+			v.fail(field+".schedule", Value(window.Schedule), err.Error())
+		}
+		if window.Duration == "" {
+			v.fail(field+".duration", "", "must be set")
+		}
+		duration := v.duration(field+".duration", window.Duration, time.Minute, 0)
+		if err != nil || duration == 0 {
+			continue
+		}
+		plrConfig.BlackoutWindows = append(plrConfig.BlackoutWindows, BlackoutWindow{
+			Name:       window.Name,
+			Spec:       window.Schedule,
+			Schedule:   schedule,
+			Duration:   duration,
+			Hosts:      window.Hosts,
+			Namespaces: window.Namespaces,
+		})
+	}
+
+	global := &schema.Global
+	globalConfig := &config.GlobalConfig
+	globalConfig.GhTokenValidity = v.duration("global.github-token-validity",
+		global.GhTokenValidity, time.Minute, defaultConfig.GlobalConfig.GhTokenValidity)
+	globalConfig.GhTokenUsageWindow = v.duration("global.github-token-usage-window",
+		global.GhTokenUsageWindow, time.Minute, defaultConfig.GlobalConfig.GhTokenUsageWindow)
+	if globalConfig.GhTokenUsageWindow >= globalConfig.GhTokenValidity {
+		v.fail("global.github-token-usage-window", global.GhTokenUsageWindow,
+			fmt.Sprintf("must be less than github-token-validity (%s)", globalConfig.GhTokenValidity))
+	}
+	globalConfig.GhTokenRenewThreshold = globalConfig.GhTokenValidity - globalConfig.GhTokenUsageWindow
+
+	globalConfig.GhTokenPermissions = defaultConfig.GlobalConfig.GhTokenPermissions
+	if len(global.GhTokenPermissions) > 0 {
+		for permission, access := range global.GhTokenPermissions {
+			if !slices.Contains(githubTokenPermissions, permission) {
+				v.fail("global.github-token-permissions."+permission, "", "unknown GitHub App permission")
+				continue
+			}
+			if access != "read" && access != "write" {
+				v.fail("global.github-token-permissions."+permission, Value(access), "must be read or write")
+			}
+		}
+		globalConfig.GhTokenPermissions = global.GhTokenPermissions
+	}
+
+	if err := v.err(); err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+// ParseFile reads and validates a config file offline. The file is either a
+// config.json, or a ConfigMap manifest holding it in YAML or JSON.
+func ParseFile(data []byte) (*ControllerConfig, error) {
+	configMap := &corev1.ConfigMap{}
+	if err := yaml.Unmarshal(data, configMap); err == nil && configMap.Kind == "ConfigMap" {
+		configJson, ok := configMap.Data["config.json"]
+		if !ok {
+			return nil, ValidationErrors{{Field: "data", Message: "config.json is missing"}}
+		}
+		return Parse([]byte(configJson))
+	}
+	return Parse(data)
+}
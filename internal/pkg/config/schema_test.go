@@ -0,0 +1,204 @@
+package config
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+var _ = Describe("Config schema", func() {
+
+	It("should use the default config for an empty config", func() {
+		config, err := Parse([]byte(""))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(Diff(config, DefaultConfig())).To(BeEmpty())
+
+		config, err = Parse([]byte("{}"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(Diff(config, DefaultConfig())).To(BeEmpty())
+	})
+
+	It("should parse values given as strings or numbers", func() {
+		config, err := Parse([]byte(`{
+			"version": "v1",
+			"global": {"github-token-validity": "2h", "github-token-usage-window": "1h"},
+			"pipelinerun": {
+				"max-parallel-pipelineruns": 20,
+				"max-pending-pipelineruns": "50",
+				"git-host-max-parallel-pipelineruns": {"gitlab.com": 5},
+				"retention": {"keep-failed-days": 2},
+				"blackout-windows": [{"name": "freeze", "schedule": "0 0 * * 6", "duration": "48h"}]
+			}
+		}`))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(config.PipelineRunConfig.MaxParallelPipelineruns).To(Equal(20))
+		Expect(config.PipelineRunConfig.MaxPendingPipelineruns).To(Equal(50))
+		Expect(config.PipelineRunConfig.GitHostMaxParallelPipelineruns).To(Equal(map[string]int{"gitlab.com": 5}))
+		Expect(config.PipelineRunConfig.RetentionPolicy.KeepFailedFor).To(Equal(48 * time.Hour))
+		Expect(config.PipelineRunConfig.BlackoutWindows).To(HaveLen(1))
+		Expect(config.GlobalConfig.GhTokenRenewThreshold).To(Equal(time.Hour))
+	})
+
+	It("should report all invalid values", func() {
+		_, err := Parse([]byte(`{
+			"version": "v2",
+			"global": {"github-token-usage-window": "2h", "github-token-permissions": {"contents": "admin"}},
+			"pipelinerun": {
+				"max-parallel-pipelineruns": "many",
+				"low-priority-reserved-slots": "50",
+				"retry": {"initial-backoff": "10m", "max-backoff": "1m"},
+				"blackout-windows": [{"name": "freeze", "schedule": "every day", "duration": "1h"}]
+			}
+		}`))
+		Expect(err).To(HaveOccurred())
+
+		var fields []string
+		for _, fieldError := range err.(ValidationErrors) {
+			fields = append(fields, fieldError.Field)
+		}
+		Expect(fields).To(Equal([]string{
+			"global.github-token-permissions.contents",
+			"global.github-token-usage-window",
+			"pipelinerun.blackout-windows[0].schedule",
+			"pipelinerun.low-priority-reserved-slots",
+			"pipelinerun.max-parallel-pipelineruns",
+			"pipelinerun.retry.max-backoff",
+			"version",
+		}))
+	})
+
+	It("should reject unknown GitHub token permissions", func() {
+		config, err := Parse([]byte(`{"global": {"github-token-permissions": {"pull_requests": "write", "checks": "read"}}}`))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(config.GlobalConfig.GhTokenPermissions).To(Equal(map[string]string{"pull_requests": "write", "checks": "read"}))
+
+		_, err = Parse([]byte(`{"global": {"github-token-permissions": {"pull-requests": "write"}}}`))
+		Expect(err).To(MatchError(ContainSubstring("global.github-token-permissions.pull-requests: unknown GitHub App permission")))
+	})
+
+	It("should keep at least the last PipelineRun of each repository", func() {
+		config, err := Parse([]byte(`{"pipelinerun": {"retention": {"keep-last-per-repository": 1, "keep-failed-days": 0}}}`))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(config.PipelineRunConfig.RetentionPolicy.KeepLastPerRepository).To(Equal(1))
+		Expect(config.PipelineRunConfig.RetentionPolicy.KeepFailedFor).To(BeZero())
+
+		_, err = Parse([]byte(`{"pipelinerun": {"retention": {"keep-last-per-repository": 0}}}`))
+		Expect(err).To(MatchError(ContainSubstring("pipelinerun.retention.keep-last-per-repository")))
+	})
+
+	It("should parse the scheduler resync period", func() {
+		config, err := Parse([]byte(`{"pipelinerun": {"scheduler-resync-period": "30s"}}`))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(config.PipelineRunConfig.SchedulerResyncPeriod).To(Equal(30 * time.Second))
+
+		config, err = Parse([]byte(`{"pipelinerun": {}}`))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(config.PipelineRunConfig.SchedulerResyncPeriod).To(Equal(time.Minute))
+
+		_, err = Parse([]byte(`{"pipelinerun": {"scheduler-resync-period": "500ms"}}`))
+		Expect(err).To(MatchError(ContainSubstring("pipelinerun.scheduler-resync-period")))
+		_, err = Parse([]byte(`{"pipelinerun": {"scheduler-resync-period": "soon"}}`))
+		Expect(err).To(MatchError(ContainSubstring("pipelinerun.scheduler-resync-period")))
+	})
+
+	It("should reject unknown fields", func() {
+		_, err := Parse([]byte(`{"pipelinerun": {"max-parallel-pipeline-runs": "10"}}`))
+		Expect(err).To(MatchError(ContainSubstring("max-parallel-pipeline-runs")))
+	})
+
+	It("should read the config from a ConfigMap manifest", func() {
+		config, err := ParseFile([]byte(`apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: mintmaker-controller-configmap
+data:
+  config.json: |
+    {"pipelinerun": {"max-parallel-pipelineruns": "12"}}
+`))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(config.PipelineRunConfig.MaxParallelPipelineruns).To(Equal(12))
+	})
+
+	It("should parse the pod template", func() {
+		config, err := Parse([]byte(`{
+			"pipelinerun": {
+				"pod-template": {
+					"node-selector": {"pool": "renovate"},
+					"tolerations": [{"key": "dedicated", "operator": "Equal", "value": "renovate", "effect": "NoSchedule"}]
+				}
+			}
+		}`))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(config.PipelineRunConfig.PodTemplate.NodeSelector).To(Equal(map[string]string{"pool": "renovate"}))
+		Expect(config.PipelineRunConfig.PodTemplate.Tolerations).To(HaveLen(1))
+
+		_, err = Parse([]byte(`{"pipelinerun": {"pod-template": {"tolerations": [{"key": "dedicated", "effect": "Never"}]}}}`))
+		Expect(err).To(MatchError(ContainSubstring("pipelinerun.pod-template.tolerations[0].effect")))
+	})
+
+	It("should parse the pipeline reference", func() {
+		config, err := Parse([]byte(`{
+			"pipelinerun": {
+				"pipeline-ref": {
+					"resolver": "bundles",
+					"params": [
+						{"name": "bundle", "value": "quay.io/konflux-ci/renovate-pipeline:v1"},
+						{"name": "name", "value": "renovate"},
+						{"name": "kind", "value": "pipeline"}
+					]
+				}
+			}
+		}`))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(config.PipelineRunConfig.PipelineRef.Resolver).To(BeEquivalentTo("bundles"))
+		Expect(config.PipelineRunConfig.PipelineRef.Params).To(HaveLen(3))
+		Expect(config.PipelineRunConfig.PipelineRefTask).To(Equal("build"))
+		Expect(DefaultConfig().PipelineRunConfig.PipelineRef).To(BeNil())
+
+		config, err = Parse([]byte(`{"pipelinerun": {"pipeline-ref": {"name": "renovate"}, "pipeline-ref-task": "run-renovate"}}`))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(config.PipelineRunConfig.PipelineRefTask).To(Equal("run-renovate"))
+
+		_, err = Parse([]byte(`{"pipelinerun": {"pipeline-ref": {"params": []}}}`))
+		Expect(err).To(MatchError(ContainSubstring("pipelinerun.pipeline-ref: must set either a name or a resolver")))
+	})
+	It("should parse the vulnerability fast lane", func() {
+		Expect(DefaultConfig().PipelineRunConfig.VulnerabilityFastLane.Interval).To(BeZero())
+
+		config, err := Parse([]byte(`{
+			"pipelinerun": {
+				"vulnerability-fast-lane": {"interval": "15m", "priority": 1000, "registries": ["quay.io"]}
+			}
+		}`))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(config.PipelineRunConfig.VulnerabilityFastLane).To(Equal(VulnerabilityFastLane{
+			Interval:   15 * time.Minute,
+			Priority:   1000,
+			Registries: []string{"quay.io"},
+		}))
+
+		_, err = Parse([]byte(`{"pipelinerun": {"vulnerability-fast-lane": {"interval": "10s", "priority": "1001"}}}`))
+		Expect(err).To(MatchError(And(
+			ContainSubstring("pipelinerun.vulnerability-fast-lane.interval: must be at least 1m0s"),
+			ContainSubstring("pipelinerun.vulnerability-fast-lane.priority: must be at most 1000"),
+		)))
+	})
+
+	It("should parse the annotation maximums", func() {
+		config, err := Parse([]byte(`{"pipelinerun": {"annotation-maximums": {"memory": "32Gi", "timeout": "12h"}}}`))
+		Expect(err).NotTo(HaveOccurred())
+		maximums := config.PipelineRunConfig.AnnotationMaximums
+		Expect(maximums.Resources.Memory().Equal(resource.MustParse("32Gi"))).To(BeTrue())
+		Expect(maximums.Timeout).To(Equal(12 * time.Hour))
+		// Maximums which aren't configured keep their default
+		Expect(maximums.Resources.Cpu().Equal(resource.MustParse("4"))).To(BeTrue())
+
+		_, err = Parse([]byte(`{"pipelinerun": {"annotation-maximums": {"cpu": "-1", "timeout": "10s"}}}`))
+		Expect(err).To(MatchError(And(
+			ContainSubstring("pipelinerun.annotation-maximums.cpu: must be a positive quantity"),
+			ContainSubstring("pipelinerun.annotation-maximums.timeout: must be at least 1m0s"),
+		)))
+	})
+})
@@ -22,6 +22,37 @@ const (
 	// Mintmaker can be disabled by disabled annotation in component
 	MintMakerDisabledAnnotationName = "mintmaker.appstudio.redhat.com/disabled"
 
-	RenovateImageEnvName    = "RENOVATE_IMAGE"
-	DefaultRenovateImageURL = "quay.io/konflux-ci/mintmaker-renovate-image:latest"
+	// Labels set on the PipelineRuns created by mintmaker
+	MintMakerGitPlatformLabel        = "mintmaker.appstudio.redhat.com/git-platform"
+	MintMakerApplicationLabel        = "mintmaker.appstudio.redhat.com/application"
+	MintMakerComponentNameLabel      = "mintmaker.appstudio.redhat.com/component"
+	MintMakerComponentNamespaceLabel = "mintmaker.appstudio.redhat.com/namespace"
+	MintMakerPriorityLabel           = "mintmaker.appstudio.redhat.com/priority"
+	MintMakerGitHostLabel            = "mintmaker.appstudio.redhat.com/git-host"
+	MintMakerRepositoryLabel         = "mintmaker.appstudio.redhat.com/repository"
+	// ID of the GitHub App installation which grants access to the repository
+	MintMakerInstallationLabel = "mintmaker.appstudio.redhat.com/installation"
+	// Attempt number of a PipelineRun, set on retries of failed PipelineRuns
+	MintMakerAttemptLabel = "mintmaker.appstudio.redhat.com/attempt"
+	// The scheduler doesn't start a PipelineRun before this time, in RFC3339 format
+	MintMakerNotBeforeAnnotation = "mintmaker.appstudio.redhat.com/not-before"
+	// Name of the PipelineRun created to retry a failed PipelineRun
+	MintMakerRetriedByAnnotation = "mintmaker.appstudio.redhat.com/retried-by"
+	// Why mintmaker cancelled a PipelineRun
+	MintMakerCancelReasonAnnotation = "mintmaker.appstudio.redhat.com/cancel-reason"
+	// Digest of the OSV database image which triggered a DependencyUpdateCheck of the vulnerability fast lane
+	MintMakerOSVDatabaseDigestAnnotation = "mintmaker.appstudio.redhat.com/osv-database-digest"
+
+	// Annotations of a component overriding the resources, timeout and log level of its PipelineRuns
+	MintMakerCPURequestAnnotation    = "mintmaker.appstudio.redhat.com/renovate-cpu-request"
+	MintMakerCPULimitAnnotation      = "mintmaker.appstudio.redhat.com/renovate-cpu-limit"
+	MintMakerMemoryRequestAnnotation = "mintmaker.appstudio.redhat.com/renovate-memory-request"
+	MintMakerMemoryLimitAnnotation   = "mintmaker.appstudio.redhat.com/renovate-memory-limit"
+	MintMakerTimeoutAnnotation       = "mintmaker.appstudio.redhat.com/renovate-timeout"
+	MintMakerLogLevelAnnotation      = "mintmaker.appstudio.redhat.com/renovate-log-level"
+
+	RenovateImageEnvName       = "RENOVATE_IMAGE"
+	DefaultRenovateImageURL    = "quay.io/konflux-ci/mintmaker-renovate-image:latest"
+	DefaultOSVDatabaseImageURL = "quay.io/konflux-ci/mintmaker-osv-database:latest"
+	DefaultRPMCertImageURL     = "registry.access.redhat.com/ubi9"
 )
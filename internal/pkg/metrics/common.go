@@ -20,12 +20,104 @@ var (
 		},
 		[]string{"status"}, // "success" or "failure"
 	)
+	apiRateLimitRemainingVec = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Subsystem: "mintmaker",
+			Name:      "api_rate_limit_remaining",
+			Help:      "Remaining API requests reported by the git host",
+		},
+		[]string{"host", "installation"},
+	)
+	apiRateLimitLimitVec = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Subsystem: "mintmaker",
+			Name:      "api_rate_limit_limit",
+			Help:      "API request limit reported by the git host",
+		},
+		[]string{"host", "installation"},
+	)
+	retainedPipelineRunsGauge = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Subsystem: "mintmaker",
+			Name:      "retained_pipelineruns",
+			Help:      "Number of completed PipelineRuns kept by the retention policy",
+		},
+	)
+	garbageCollectedPipelineRunsVec = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Subsystem: "mintmaker",
+			Name:      "garbage_collected_pipelineruns_total",
+			Help:      "Number of completed PipelineRuns deleted by the retention policy",
+		},
+		[]string{"result"}, // "success" or "failure"
+	)
+	blackoutWindowActiveVec = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Subsystem: "mintmaker",
+			Name:      "blackout_window_active",
+			Help:      "Whether a blackout window is active, holding pending PipelineRuns",
+		},
+		[]string{"window"},
+	)
+	configAcceptedGauge = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Subsystem: "mintmaker",
+			Name:      "config_accepted",
+			Help:      "Whether the last loaded controller configuration was accepted (1) or rejected (0)",
+		},
+	)
+	renovateRunsVec = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Subsystem: "mintmaker",
+			Name:      "renovate_runs_total",
+			Help:      "Number of finished Renovate runs, by the state of the repository",
+		},
+		[]string{"namespace", "repository", "status"}, // "onboarded", "disabled", "no-config", "error" or "unknown"
+	)
+	renovateBranchesUpdatedVec = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Subsystem: "mintmaker",
+			Name:      "renovate_branches_updated_total",
+			Help:      "Number of branches created or updated by Renovate",
+		},
+		[]string{"namespace", "repository"},
+	)
+	renovatePullRequestsVec = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Subsystem: "mintmaker",
+			Name:      "renovate_pull_requests_total",
+			Help:      "Number of pull requests opened or updated by Renovate",
+		},
+		[]string{"namespace", "repository", "action"}, // "created" or "updated"
+	)
+	renovateDependenciesVec = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Subsystem: "mintmaker",
+			Name:      "renovate_dependencies",
+			Help:      "Number of dependencies Renovate found in the repository in its last run",
+		},
+		[]string{"namespace", "repository"},
+	)
 )
 
 func RegisterCommonMetrics(ctx context.Context, registerer prometheus.Registerer) error {
 	log := logr.FromContextOrDiscard(ctx)
-	if err := registerer.Register(controllerAvailabilityVec); err != nil {
-		return fmt.Errorf("failed to register metrics: %w", err)
+	for _, collector := range []prometheus.Collector{
+		controllerAvailabilityVec,
+		apiRateLimitRemainingVec,
+		apiRateLimitLimitVec,
+		retainedPipelineRunsGauge,
+		garbageCollectedPipelineRunsVec,
+		blackoutWindowActiveVec,
+		configAcceptedGauge,
+		renovateRunsVec,
+		renovateBranchesUpdatedVec,
+		renovatePullRequestsVec,
+		renovateDependenciesVec,
+	} {
+		if err := registerer.Register(collector); err != nil {
+			return fmt.Errorf("failed to register metrics: %w", err)
+		}
 	}
 	ticker := time.NewTicker(10 * time.Minute)
 	log.Info("Starting metrics")
@@ -75,6 +167,57 @@ func CountScheduledRunFailure() {
 	(*probeFailure).AddEvent()
 }
 
+// SetAPIRateLimit exposes the API quota last reported for a git host and installation
+func SetAPIRateLimit(host, installation string, limit, remaining int) {
+	apiRateLimitRemainingVec.WithLabelValues(host, installation).Set(float64(remaining))
+	apiRateLimitLimitVec.WithLabelValues(host, installation).Set(float64(limit))
+}
+
+// SetRetainedPipelineRuns exposes the number of completed PipelineRuns kept after a garbage collection
+func SetRetainedPipelineRuns(count int) {
+	retainedPipelineRunsGauge.Set(float64(count))
+}
+
+// CountGarbageCollectedPipelineRun counts a PipelineRun deleted by the garbage collection
+func CountGarbageCollectedPipelineRun(success bool) {
+	result := "success"
+	if !success {
+		result = "failure"
+	}
+	garbageCollectedPipelineRunsVec.WithLabelValues(result).Inc()
+}
+
+// SetBlackoutWindowActive exposes whether a blackout window is active
+func SetBlackoutWindowActive(window string, active bool) {
+	value := 0.0
+	if active {
+		value = 1
+	}
+	blackoutWindowActiveVec.WithLabelValues(window).Set(value)
+}
+
+// SetConfigAccepted exposes whether the last loaded configuration was accepted
+func SetConfigAccepted(accepted bool) {
+	value := 0.0
+	if accepted {
+		value = 1
+	}
+	configAcceptedGauge.Set(value)
+}
+
+// CountRenovateRun counts a finished Renovate run of a repository
+func CountRenovateRun(namespace, repository, status string) {
+	renovateRunsVec.WithLabelValues(namespace, repository, status).Inc()
+}
+
+// SetRenovateOutcome exposes what a Renovate run changed in a repository
+func SetRenovateOutcome(namespace, repository string, branchesUpdated, prsCreated, prsUpdated, dependencies int) {
+	renovateBranchesUpdatedVec.WithLabelValues(namespace, repository).Add(float64(branchesUpdated))
+	renovatePullRequestsVec.WithLabelValues(namespace, repository, "created").Add(float64(prsCreated))
+	renovatePullRequestsVec.WithLabelValues(namespace, repository, "updated").Add(float64(prsUpdated))
+	renovateDependenciesVec.WithLabelValues(namespace, repository).Set(float64(dependencies))
+}
+
 type AvailabilityProbe interface {
 	CheckEvents(ctx context.Context) float64
 	AddEvent()
@@ -0,0 +1,154 @@
+// Copyright 2024 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package osv
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/konflux-ci/mintmaker/internal/pkg/registry"
+)
+
+// ContainerDatabaseFile is the file of the OSV database image holding the
+// advisories of container images, as written by the osv-generator
+const ContainerDatabaseFile = "/data/osv-db/docker.nedb"
+
+// Advisory is an OSV advisory, with the fields used to find the images it
+// affects
+type Advisory struct {
+	ID       string     `json:"id"`
+	Affected []Affected `json:"affected"`
+}
+
+// Affected is an image covered by an advisory
+type Affected struct {
+	Package Package `json:"package"`
+}
+
+// Package is an image, e.g. registry.access.redhat.com/ubi9/ubi-minimal. The
+// purl holds the tag of the image fixing the advisory, e.g.
+// pkg:oci/ubi-minimal@sha256:...?repository_url=registry.redhat.io/ubi9/ubi-minimal&tag=9.5-1
+type Package struct {
+	Name string `json:"name"`
+	Purl string `json:"purl"`
+}
+
+// FixedVersion returns the tag of the image fixing the advisory, or "" if the
+// purl has none
+func (p *Package) FixedVersion() string {
+	_, query, found := strings.Cut(p.Purl, "?")
+	if !found {
+		return ""
+	}
+	values, err := url.ParseQuery(query)
+	if err != nil {
+		return ""
+	}
+	return values.Get("tag")
+}
+
+// Affects checks if the advisory covers an image at the given version, i.e.
+// it lists the image and the version isn't the one fixing the advisory
+func (a *Advisory) Affects(image, version string) bool {
+	for _, affected := range a.Affected {
+		if affected.Package.Name != image {
+			continue
+		}
+		if fixed := affected.Package.FixedVersion(); fixed == "" || fixed != version {
+			return true
+		}
+	}
+	return false
+}
+
+// Advisories are the advisories of an OSV database by ID
+type Advisories map[string]*Advisory
+
+// ParseAdvisories reads the advisories of a NeDB file, which holds one JSON
+// document per line. An ID can be found on several lines, when a CVE is
+// fixed by several errata, their affected images are merged.
+func ParseAdvisories(data []byte) (Advisories, error) {
+	advisories := Advisories{}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(nil, len(data)+1)
+	for line := 1; scanner.Scan(); line++ {
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+		advisory := &Advisory{}
+		if err := json.Unmarshal(scanner.Bytes(), advisory); err != nil {
+			return nil, fmt.Errorf("invalid advisory on line %d: %w", line, err)
+		}
+		// NeDB also stores indexes and deleted documents, without an ID
+		if advisory.ID == "" {
+			continue
+		}
+		if existing, ok := advisories[advisory.ID]; ok {
+			existing.Affected = append(existing.Affected, advisory.Affected...)
+		} else {
+			advisories[advisory.ID] = advisory
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return advisories, nil
+}
+
+// LoadAdvisories reads the container advisories of an OSV database image
+func LoadAdvisories(ctx context.Context, httpClient *http.Client, image string) (Advisories, error) {
+	data, err := registry.ReadFile(ctx, httpClient, image, ContainerDatabaseFile)
+	if err != nil {
+		return nil, err
+	}
+	advisories, err := ParseAdvisories(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse the advisories of %s: %w", image, err)
+	}
+	return advisories, nil
+}
+
+// Added returns the advisories which aren't in the previous database, sorted
+// by ID. An advisory which was already known is returned when it covers new
+// images, with only these images.
+func (a Advisories) Added(previous Advisories) []*Advisory {
+	added := []*Advisory{}
+	for id, advisory := range a {
+		known := map[Package]bool{}
+		if previousAdvisory, ok := previous[id]; ok {
+			for _, affected := range previousAdvisory.Affected {
+				known[affected.Package] = true
+			}
+		}
+		newAdvisory := &Advisory{ID: id}
+		for _, affected := range advisory.Affected {
+			if !known[affected.Package] {
+				newAdvisory.Affected = append(newAdvisory.Affected, affected)
+			}
+		}
+		if len(newAdvisory.Affected) > 0 {
+			added = append(added, newAdvisory)
+		}
+	}
+	sort.Slice(added, func(i, j int) bool { return added[i].ID < added[j].ID })
+	return added
+}
@@ -0,0 +1,63 @@
+package osv
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+const ubiMinimal = "registry.access.redhat.com/ubi9/ubi-minimal"
+
+func packageOf(name, tag string) Package {
+	return Package{Name: name, Purl: "pkg:oci/image@sha256:abc?arch=amd64&repository_url=" + name + "&tag=" + tag}
+}
+
+var _ = Describe("OSV advisories", func() {
+
+	It("should parse the advisories of a NeDB file", func() {
+		advisories, err := ParseAdvisories([]byte(`{"_id": "a", "id": "CVE-2024-1", "affected": [{"package": {"name": "` + ubiMinimal + `", "purl": "pkg:oci/ubi-minimal?tag=9.5-1"}}]}
+
+{"$$indexCreated": {"fieldName": "id"}}
+{"_id": "b", "id": "CVE-2024-1", "affected": [{"package": {"name": "registry.access.redhat.com/ubi9", "purl": "pkg:oci/ubi9?tag=9.5-2"}}]}
+{"_id": "c", "$$deleted": true}
+`))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(advisories).To(HaveLen(1))
+		Expect(advisories["CVE-2024-1"].Affected).To(Equal([]Affected{
+			{Package: Package{Name: ubiMinimal, Purl: "pkg:oci/ubi-minimal?tag=9.5-1"}},
+			{Package: Package{Name: "registry.access.redhat.com/ubi9", Purl: "pkg:oci/ubi9?tag=9.5-2"}},
+		}))
+
+		_, err = ParseAdvisories([]byte("{\"id\": \"CVE-2024-1\"}\nnot json\n"))
+		Expect(err).To(MatchError(ContainSubstring("line 2")))
+	})
+
+	It("should return the advisories and images which aren't in the previous database", func() {
+		previous := Advisories{
+			"CVE-2024-1": {ID: "CVE-2024-1", Affected: []Affected{{Package: packageOf(ubiMinimal, "9.5-1")}}},
+			"CVE-2024-2": {ID: "CVE-2024-2", Affected: []Affected{{Package: packageOf(ubiMinimal, "9.5-1")}}},
+		}
+		current := Advisories{
+			"CVE-2024-1": {ID: "CVE-2024-1", Affected: []Affected{{Package: packageOf(ubiMinimal, "9.5-1")}}},
+			"CVE-2024-2": {ID: "CVE-2024-2", Affected: []Affected{
+				{Package: packageOf(ubiMinimal, "9.5-1")}, {Package: packageOf("registry.access.redhat.com/ubi9", "9.5-2")},
+			}},
+			"CVE-2024-3": {ID: "CVE-2024-3", Affected: []Affected{{Package: packageOf(ubiMinimal, "9.5-3")}}},
+		}
+		Expect(current.Added(previous)).To(Equal([]*Advisory{
+			{ID: "CVE-2024-2", Affected: []Affected{{Package: packageOf("registry.access.redhat.com/ubi9", "9.5-2")}}},
+			{ID: "CVE-2024-3", Affected: []Affected{{Package: packageOf(ubiMinimal, "9.5-3")}}},
+		}))
+		Expect(current.Added(current)).To(BeEmpty())
+	})
+
+	It("should only affect the listed images before the version fixing the advisory", func() {
+		advisory := &Advisory{ID: "CVE-2024-1", Affected: []Affected{
+			{Package: packageOf(ubiMinimal, "9.5-1")},
+			{Package: Package{Name: "registry.access.redhat.com/ubi9", Purl: "pkg:oci/ubi9"}},
+		}}
+		Expect(advisory.Affects(ubiMinimal, "9.4")).To(BeTrue())
+		Expect(advisory.Affects(ubiMinimal, "9.5-1")).To(BeFalse())
+		Expect(advisory.Affects("registry.access.redhat.com/ubi9", "9.5-1")).To(BeTrue())
+		Expect(advisory.Affects("docker.io/library/alpine", "3.19")).To(BeFalse())
+	})
+})
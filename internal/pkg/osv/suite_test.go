@@ -0,0 +1,13 @@
+package osv
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+func TestOSV(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "OSV Suite")
+}
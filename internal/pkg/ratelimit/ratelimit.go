@@ -0,0 +1,155 @@
+// Copyright 2024 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ratelimit
+
+import (
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	mintmakermetrics "github.com/konflux-ci/mintmaker/internal/pkg/metrics"
+)
+
+// resetEpochThreshold separates reset values given in seconds since the epoch
+// from reset values given in seconds from now
+const resetEpochThreshold = 1_000_000_000
+
+// Key identifies an API quota. GitHub App installations have their own quota,
+// other platforms are tracked per git host.
+type Key struct {
+	Host         string
+	Installation string
+}
+
+// Quota is the API quota last reported for a Key
+type Quota struct {
+	Limit     int
+	Remaining int
+	Reset     time.Time
+}
+
+// Tracker keeps track of the remaining API quota per git host and installation
+type Tracker struct {
+	mu     sync.RWMutex
+	quotas map[Key]Quota
+}
+
+// DefaultTracker is the tracker fed by the clients of the controller and by
+// the quotas Renovate reports at the end of its runs
+var DefaultTracker = NewTracker()
+
+func NewTracker() *Tracker {
+	return &Tracker{quotas: make(map[Key]Quota)}
+}
+
+// Update records the quota reported for a key
+func (t *Tracker) Update(key Key, quota Quota) {
+	t.mu.Lock()
+	t.quotas[key] = quota
+	t.mu.Unlock()
+
+	mintmakermetrics.SetAPIRateLimit(key.Host, key.Installation, quota.Limit, quota.Remaining)
+}
+
+// Get returns the last quota reported for a key
+func (t *Tracker) Get(key Key) (Quota, bool) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	quota, ok := t.quotas[key]
+	return quota, ok
+}
+
+// Exhausted checks if fewer than minRemaining requests are left for a key
+// before its quota is reset. Keys without a known quota are never exhausted.
+func (t *Tracker) Exhausted(key Key, minRemaining int, now time.Time) bool {
+	quota, ok := t.Get(key)
+	if !ok {
+		return false
+	}
+	return quota.Remaining < minRemaining && now.Before(quota.Reset)
+}
+
+// NextReset returns the earliest reset time of the exhausted quotas among
+// the given keys, e.g. the keys of the held PipelineRuns
+func (t *Tracker) NextReset(keys []Key, minRemaining int, now time.Time) (time.Time, bool) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	var next time.Time
+	for _, key := range keys {
+		quota, ok := t.quotas[key]
+		if !ok || quota.Remaining >= minRemaining || !now.Before(quota.Reset) {
+			continue
+		}
+		if next.IsZero() || quota.Reset.Before(next) {
+			next = quota.Reset
+		}
+	}
+	return next, !next.IsZero()
+}
+
+// ParseHeaders reads the quota from the rate limit headers of a response.
+// GitHub sends X-RateLimit-* headers, GitLab sends RateLimit-* headers.
+func ParseHeaders(header http.Header, now time.Time) (Quota, bool) {
+	for _, prefix := range []string{"X-RateLimit-", "RateLimit-"} {
+		remaining, err := strconv.Atoi(header.Get(prefix + "Remaining"))
+		if err != nil {
+			continue
+		}
+		quota := Quota{Remaining: remaining}
+		if limit, err := strconv.Atoi(header.Get(prefix + "Limit")); err == nil {
+			quota.Limit = limit
+		}
+		if reset, err := strconv.ParseInt(header.Get(prefix+"Reset"), 10, 64); err == nil {
+			quota.Reset = ResetTime(reset, now)
+		}
+		return quota, true
+	}
+	return Quota{}, false
+}
+
+// ResetTime converts the reset of a quota, given either in seconds since the
+// epoch or in seconds from now
+func ResetTime(reset int64, now time.Time) time.Time {
+	if reset < resetEpochThreshold {
+		return now.Add(time.Duration(reset) * time.Second)
+	}
+	return time.Unix(reset, 0)
+}
+
+// Transport records the quota reported in the responses of the wrapped
+// RoundTripper
+type Transport struct {
+	Base    http.RoundTripper
+	Key     Key
+	Tracker *Tracker
+}
+
+// NewTransport returns a Transport recording quotas in the DefaultTracker
+func NewTransport(base http.RoundTripper, key Key) *Transport {
+	return &Transport{Base: base, Key: key, Tracker: DefaultTracker}
+}
+
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.Base.RoundTrip(req)
+	if err != nil {
+		return resp, err
+	}
+	if quota, ok := ParseHeaders(resp.Header, time.Now()); ok {
+		t.Tracker.Update(t.Key, quota)
+	}
+	return resp, nil
+}
@@ -0,0 +1,130 @@
+package ratelimit
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Rate limit tracking", func() {
+
+	now := time.Unix(1700000000, 0)
+	key := Key{Host: "github.com", Installation: "1"}
+
+	Context("when parsing headers", func() {
+
+		It("should parse the GitHub headers", func() {
+			header := http.Header{}
+			header.Set("X-RateLimit-Limit", "5000")
+			header.Set("X-RateLimit-Remaining", "42")
+			header.Set("X-RateLimit-Reset", "1700000600")
+
+			quota, ok := ParseHeaders(header, now)
+			Expect(ok).To(BeTrue())
+			Expect(quota).To(Equal(Quota{Limit: 5000, Remaining: 42, Reset: time.Unix(1700000600, 0)}))
+		})
+
+		It("should parse the GitLab headers", func() {
+			header := http.Header{}
+			header.Set("RateLimit-Limit", "2000")
+			header.Set("RateLimit-Remaining", "10")
+			header.Set("RateLimit-Reset", "1700000060")
+
+			quota, ok := ParseHeaders(header, now)
+			Expect(ok).To(BeTrue())
+			Expect(quota).To(Equal(Quota{Limit: 2000, Remaining: 10, Reset: time.Unix(1700000060, 0)}))
+		})
+
+		It("should parse a reset given in seconds from now", func() {
+			header := http.Header{}
+			header.Set("RateLimit-Remaining", "10")
+			header.Set("RateLimit-Reset", "60")
+
+			quota, ok := ParseHeaders(header, now)
+			Expect(ok).To(BeTrue())
+			Expect(quota.Reset).To(Equal(now.Add(time.Minute)))
+		})
+
+		It("should convert resets given in seconds since the epoch or from now", func() {
+			Expect(ResetTime(1700000600, now)).To(Equal(time.Unix(1700000600, 0)))
+			Expect(ResetTime(600, now)).To(Equal(now.Add(10 * time.Minute)))
+		})
+
+		It("should ignore responses without rate limit headers", func() {
+			_, ok := ParseHeaders(http.Header{}, now)
+			Expect(ok).To(BeFalse())
+		})
+	})
+
+	Context("when checking quotas", func() {
+
+		var tracker *Tracker
+
+		BeforeEach(func() {
+			tracker = NewTracker()
+		})
+
+		It("should not consider unknown quotas exhausted", func() {
+			Expect(tracker.Exhausted(key, 100, now)).To(BeFalse())
+		})
+
+		It("should consider a quota exhausted until it is reset", func() {
+			tracker.Update(key, Quota{Limit: 5000, Remaining: 50, Reset: now.Add(time.Minute)})
+
+			Expect(tracker.Exhausted(key, 100, now)).To(BeTrue())
+			Expect(tracker.Exhausted(key, 10, now)).To(BeFalse())
+			Expect(tracker.Exhausted(key, 100, now.Add(time.Minute))).To(BeFalse())
+			Expect(tracker.Exhausted(Key{Host: "github.com", Installation: "2"}, 100, now)).To(BeFalse())
+		})
+
+		It("should return the earliest reset of the exhausted quotas", func() {
+			keys := []Key{key, {Host: "gitlab.com"}, {Host: "gitlab.example.com"}}
+			tracker.Update(key, Quota{Remaining: 50, Reset: now.Add(time.Hour)})
+			tracker.Update(Key{Host: "gitlab.com"}, Quota{Remaining: 0, Reset: now.Add(time.Minute)})
+			tracker.Update(Key{Host: "gitlab.example.com"}, Quota{Remaining: 1000, Reset: now.Add(time.Second)})
+
+			reset, ok := tracker.NextReset(keys, 100, now)
+			Expect(ok).To(BeTrue())
+			Expect(reset).To(Equal(now.Add(time.Minute)))
+
+			_, ok = tracker.NextReset(keys, 100, now.Add(2*time.Hour))
+			Expect(ok).To(BeFalse())
+		})
+
+		It("should only return the resets of the given keys", func() {
+			tracker.Update(Key{Host: "gitlab.com"}, Quota{Remaining: 0, Reset: now.Add(time.Minute)})
+			tracker.Update(key, Quota{Remaining: 50, Reset: now.Add(time.Hour)})
+
+			reset, ok := tracker.NextReset([]Key{key, {Host: "github.com", Installation: "2"}}, 100, now)
+			Expect(ok).To(BeTrue())
+			Expect(reset).To(Equal(now.Add(time.Hour)))
+
+			_, ok = tracker.NextReset([]Key{{Host: "gitlab.example.com"}}, 100, now)
+			Expect(ok).To(BeFalse())
+		})
+	})
+
+	It("should record the quota of responses", func() {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("X-RateLimit-Limit", "5000")
+			w.Header().Set("X-RateLimit-Remaining", "4999")
+			w.Header().Set("X-RateLimit-Reset", strconv.FormatInt(time.Now().Add(time.Hour).Unix(), 10))
+		}))
+		defer server.Close()
+
+		tracker := NewTracker()
+		client := &http.Client{Transport: &Transport{Base: http.DefaultTransport, Key: key, Tracker: tracker}}
+		resp, err := client.Get(server.URL)
+		Expect(err).NotTo(HaveOccurred())
+		resp.Body.Close()
+
+		quota, ok := tracker.Get(key)
+		Expect(ok).To(BeTrue())
+		Expect(quota.Limit).To(Equal(5000))
+		Expect(quota.Remaining).To(Equal(4999))
+	})
+})
@@ -0,0 +1,236 @@
+// Copyright 2024 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package registry
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+const dockerHubRegistry = "registry-1.docker.io"
+
+// manifestMediaTypes are the manifests accepted when resolving a tag, image
+// indexes first, so the digest of a multi-arch image is the one of its index
+var manifestMediaTypes = []string{
+	"application/vnd.oci.image.index.v1+json",
+	"application/vnd.docker.distribution.manifest.list.v2+json",
+	"application/vnd.oci.image.manifest.v1+json",
+	"application/vnd.docker.distribution.manifest.v2+json",
+}
+
+// ImageReference is an image split into its registry, repository and tag or digest
+type ImageReference struct {
+	Registry   string
+	Repository string
+	// Tag or digest of the image, e.g. latest or sha256:...
+	Reference string
+}
+
+// ParseImage splits an image, e.g. quay.io/konflux-ci/mintmaker-osv-database:latest.
+// Images without a registry are on Docker Hub and images without a tag or
+// digest use the latest tag.
+func ParseImage(image string) (*ImageReference, error) {
+	if image == "" {
+		return nil, fmt.Errorf("empty image")
+	}
+	ref := &ImageReference{Reference: "latest"}
+	name := image
+	if i := strings.Index(name, "@"); i >= 0 {
+		name, ref.Reference = name[:i], name[i+1:]
+	} else if i := strings.LastIndex(name, ":"); i > strings.LastIndex(name, "/") {
+		name, ref.Reference = name[:i], name[i+1:]
+	}
+
+	// The first part is a registry if it's a host name, with a dot or a port
+	registry, repository, found := strings.Cut(name, "/")
+	if !found || (!strings.ContainsAny(registry, ".:") && registry != "localhost") {
+		registry, repository = dockerHubRegistry, name
+		if !strings.Contains(repository, "/") {
+			repository = "library/" + repository
+		}
+	}
+	if registry == "docker.io" {
+		registry = dockerHubRegistry
+	}
+	if repository == "" || ref.Reference == "" {
+		return nil, fmt.Errorf("invalid image %s", image)
+	}
+	ref.Registry, ref.Repository = registry, repository
+	return ref, nil
+}
+
+// Pinned returns the image pinned to the given digest, e.g. to read the
+// manifest a tag pointed to even if the tag is pushed again meanwhile
+func (r *ImageReference) Pinned(digest string) string {
+	return fmt.Sprintf("%s/%s@%s", r.Registry, r.Repository, digest)
+}
+
+// Digest returns the digest of the manifest an image points to, e.g. to find
+// out when a tag is pushed again. Only public images are supported, a token
+// is requested anonymously when the registry asks for one.
+func Digest(ctx context.Context, httpClient *http.Client, image string) (string, error) {
+	ref, err := ParseImage(image)
+	if err != nil {
+		return "", err
+	}
+	if strings.HasPrefix(ref.Reference, "sha256:") {
+		return ref.Reference, nil
+	}
+
+	s := &session{httpClient: httpClient, registry: ref.Registry}
+	resp, err := s.do(ctx, http.MethodHead, s.manifestURL(ref), manifestMediaTypes)
+	if err != nil {
+		return "", err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("failed to get the manifest of %s: %s", image, resp.Status)
+	}
+	digest := resp.Header.Get("Docker-Content-Digest")
+	if digest == "" {
+		return "", fmt.Errorf("no digest returned for the manifest of %s", image)
+	}
+	return digest, nil
+}
+
+// session sends the requests to a registry, with the anonymous token the
+// registry asked for once it's known
+type session struct {
+	httpClient *http.Client
+	registry   string
+	token      string
+}
+
+func (s *session) manifestURL(ref *ImageReference) string {
+	return fmt.Sprintf("https://%s/v2/%s/manifests/%s", s.registry, ref.Repository, ref.Reference)
+}
+
+func (s *session) blobURL(ref *ImageReference, digest string) string {
+	return fmt.Sprintf("https://%s/v2/%s/blobs/%s", s.registry, ref.Repository, digest)
+}
+
+// do sends a request, and sends it again with an anonymous token when the
+// registry asks for one. The caller closes the body of the response.
+func (s *session) do(ctx context.Context, method, url string, accept []string) (*http.Response, error) {
+	resp, err := s.send(ctx, method, url, accept)
+	if err != nil || resp.StatusCode != http.StatusUnauthorized || s.token != "" {
+		return resp, err
+	}
+	resp.Body.Close()
+	if s.token, err = anonymousToken(ctx, s.httpClient, resp.Header.Get("WWW-Authenticate")); err != nil {
+		return nil, fmt.Errorf("failed to authenticate to %s: %w", s.registry, err)
+	}
+	return s.send(ctx, method, url, accept)
+}
+
+func (s *session) send(ctx context.Context, method, url string, accept []string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	if len(accept) > 0 {
+		req.Header.Set("Accept", strings.Join(accept, ", "))
+	}
+	if s.token != "" {
+		req.Header.Set("Authorization", "Bearer "+s.token)
+	}
+	return s.httpClient.Do(req)
+}
+
+// anonymousToken requests a token from the realm of a Bearer challenge, e.g.
+// Bearer realm="https://quay.io/v2/auth",service="quay.io",scope="repository:org/repo:pull"
+func anonymousToken(ctx context.Context, httpClient *http.Client, challenge string) (string, error) {
+	scheme, params, _ := strings.Cut(challenge, " ")
+	if !strings.EqualFold(scheme, "Bearer") {
+		return "", fmt.Errorf("unsupported authentication challenge %q", challenge)
+	}
+	realm := ""
+	query := url.Values{}
+	for key, value := range challengeParams(params) {
+		switch key {
+		case "realm":
+			realm = value
+		case "service", "scope":
+			query.Set(key, value)
+		}
+	}
+	if realm == "" {
+		return "", fmt.Errorf("no realm in the authentication challenge %q", challenge)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, realm+"?"+query.Encode(), nil)
+	if err != nil {
+		return "", err
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("token request failed: %s", resp.Status)
+	}
+	var body struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return "", fmt.Errorf("invalid token response: %w", err)
+	}
+	if body.Token != "" {
+		return body.Token, nil
+	}
+	if body.AccessToken != "" {
+		return body.AccessToken, nil
+	}
+	return "", fmt.Errorf("no token in the token response")
+}
+
+// challengeParams splits the parameters of an authentication challenge, e.g.
+// realm="https://auth.docker.io/token",scope="repository:org/repo:pull,push".
+// Commas separate the parameters only outside of quoted values.
+func challengeParams(params string) map[string]string {
+	result := map[string]string{}
+	for params != "" {
+		var key, value string
+		key, params, _ = strings.Cut(params, "=")
+		key = strings.ToLower(strings.TrimSpace(key))
+		params = strings.TrimLeft(params, " ")
+		if strings.HasPrefix(params, `"`) {
+			// A quoted value ends with the next unescaped quote
+			var b strings.Builder
+			i := 1
+			for ; i < len(params) && params[i] != '"'; i++ {
+				if params[i] == '\\' && i+1 < len(params) {
+					i++
+				}
+				b.WriteByte(params[i])
+			}
+			value, params = b.String(), params[min(i+1, len(params)):]
+			_, params, _ = strings.Cut(params, ",")
+		} else {
+			value, params, _ = strings.Cut(params, ",")
+			value = strings.TrimSpace(value)
+		}
+		if key != "" {
+			result[key] = value
+		}
+	}
+	return result
+}
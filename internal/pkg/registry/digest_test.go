@@ -0,0 +1,86 @@
+package registry
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Image digests", func() {
+
+	DescribeTable("should parse images",
+		func(image, registry, repository, reference string) {
+			ref, err := ParseImage(image)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(*ref).To(Equal(ImageReference{Registry: registry, Repository: repository, Reference: reference}))
+		},
+		Entry("with a tag", "quay.io/konflux-ci/mintmaker-osv-database:v1", "quay.io", "konflux-ci/mintmaker-osv-database", "v1"),
+		Entry("without a tag", "registry.access.redhat.com/ubi9", "registry.access.redhat.com", "ubi9", "latest"),
+		Entry("with a digest", "quay.io/org/repo@sha256:abc", "quay.io", "org/repo", "sha256:abc"),
+		Entry("with a port", "localhost:5000/repo:v2", "localhost:5000", "repo", "v2"),
+		Entry("on Docker Hub", "alpine:3", dockerHubRegistry, "library/alpine", "3"),
+		Entry("on Docker Hub with an organization", "docker.io/renovate/renovate", dockerHubRegistry, "renovate/renovate", "latest"),
+	)
+
+	It("should reject invalid images", func() {
+		_, err := ParseImage("")
+		Expect(err).To(HaveOccurred())
+		_, err = ParseImage("quay.io/")
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("should return the digest of images pinned by digest", func() {
+		digest, err := Digest(context.Background(), http.DefaultClient, "quay.io/org/repo@sha256:abc")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(digest).To(Equal("sha256:abc"))
+	})
+
+	It("should resolve the digest of a tag with an anonymous token", func() {
+		var server *httptest.Server
+		server = httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			switch r.URL.Path {
+			case "/token":
+				Expect(r.URL.Query().Get("scope")).To(Equal("repository:org/osv:pull"))
+				fmt.Fprint(w, `{"token": "anonymous"}`)
+			case "/v2/org/osv/manifests/latest":
+				Expect(r.Method).To(Equal(http.MethodHead))
+				Expect(r.Header.Get("Accept")).To(ContainSubstring("application/vnd.oci.image.index.v1+json"))
+				if r.Header.Get("Authorization") != "Bearer anonymous" {
+					w.Header().Set("WWW-Authenticate", fmt.Sprintf(
+						`Bearer realm="%s/token",service="registry",scope="repository:org/osv:pull"`, server.URL))
+					w.WriteHeader(http.StatusUnauthorized)
+					return
+				}
+				w.Header().Set("Docker-Content-Digest", "sha256:123")
+			default:
+				w.WriteHeader(http.StatusNotFound)
+			}
+		}))
+		defer server.Close()
+		host := strings.TrimPrefix(server.URL, "https://")
+
+		digest, err := Digest(context.Background(), server.Client(), host+"/org/osv")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(digest).To(Equal("sha256:123"))
+
+		_, err = Digest(context.Background(), server.Client(), host+"/org/missing:v1")
+		Expect(err).To(MatchError(ContainSubstring("404")))
+	})
+
+	DescribeTable("should split the parameters of authentication challenges",
+		func(params string, expected map[string]string) {
+			Expect(challengeParams(params)).To(Equal(expected))
+		},
+		Entry("with quoted values", `realm="https://quay.io/v2/auth",service="quay.io",scope="repository:org/repo:pull"`,
+			map[string]string{"realm": "https://quay.io/v2/auth", "service": "quay.io", "scope": "repository:org/repo:pull"}),
+		Entry("with commas in a quoted value", `realm="https://auth.docker.io/token", scope="repository:org/repo:pull,push",service="registry.docker.io"`,
+			map[string]string{"realm": "https://auth.docker.io/token", "scope": "repository:org/repo:pull,push", "service": "registry.docker.io"}),
+		Entry("with unquoted values and escaped quotes", `Realm=https://registry/token, error="invalid \"token\""`,
+			map[string]string{"realm": "https://registry/token", "error": `invalid "token"`}),
+	)
+})
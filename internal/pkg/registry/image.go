@@ -0,0 +1,164 @@
+// Copyright 2024 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package registry
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"path"
+	"strings"
+)
+
+// maxFileSize is the size of the largest file read from an image
+const maxFileSize = 256 << 20
+
+// ErrFileNotFound is returned when an image doesn't contain the file to read
+var ErrFileNotFound = errors.New("file not found in the image")
+
+// manifest is an image manifest or an image index, with the fields used to
+// find the layers of an image
+type manifest struct {
+	MediaType string `json:"mediaType"`
+	Manifests []struct {
+		Digest   string `json:"digest"`
+		Platform struct {
+			OS           string `json:"os"`
+			Architecture string `json:"architecture"`
+		} `json:"platform"`
+	} `json:"manifests"`
+	Layers []struct {
+		MediaType string `json:"mediaType"`
+		Digest    string `json:"digest"`
+	} `json:"layers"`
+}
+
+// ReadFile returns the content of a file of a public image, e.g. the
+// advisories of the OSV database image. The linux/amd64 image of a multi-arch
+// image is read, or its first image if there's none. The layers are read from
+// the top, so the file is returned as the container would see it.
+func ReadFile(ctx context.Context, httpClient *http.Client, image, filePath string) ([]byte, error) {
+	ref, err := ParseImage(image)
+	if err != nil {
+		return nil, err
+	}
+	s := &session{httpClient: httpClient, registry: ref.Registry}
+	imageManifest, err := s.manifest(ctx, ref)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get the manifest of %s: %w", image, err)
+	}
+	if len(imageManifest.Manifests) > 0 {
+		platformRef := *ref
+		platformRef.Reference = imageManifest.Manifests[0].Digest
+		for _, platform := range imageManifest.Manifests {
+			if platform.Platform.OS == "linux" && platform.Platform.Architecture == "amd64" {
+				platformRef.Reference = platform.Digest
+				break
+			}
+		}
+		if imageManifest, err = s.manifest(ctx, &platformRef); err != nil {
+			return nil, fmt.Errorf("failed to get the manifest of %s: %w", ref.Pinned(platformRef.Reference), err)
+		}
+	}
+
+	name := strings.TrimPrefix(path.Clean("/"+filePath), "/")
+	for i := len(imageManifest.Layers) - 1; i >= 0; i-- {
+		content, found, err := s.readLayerFile(ctx, ref, imageManifest.Layers[i].Digest, name)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read layer %s of %s: %w", imageManifest.Layers[i].Digest, image, err)
+		}
+		if found {
+			if content == nil {
+				// The file was deleted by the layer
+				break
+			}
+			return content, nil
+		}
+	}
+	return nil, fmt.Errorf("%w: %s in %s", ErrFileNotFound, filePath, image)
+}
+
+func (s *session) manifest(ctx context.Context, ref *ImageReference) (*manifest, error) {
+	resp, err := s.do(ctx, http.MethodGet, s.manifestURL(ref), manifestMediaTypes)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected response: %s", resp.Status)
+	}
+	result := &manifest{}
+	if err := json.NewDecoder(resp.Body).Decode(result); err != nil {
+		return nil, fmt.Errorf("invalid manifest: %w", err)
+	}
+	return result, nil
+}
+
+// readLayerFile looks for a file in a layer, a tar archive which may be
+// compressed with gzip. It returns whether the layer adds or deletes the
+// file, the content is nil when it's deleted.
+func (s *session) readLayerFile(ctx context.Context, ref *ImageReference, digest, name string) ([]byte, bool, error) {
+	resp, err := s.do(ctx, http.MethodGet, s.blobURL(ref, digest), nil)
+	if err != nil {
+		return nil, false, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, false, fmt.Errorf("unexpected response: %s", resp.Status)
+	}
+
+	var layer io.Reader = bufio.NewReader(resp.Body)
+	if magic, err := layer.(*bufio.Reader).Peek(2); err == nil && bytes.Equal(magic, []byte{0x1f, 0x8b}) {
+		gzipReader, err := gzip.NewReader(layer)
+		if err != nil {
+			return nil, false, err
+		}
+		defer gzipReader.Close()
+		layer = gzipReader
+	}
+
+	// Deleted files are marked by a whiteout file, .wh.<name>
+	whiteout := path.Join(path.Dir(name), ".wh."+path.Base(name))
+	archive := tar.NewReader(layer)
+	for {
+		header, err := archive.Next()
+		if err == io.EOF {
+			return nil, false, nil
+		}
+		if err != nil {
+			return nil, false, err
+		}
+		switch strings.TrimPrefix(path.Clean("/"+header.Name), "/") {
+		case whiteout:
+			return nil, true, nil
+		case name:
+			if header.Typeflag != tar.TypeReg {
+				return nil, false, fmt.Errorf("%s isn't a regular file", name)
+			}
+			if header.Size > maxFileSize {
+				return nil, false, fmt.Errorf("%s is larger than %d bytes", name, maxFileSize)
+			}
+			content, err := io.ReadAll(archive)
+			return content, err == nil, err
+		}
+	}
+}
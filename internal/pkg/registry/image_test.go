@@ -0,0 +1,130 @@
+package registry
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+// layerOf returns a tar archive with the given files, compressed with gzip
+func layerOf(compress bool, files map[string]string) []byte {
+	var archive bytes.Buffer
+	writer := tar.NewWriter(&archive)
+	for name, content := range files {
+		Expect(writer.WriteHeader(&tar.Header{Name: name, Mode: 0o644, Size: int64(len(content)), Typeflag: tar.TypeReg})).To(Succeed())
+		_, err := writer.Write([]byte(content))
+		Expect(err).NotTo(HaveOccurred())
+	}
+	Expect(writer.Close()).To(Succeed())
+	if !compress {
+		return archive.Bytes()
+	}
+	var compressed bytes.Buffer
+	gzipWriter := gzip.NewWriter(&compressed)
+	_, err := gzipWriter.Write(archive.Bytes())
+	Expect(err).NotTo(HaveOccurred())
+	Expect(gzipWriter.Close()).To(Succeed())
+	return compressed.Bytes()
+}
+
+var _ = Describe("Image files", func() {
+
+	var (
+		server *httptest.Server
+		host   string
+		blobs  map[string][]byte
+	)
+
+	_ = BeforeEach(func() {
+		blobs = map[string][]byte{}
+		index, _ := json.Marshal(map[string]any{
+			"mediaType": "application/vnd.oci.image.index.v1+json",
+			"manifests": []map[string]any{
+				{"digest": "sha256:arm64", "platform": map[string]string{"os": "linux", "architecture": "arm64"}},
+				{"digest": "sha256:amd64", "platform": map[string]string{"os": "linux", "architecture": "amd64"}},
+			},
+		})
+		blobs["manifests/latest"] = index
+		blobs["blobs/sha256:base"] = layerOf(true, map[string]string{
+			"data/osv-db/docker.nedb": "base",
+			"data/osv-db/rpm.nedb":    "rpm",
+			"etc/os-release":          "ubi",
+		})
+		blobs["blobs/sha256:top"] = layerOf(false, map[string]string{
+			"./data/osv-db/docker.nedb": "top",
+			"data/osv-db/.wh.rpm.nedb":  "",
+		})
+		amd64, _ := json.Marshal(map[string]any{
+			"mediaType": "application/vnd.oci.image.manifest.v1+json",
+			"layers": []map[string]string{
+				{"mediaType": "application/vnd.oci.image.layer.v1.tar+gzip", "digest": "sha256:base"},
+				{"mediaType": "application/vnd.oci.image.layer.v1.tar", "digest": "sha256:top"},
+			},
+		})
+		blobs["manifests/sha256:amd64"] = amd64
+
+		server = httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path == "/token" {
+				fmt.Fprint(w, `{"token": "anonymous"}`)
+				return
+			}
+			if r.Header.Get("Authorization") != "Bearer anonymous" {
+				w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Bearer realm="%s/token",scope="repository:org/osv:pull,push"`, server.URL))
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			blob, ok := blobs[strings.TrimPrefix(r.URL.Path, "/v2/org/osv/")]
+			if !ok {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			_, _ = w.Write(blob)
+		}))
+		host = strings.TrimPrefix(server.URL, "https://")
+	})
+
+	_ = AfterEach(func() {
+		server.Close()
+	})
+
+	It("should read a file from the top layer of the linux/amd64 image", func() {
+		content, err := ReadFile(context.Background(), server.Client(), host+"/org/osv", "/data/osv-db/docker.nedb")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(content)).To(Equal("top"))
+
+		content, err = ReadFile(context.Background(), server.Client(), host+"/org/osv:latest", "etc/os-release")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(content)).To(Equal("ubi"))
+	})
+
+	It("should read a file of an image pinned by digest", func() {
+		ref, err := ParseImage(host + "/org/osv:latest")
+		Expect(err).NotTo(HaveOccurred())
+		content, err := ReadFile(context.Background(), server.Client(), ref.Pinned("sha256:amd64"), "data/osv-db/docker.nedb")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(content)).To(Equal("top"))
+	})
+
+	It("should not return deleted or missing files", func() {
+		_, err := ReadFile(context.Background(), server.Client(), host+"/org/osv", "/data/osv-db/rpm.nedb")
+		Expect(errors.Is(err, ErrFileNotFound)).To(BeTrue())
+		_, err = ReadFile(context.Background(), server.Client(), host+"/org/osv", "/data/missing")
+		Expect(errors.Is(err, ErrFileNotFound)).To(BeTrue())
+	})
+
+	It("should return an error when a layer is missing", func() {
+		delete(blobs, "blobs/sha256:top")
+		_, err := ReadFile(context.Background(), server.Client(), host+"/org/osv", "/data/osv-db/docker.nedb")
+		Expect(err).To(MatchError(ContainSubstring("404")))
+	})
+})
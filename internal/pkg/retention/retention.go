@@ -0,0 +1,87 @@
+// Copyright 2024 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package retention
+
+import (
+	"sort"
+	"time"
+
+	tektonv1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+	"knative.dev/pkg/apis"
+
+	"github.com/konflux-ci/mintmaker/internal/pkg/config"
+	. "github.com/konflux-ci/mintmaker/internal/pkg/constant"
+)
+
+// Result splits the completed PipelineRuns into the ones to keep and the
+// ones to delete
+type Result struct {
+	Retained []tektonv1.PipelineRun
+	Expired  []tektonv1.PipelineRun
+}
+
+// Apply applies the retention policy to the PipelineRuns. Only completed
+// PipelineRuns are considered, the last ones of each repository are kept, as
+// well as failed PipelineRuns which completed within the retention period
+// for failures, so they can still be investigated.
+func Apply(policy *config.RetentionPolicy, runs []tektonv1.PipelineRun, now time.Time) Result {
+	runsByRepository := make(map[string][]tektonv1.PipelineRun)
+	var repositories []string
+	for _, run := range runs {
+		if !run.IsDone() {
+			continue
+		}
+		repository := run.Labels[MintMakerGitHostLabel] + "/" + run.Labels[MintMakerRepositoryLabel]
+		if _, ok := runsByRepository[repository]; !ok {
+			repositories = append(repositories, repository)
+		}
+		runsByRepository[repository] = append(runsByRepository[repository], run)
+	}
+	sort.Strings(repositories)
+
+	var result Result
+	for _, repository := range repositories {
+		repositoryRuns := runsByRepository[repository]
+		// Newest first
+		sort.SliceStable(repositoryRuns, func(i, j int) bool {
+			return completionTimeOf(&repositoryRuns[j]).Before(completionTimeOf(&repositoryRuns[i]))
+		})
+		for i, run := range repositoryRuns {
+			if i < policy.KeepLastPerRepository || keepFailed(policy, &run, now) {
+				result.Retained = append(result.Retained, run)
+			} else {
+				result.Expired = append(result.Expired, run)
+			}
+		}
+	}
+	return result
+}
+
+// keepFailed checks if the PipelineRun failed within the retention period for failures
+func keepFailed(policy *config.RetentionPolicy, run *tektonv1.PipelineRun, now time.Time) bool {
+	if run.Status.GetCondition(apis.ConditionSucceeded).IsTrue() {
+		return false
+	}
+	return now.Sub(completionTimeOf(run)) < policy.KeepFailedFor
+}
+
+// completionTimeOf returns when the PipelineRun completed, falling back to
+// its creation time for PipelineRuns cancelled before they started
+func completionTimeOf(run *tektonv1.PipelineRun) time.Time {
+	if run.Status.CompletionTime != nil {
+		return run.Status.CompletionTime.Time
+	}
+	return run.CreationTimestamp.Time
+}
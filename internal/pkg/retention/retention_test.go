@@ -0,0 +1,90 @@
+package retention
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	tektonv1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/konflux-ci/mintmaker/internal/pkg/config"
+	. "github.com/konflux-ci/mintmaker/internal/pkg/constant"
+)
+
+var now = time.Now()
+
+func newPipelineRun(name, repository string, completedAgo time.Duration, succeeded bool) tektonv1.PipelineRun {
+	run := tektonv1.PipelineRun{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:              name,
+			Namespace:         MintMakerNamespaceName,
+			CreationTimestamp: metav1.NewTime(now.Add(-completedAgo - time.Hour)),
+			Labels: map[string]string{
+				MintMakerGitHostLabel:    "github.com",
+				MintMakerRepositoryLabel: repository,
+			},
+		},
+	}
+	if succeeded {
+		run.Status.MarkSucceeded(string(tektonv1.PipelineRunReasonSuccessful), "succeeded")
+	} else {
+		run.Status.MarkFailed(string(tektonv1.PipelineRunReasonFailed), "failed")
+	}
+	run.Status.CompletionTime = &metav1.Time{Time: now.Add(-completedAgo)}
+	return run
+}
+
+func names(runs []tektonv1.PipelineRun) []string {
+	result := []string{}
+	for _, run := range runs {
+		result = append(result, run.Name)
+	}
+	return result
+}
+
+var _ = Describe("Retention", func() {
+
+	var policy *config.RetentionPolicy
+
+	BeforeEach(func() {
+		policy = &config.RetentionPolicy{KeepLastPerRepository: 2, KeepFailedFor: 24 * time.Hour}
+	})
+
+	It("should keep the last PipelineRuns of each repository", func() {
+		runs := []tektonv1.PipelineRun{
+			newPipelineRun("a-old", "org-a", 3*time.Hour, true),
+			newPipelineRun("a-newest", "org-a", time.Hour, true),
+			newPipelineRun("a-new", "org-a", 2*time.Hour, true),
+			newPipelineRun("b-old", "org-b", 48*time.Hour, true),
+		}
+
+		result := Apply(policy, runs, now)
+		Expect(names(result.Retained)).To(Equal([]string{"a-newest", "a-new", "b-old"}))
+		Expect(names(result.Expired)).To(Equal([]string{"a-old"}))
+	})
+
+	It("should keep recently failed PipelineRuns", func() {
+		runs := []tektonv1.PipelineRun{
+			newPipelineRun("newest", "org-a", time.Hour, true),
+			newPipelineRun("new", "org-a", 2*time.Hour, true),
+			newPipelineRun("failed-recently", "org-a", 3*time.Hour, false),
+			newPipelineRun("failed-long-ago", "org-a", 48*time.Hour, false),
+		}
+
+		result := Apply(policy, runs, now)
+		Expect(names(result.Retained)).To(Equal([]string{"newest", "new", "failed-recently"}))
+		Expect(names(result.Expired)).To(Equal([]string{"failed-long-ago"}))
+	})
+
+	It("should ignore PipelineRuns which haven't completed", func() {
+		policy.KeepLastPerRepository = 0
+		running := newPipelineRun("running", "org-a", time.Hour, true)
+		running.Status.Conditions = nil
+		running.Status.CompletionTime = nil
+
+		result := Apply(policy, []tektonv1.PipelineRun{running, newPipelineRun("done", "org-a", time.Hour, true)}, now)
+		Expect(names(result.Retained)).To(BeEmpty())
+		Expect(names(result.Expired)).To(Equal([]string{"done"}))
+	})
+})
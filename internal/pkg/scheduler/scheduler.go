@@ -0,0 +1,401 @@
+// Copyright 2024 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scheduler
+
+import (
+	"slices"
+	"sort"
+	"strconv"
+	"time"
+
+	tektonv1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+
+	"github.com/konflux-ci/mintmaker/internal/pkg/config"
+	. "github.com/konflux-ci/mintmaker/internal/pkg/constant"
+	"github.com/konflux-ci/mintmaker/internal/pkg/ratelimit"
+)
+
+// Scheduler decides which pending PipelineRuns are started next. PipelineRuns
+// with a higher priority are started first. Within the same priority, slots
+// are shared fairly between namespaces: pending PipelineRuns are taken
+// round-robin from per-namespace queues, so a namespace with many components
+// can't starve the others. PipelineRuns are only started if their namespace,
+// git host and GitHub App installation are below their limits, and their API
+// quota isn't close to exhaustion. PipelineRuns retried with a backoff aren't
+// started before their not-before time, and no PipelineRuns are started during
+// a blackout window applying to them.
+type Scheduler struct {
+	config     *config.PipelineRunConfig
+	rateLimits *ratelimit.Tracker
+	now        time.Time
+}
+
+func NewScheduler(config *config.PipelineRunConfig) *Scheduler {
+	return &Scheduler{
+		config:     config,
+		rateLimits: ratelimit.DefaultTracker,
+		now:        time.Now(),
+	}
+}
+
+// NextRateLimitReset returns the time when the next exhausted API quota of
+// the pending PipelineRuns is reset, so the PipelineRuns held because of it
+// can be scheduled again
+func (s *Scheduler) NextRateLimitReset(pending []tektonv1.PipelineRun) (time.Time, bool) {
+	if s.config.RateLimitMinRemaining <= 0 {
+		return time.Time{}, false
+	}
+	keys := make([]ratelimit.Key, 0, len(pending))
+	for i := range pending {
+		keys = append(keys, RateLimitKeyOf(&pending[i]))
+	}
+	return s.rateLimits.NextReset(keys, s.config.RateLimitMinRemaining, s.now)
+}
+
+// WindowState is the state of a blackout window at the time of scheduling
+type WindowState struct {
+	Name   string
+	Active bool
+	// End of the window, set if it is active
+	Until time.Time
+}
+
+// BlackoutWindows returns the state of the configured blackout windows
+func (s *Scheduler) BlackoutWindows() []WindowState {
+	var states []WindowState
+	for i := range s.config.BlackoutWindows {
+		window := &s.config.BlackoutWindows[i]
+		active, until := window.ActiveAt(s.now)
+		states = append(states, WindowState{Name: window.Name, Active: active, Until: until})
+	}
+	return states
+}
+
+// NextBlackoutEnd returns the time when the next active blackout window
+// applying to one of the pending PipelineRuns ends, so the PipelineRuns held
+// because of it can be scheduled again
+func (s *Scheduler) NextBlackoutEnd(pending []tektonv1.PipelineRun) (time.Time, bool) {
+	var next time.Time
+	for i := range s.config.BlackoutWindows {
+		window := &s.config.BlackoutWindows[i]
+		if !appliesToAny(window, pending) {
+			continue
+		}
+		if active, until := window.ActiveAt(s.now); active && (next.IsZero() || until.Before(next)) {
+			next = until
+		}
+	}
+	return next, !next.IsZero()
+}
+
+// appliesToAny checks if the blackout window applies to any of the PipelineRuns
+func appliesToAny(window *config.BlackoutWindow, runs []tektonv1.PipelineRun) bool {
+	for i := range runs {
+		if window.AppliesTo(runs[i].Labels[MintMakerGitHostLabel], runs[i].Labels[MintMakerComponentNamespaceLabel]) {
+			return true
+		}
+	}
+	return false
+}
+
+// tier holds the pending PipelineRuns of the same priority
+type tier struct {
+	priority int
+	runs     []tektonv1.PipelineRun
+}
+
+// queue holds the pending PipelineRuns of a namespace, oldest first
+type queue struct {
+	namespace string
+	runs      []tektonv1.PipelineRun
+}
+
+// usage counts the running PipelineRuns per namespace, git host and installation
+type usage struct {
+	namespaces    map[string]int
+	hosts         map[string]int
+	installations map[string]int
+	prioritized   int
+}
+
+func newUsage(running []tektonv1.PipelineRun) *usage {
+	u := &usage{
+		namespaces:    make(map[string]int),
+		hosts:         make(map[string]int),
+		installations: make(map[string]int),
+	}
+	for i := range running {
+		u.add(&running[i])
+	}
+	return u
+}
+
+func (u *usage) add(run *tektonv1.PipelineRun) {
+	u.namespaces[run.Labels[MintMakerComponentNamespaceLabel]]++
+	u.hosts[run.Labels[MintMakerGitHostLabel]]++
+	if installation := installationOf(run); installation != "" {
+		u.installations[installation]++
+	}
+	if priorityOf(run) > 0 {
+		u.prioritized++
+	}
+}
+
+// Schedule returns the pending PipelineRuns that should be started, in the
+// order they should be started, given the PipelineRuns currently running.
+func (s *Scheduler) Schedule(running, pending []tektonv1.PipelineRun) []tektonv1.PipelineRun {
+	availableSlots := s.config.MaxParallelPipelineruns - len(running)
+	if availableSlots <= 0 || len(pending) == 0 {
+		return nil
+	}
+
+	u := newUsage(running)
+	// Prioritized PipelineRuns can't take the slots reserved for routine runs
+	prioritizedSlots := s.config.MaxParallelPipelineruns - s.config.LowPriorityReservedSlots - u.prioritized
+
+	var selected []tektonv1.PipelineRun
+	for _, t := range newTiers(pending) {
+		slots := availableSlots
+		if t.priority > 0 {
+			slots = min(slots, prioritizedSlots)
+		}
+		runs := s.roundRobin(newQueues(t.runs), slots, u)
+		selected = append(selected, runs...)
+
+		availableSlots -= len(runs)
+		if t.priority > 0 {
+			prioritizedSlots -= len(runs)
+		}
+		if availableSlots <= 0 {
+			break
+		}
+	}
+
+	return selected
+}
+
+// roundRobin takes PipelineRuns from the queues in turns, until there are no
+// slots left or no PipelineRun in the queues can be started. The usage is
+// updated with the selected PipelineRuns.
+func (s *Scheduler) roundRobin(queues []*queue, slots int, u *usage) []tektonv1.PipelineRun {
+	var selected []tektonv1.PipelineRun
+	for slots > 0 && len(queues) > 0 {
+		var remaining []*queue
+		for _, q := range queues {
+			if slots == 0 {
+				break
+			}
+			// Take the oldest PipelineRun of the namespace which can be started,
+			// the queue is dropped when there is none, as the usage only grows
+			index := slices.IndexFunc(q.runs, func(run tektonv1.PipelineRun) bool {
+				return s.canStart(&run, u)
+			})
+			if index < 0 {
+				continue
+			}
+			run := q.runs[index]
+			selected = append(selected, run)
+			u.add(&run)
+			slots--
+
+			q.runs = slices.Delete(q.runs, index, index+1)
+			if len(q.runs) > 0 {
+				remaining = append(remaining, q)
+			}
+		}
+		queues = remaining
+	}
+	return selected
+}
+
+// NextNotBefore returns the earliest not-before time of the pending
+// PipelineRuns which can't be started yet
+func (s *Scheduler) NextNotBefore(pending []tektonv1.PipelineRun) (time.Time, bool) {
+	var next time.Time
+	for i := range pending {
+		notBefore, ok := notBeforeOf(&pending[i])
+		if !ok || !s.now.Before(notBefore) {
+			continue
+		}
+		if next.IsZero() || notBefore.Before(next) {
+			next = notBefore
+		}
+	}
+	return next, !next.IsZero()
+}
+
+// canStart checks if starting the PipelineRun would exceed the limits of its
+// namespace, git host or installation, if their API quota is nearly exhausted,
+// or if a blackout window applying to the PipelineRun is active
+func (s *Scheduler) canStart(run *tektonv1.PipelineRun, u *usage) bool {
+	if notBefore, ok := notBeforeOf(run); ok && s.now.Before(notBefore) {
+		return false
+	}
+
+	namespace := run.Labels[MintMakerComponentNamespaceLabel]
+	host := run.Labels[MintMakerGitHostLabel]
+	if s.inBlackout(host, namespace) {
+		return false
+	}
+
+	namespaceLimit, ok := s.config.NamespaceMaxParallelPipelineruns[namespace]
+	if !ok {
+		namespaceLimit = s.config.MaxParallelPipelinerunsPerNamespace
+	}
+	if limitReached(namespaceLimit, u.namespaces[namespace]) {
+		return false
+	}
+
+	if limitReached(s.config.GitHostMaxParallelPipelineruns[host], u.hosts[host]) {
+		return false
+	}
+
+	if installation := installationOf(run); installation != "" {
+		if limitReached(s.config.MaxParallelPipelinerunsPerInstallation, u.installations[installation]) {
+			return false
+		}
+	}
+
+	if s.config.RateLimitMinRemaining > 0 &&
+		s.rateLimits.Exhausted(RateLimitKeyOf(run), s.config.RateLimitMinRemaining, s.now) {
+		return false
+	}
+	return true
+}
+
+// inBlackout checks if an active blackout window applies to the git host and
+// namespace
+func (s *Scheduler) inBlackout(host, namespace string) bool {
+	for i := range s.config.BlackoutWindows {
+		window := &s.config.BlackoutWindows[i]
+		if !window.AppliesTo(host, namespace) {
+			continue
+		}
+		if active, _ := window.ActiveAt(s.now); active {
+			return true
+		}
+	}
+	return false
+}
+
+// limitReached checks if the count reached the limit, 0 means no limit
+func limitReached(limit, count int) bool {
+	return limit > 0 && count >= limit
+}
+
+// installationOf returns a key identifying the installation of the
+// PipelineRun, or an empty string if it has none
+func installationOf(run *tektonv1.PipelineRun) string {
+	installation := run.Labels[MintMakerInstallationLabel]
+	if installation == "" {
+		return ""
+	}
+	return run.Labels[MintMakerGitHostLabel] + "/" + installation
+}
+
+// notBeforeOf returns the time before which the PipelineRun must not be
+// started. PipelineRuns with an invalid time can be started right away.
+func notBeforeOf(run *tektonv1.PipelineRun) (time.Time, bool) {
+	value, ok := run.Annotations[MintMakerNotBeforeAnnotation]
+	if !ok {
+		return time.Time{}, false
+	}
+	notBefore, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return notBefore, true
+}
+
+// RateLimitKeyOf returns the key of the API quota used by the PipelineRun, as
+// labelled when it was created
+func RateLimitKeyOf(run *tektonv1.PipelineRun) ratelimit.Key {
+	return ratelimit.Key{
+		Host:         run.Labels[MintMakerGitHostLabel],
+		Installation: run.Labels[MintMakerInstallationLabel],
+	}
+}
+
+// newTiers groups the pending PipelineRuns by priority, highest priority first
+func newTiers(pending []tektonv1.PipelineRun) []*tier {
+	tiersByPriority := make(map[int]*tier)
+	var tiers []*tier
+	for _, run := range pending {
+		priority := priorityOf(&run)
+		t, ok := tiersByPriority[priority]
+		if !ok {
+			t = &tier{priority: priority}
+			tiersByPriority[priority] = t
+			tiers = append(tiers, t)
+		}
+		t.runs = append(t.runs, run)
+	}
+	sort.Slice(tiers, func(i, j int) bool {
+		return tiers[i].priority > tiers[j].priority
+	})
+	return tiers
+}
+
+// priorityOf returns the priority of a PipelineRun. PipelineRuns without a
+// valid priority label have the default priority, 0.
+func priorityOf(run *tektonv1.PipelineRun) int {
+	priority, err := strconv.Atoi(run.Labels[MintMakerPriorityLabel])
+	if err != nil || priority < 0 {
+		return 0
+	}
+	return priority
+}
+
+// newQueues groups the pending PipelineRuns by the namespace of their
+// component. The queues are ordered by their oldest PipelineRun, so the
+// namespace which has been waiting the longest is served first.
+func newQueues(pending []tektonv1.PipelineRun) []*queue {
+	queuesByNamespace := make(map[string]*queue)
+	var queues []*queue
+	for _, run := range pending {
+		namespace := run.Labels[MintMakerComponentNamespaceLabel]
+		q, ok := queuesByNamespace[namespace]
+		if !ok {
+			q = &queue{namespace: namespace}
+			queuesByNamespace[namespace] = q
+			queues = append(queues, q)
+		}
+		q.runs = append(q.runs, run)
+	}
+
+	for _, q := range queues {
+		sortByCreationTime(q.runs)
+	}
+	sort.SliceStable(queues, func(i, j int) bool {
+		return olderThan(&queues[i].runs[0], &queues[j].runs[0])
+	})
+	return queues
+}
+
+// sortByCreationTime sorts PipelineRuns by creation timestamp (oldest first)
+func sortByCreationTime(runs []tektonv1.PipelineRun) {
+	sort.SliceStable(runs, func(i, j int) bool {
+		return olderThan(&runs[i], &runs[j])
+	})
+}
+
+// olderThan compares the creation timestamps of two PipelineRuns, using the
+// name to break ties, so the order is deterministic
+func olderThan(a, b *tektonv1.PipelineRun) bool {
+	if a.CreationTimestamp.Equal(&b.CreationTimestamp) {
+		return a.Name < b.Name
+	}
+	return a.CreationTimestamp.Before(&b.CreationTimestamp)
+}
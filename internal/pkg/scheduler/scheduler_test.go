@@ -0,0 +1,370 @@
+package scheduler
+
+import (
+	"fmt"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/robfig/cron/v3"
+	tektonv1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/konflux-ci/mintmaker/internal/pkg/config"
+	. "github.com/konflux-ci/mintmaker/internal/pkg/constant"
+	"github.com/konflux-ci/mintmaker/internal/pkg/ratelimit"
+)
+
+var now = time.Now()
+
+func newPipelineRun(name, namespace string, age time.Duration) tektonv1.PipelineRun {
+	return tektonv1.PipelineRun{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:              name,
+			Namespace:         MintMakerNamespaceName,
+			CreationTimestamp: metav1.NewTime(now.Add(-age)),
+			Labels: map[string]string{
+				MintMakerComponentNamespaceLabel: namespace,
+			},
+		},
+	}
+}
+
+func withPriority(run tektonv1.PipelineRun, priority string) tektonv1.PipelineRun {
+	run.Labels[MintMakerPriorityLabel] = priority
+	return run
+}
+
+func withHost(run tektonv1.PipelineRun, host, installation string) tektonv1.PipelineRun {
+	run.Labels[MintMakerGitHostLabel] = host
+	if installation != "" {
+		run.Labels[MintMakerInstallationLabel] = installation
+	}
+	return run
+}
+
+func names(runs []tektonv1.PipelineRun) []string {
+	var result []string
+	for _, run := range runs {
+		result = append(result, run.Name)
+	}
+	return result
+}
+
+var _ = Describe("Scheduler", func() {
+
+	var cfg *config.PipelineRunConfig
+
+	BeforeEach(func() {
+		cfg = &config.PipelineRunConfig{MaxParallelPipelineruns: 3}
+	})
+
+	It("should not start anything when all slots are taken", func() {
+		running := []tektonv1.PipelineRun{
+			newPipelineRun("r1", "ns-a", time.Hour),
+			newPipelineRun("r2", "ns-a", time.Hour),
+			newPipelineRun("r3", "ns-b", time.Hour),
+		}
+		pending := []tektonv1.PipelineRun{newPipelineRun("p1", "ns-a", time.Minute)}
+
+		Expect(NewScheduler(cfg).Schedule(running, pending)).To(BeEmpty())
+	})
+
+	It("should start the oldest PipelineRuns of a single namespace first", func() {
+		pending := []tektonv1.PipelineRun{
+			newPipelineRun("newest", "ns-a", 5*time.Minute),
+			newPipelineRun("oldest", "ns-a", 30*time.Minute),
+			newPipelineRun("middle", "ns-a", 15*time.Minute),
+			newPipelineRun("older", "ns-a", 20*time.Minute),
+		}
+
+		Expect(names(NewScheduler(cfg).Schedule(nil, pending))).To(Equal([]string{"oldest", "older", "middle"}))
+	})
+
+	It("should share slots between namespaces round-robin", func() {
+		cfg.MaxParallelPipelineruns = 5
+		pending := []tektonv1.PipelineRun{
+			newPipelineRun("a1", "ns-a", 60*time.Minute),
+			newPipelineRun("a2", "ns-a", 59*time.Minute),
+			newPipelineRun("a3", "ns-a", 58*time.Minute),
+			newPipelineRun("a4", "ns-a", 57*time.Minute),
+			newPipelineRun("b1", "ns-b", 10*time.Minute),
+			newPipelineRun("c1", "ns-c", 20*time.Minute),
+			newPipelineRun("c2", "ns-c", 5*time.Minute),
+		}
+
+		Expect(names(NewScheduler(cfg).Schedule(nil, pending))).To(Equal([]string{"a1", "c1", "b1", "a2", "c2"}))
+	})
+
+	It("should respect the per-namespace limit", func() {
+		cfg.MaxParallelPipelineruns = 10
+		cfg.MaxParallelPipelinerunsPerNamespace = 2
+		running := []tektonv1.PipelineRun{newPipelineRun("r1", "ns-a", time.Hour)}
+		pending := []tektonv1.PipelineRun{
+			newPipelineRun("a1", "ns-a", 30*time.Minute),
+			newPipelineRun("a2", "ns-a", 20*time.Minute),
+			newPipelineRun("b1", "ns-b", 10*time.Minute),
+			newPipelineRun("b2", "ns-b", 9*time.Minute),
+			newPipelineRun("b3", "ns-b", 8*time.Minute),
+		}
+
+		Expect(names(NewScheduler(cfg).Schedule(running, pending))).To(Equal([]string{"a1", "b1", "b2"}))
+	})
+
+	It("should prefer the namespace specific limit", func() {
+		cfg.MaxParallelPipelineruns = 10
+		cfg.MaxParallelPipelinerunsPerNamespace = 1
+		cfg.NamespaceMaxParallelPipelineruns = map[string]int{"ns-b": 3}
+		pending := []tektonv1.PipelineRun{
+			newPipelineRun("a1", "ns-a", 30*time.Minute),
+			newPipelineRun("a2", "ns-a", 20*time.Minute),
+			newPipelineRun("b1", "ns-b", 10*time.Minute),
+			newPipelineRun("b2", "ns-b", 9*time.Minute),
+			newPipelineRun("b3", "ns-b", 8*time.Minute),
+			newPipelineRun("b4", "ns-b", 7*time.Minute),
+		}
+
+		Expect(names(NewScheduler(cfg).Schedule(nil, pending))).To(Equal([]string{"a1", "b1", "b2", "b3"}))
+	})
+
+	Context("with prioritized PipelineRuns", func() {
+
+		It("should start PipelineRuns with higher priority first", func() {
+			pending := []tektonv1.PipelineRun{
+				newPipelineRun("routine-old", "ns-a", time.Hour),
+				withPriority(newPipelineRun("high-new", "ns-b", time.Minute), "100"),
+				withPriority(newPipelineRun("medium", "ns-a", 10*time.Minute), "50"),
+				withPriority(newPipelineRun("high-old", "ns-c", 5*time.Minute), "100"),
+			}
+
+			Expect(names(NewScheduler(cfg).Schedule(nil, pending))).To(Equal([]string{"high-old", "high-new", "medium"}))
+		})
+
+		It("should treat an invalid priority as the default one", func() {
+			pending := []tektonv1.PipelineRun{
+				withPriority(newPipelineRun("invalid", "ns-a", time.Hour), "urgent"),
+				withPriority(newPipelineRun("negative", "ns-a", 50*time.Minute), "-5"),
+				withPriority(newPipelineRun("high", "ns-b", time.Minute), "10"),
+			}
+
+			Expect(names(NewScheduler(cfg).Schedule(nil, pending))).To(Equal([]string{"high", "invalid", "negative"}))
+		})
+
+		It("should keep the reserved slots for routine PipelineRuns", func() {
+			cfg.MaxParallelPipelineruns = 4
+			cfg.LowPriorityReservedSlots = 1
+			running := []tektonv1.PipelineRun{withPriority(newPipelineRun("r1", "ns-a", time.Hour), "100")}
+			pending := []tektonv1.PipelineRun{
+				withPriority(newPipelineRun("high1", "ns-b", 30*time.Minute), "100"),
+				withPriority(newPipelineRun("high2", "ns-b", 20*time.Minute), "100"),
+				withPriority(newPipelineRun("high3", "ns-b", 10*time.Minute), "100"),
+				newPipelineRun("routine", "ns-c", 5*time.Minute),
+			}
+
+			Expect(names(NewScheduler(cfg).Schedule(running, pending))).To(Equal([]string{"high1", "high2", "routine"}))
+		})
+
+		It("should let routine PipelineRuns use all the slots", func() {
+			cfg.LowPriorityReservedSlots = 1
+			pending := []tektonv1.PipelineRun{
+				newPipelineRun("routine1", "ns-a", 30*time.Minute),
+				newPipelineRun("routine2", "ns-a", 20*time.Minute),
+				newPipelineRun("routine3", "ns-a", 10*time.Minute),
+			}
+
+			Expect(names(NewScheduler(cfg).Schedule(nil, pending))).To(Equal([]string{"routine1", "routine2", "routine3"}))
+		})
+	})
+
+	Context("with git host and installation limits", func() {
+
+		BeforeEach(func() {
+			cfg.MaxParallelPipelineruns = 10
+		})
+
+		It("should respect the git host limit", func() {
+			cfg.GitHostMaxParallelPipelineruns = map[string]int{"gitlab.example.com": 2}
+			running := []tektonv1.PipelineRun{withHost(newPipelineRun("r1", "ns-a", time.Hour), "gitlab.example.com", "")}
+			pending := []tektonv1.PipelineRun{
+				withHost(newPipelineRun("gitlab1", "ns-a", 30*time.Minute), "gitlab.example.com", ""),
+				withHost(newPipelineRun("gitlab2", "ns-b", 20*time.Minute), "gitlab.example.com", ""),
+				withHost(newPipelineRun("github1", "ns-c", 10*time.Minute), "github.com", "1"),
+			}
+
+			Expect(names(NewScheduler(cfg).Schedule(running, pending))).To(Equal([]string{"gitlab1", "github1"}))
+		})
+
+		It("should skip over PipelineRuns of a host at its limit within a namespace", func() {
+			cfg.GitHostMaxParallelPipelineruns = map[string]int{"gitlab.example.com": 1}
+			pending := []tektonv1.PipelineRun{
+				withHost(newPipelineRun("gitlab1", "ns-a", 30*time.Minute), "gitlab.example.com", ""),
+				withHost(newPipelineRun("gitlab2", "ns-a", 20*time.Minute), "gitlab.example.com", ""),
+				withHost(newPipelineRun("github1", "ns-a", 10*time.Minute), "github.com", "1"),
+			}
+
+			Expect(names(NewScheduler(cfg).Schedule(nil, pending))).To(Equal([]string{"gitlab1", "github1"}))
+		})
+
+		It("should respect the installation limit", func() {
+			cfg.MaxParallelPipelinerunsPerInstallation = 1
+			pending := []tektonv1.PipelineRun{
+				withHost(newPipelineRun("inst1-a", "ns-a", 30*time.Minute), "github.com", "1"),
+				withHost(newPipelineRun("inst1-b", "ns-b", 20*time.Minute), "github.com", "1"),
+				withHost(newPipelineRun("inst2-a", "ns-c", 10*time.Minute), "github.com", "2"),
+				withHost(newPipelineRun("gitlab", "ns-c", 5*time.Minute), "gitlab.com", ""),
+				withHost(newPipelineRun("gitlab2", "ns-c", 4*time.Minute), "gitlab.com", ""),
+			}
+
+			Expect(names(NewScheduler(cfg).Schedule(nil, pending))).To(Equal([]string{"inst1-a", "inst2-a", "gitlab", "gitlab2"}))
+		})
+	})
+	Context("with API quotas", func() {
+
+		var s *Scheduler
+
+		BeforeEach(func() {
+			cfg.MaxParallelPipelineruns = 10
+			cfg.RateLimitMinRemaining = 100
+			s = NewScheduler(cfg)
+			s.rateLimits = ratelimit.NewTracker()
+			s.now = now
+		})
+
+		It("should hold PipelineRuns whose quota is nearly exhausted", func() {
+			s.rateLimits.Update(ratelimit.Key{Host: "github.com", Installation: "1"}, ratelimit.Quota{Remaining: 50, Reset: now.Add(time.Minute)})
+			s.rateLimits.Update(ratelimit.Key{Host: "github.com", Installation: "2"}, ratelimit.Quota{Remaining: 500, Reset: now.Add(time.Minute)})
+			pending := []tektonv1.PipelineRun{
+				withHost(newPipelineRun("inst1", "ns-a", 30*time.Minute), "github.com", "1"),
+				withHost(newPipelineRun("inst2", "ns-b", 20*time.Minute), "github.com", "2"),
+				withHost(newPipelineRun("gitlab", "ns-c", 10*time.Minute), "gitlab.com", ""),
+			}
+
+			Expect(names(s.Schedule(nil, pending))).To(Equal([]string{"inst2", "gitlab"}))
+			reset, ok := s.NextRateLimitReset(pending)
+			Expect(ok).To(BeTrue())
+			Expect(reset).To(Equal(now.Add(time.Minute)))
+		})
+
+		It("should only wait for the quotas of the pending PipelineRuns", func() {
+			s.rateLimits.Update(ratelimit.Key{Host: "gitlab.com"}, ratelimit.Quota{Remaining: 0, Reset: now.Add(time.Minute)})
+			pending := []tektonv1.PipelineRun{withHost(newPipelineRun("inst1", "ns-a", 30*time.Minute), "github.com", "1")}
+
+			Expect(names(s.Schedule(nil, pending))).To(Equal([]string{"inst1"}))
+			_, ok := s.NextRateLimitReset(pending)
+			Expect(ok).To(BeFalse())
+		})
+
+		It("should start PipelineRuns once the quota is reset", func() {
+			s.rateLimits.Update(ratelimit.Key{Host: "gitlab.com"}, ratelimit.Quota{Remaining: 0, Reset: now.Add(-time.Second)})
+			pending := []tektonv1.PipelineRun{withHost(newPipelineRun("gitlab", "ns-a", 10*time.Minute), "gitlab.com", "")}
+
+			Expect(names(s.Schedule(nil, pending))).To(Equal([]string{"gitlab"}))
+			_, ok := s.NextRateLimitReset(pending)
+			Expect(ok).To(BeFalse())
+		})
+
+		It("should not hold PipelineRuns when disabled", func() {
+			cfg.RateLimitMinRemaining = 0
+			s.rateLimits.Update(ratelimit.Key{Host: "gitlab.com"}, ratelimit.Quota{Remaining: 0, Reset: now.Add(time.Minute)})
+			pending := []tektonv1.PipelineRun{withHost(newPipelineRun("gitlab", "ns-a", 10*time.Minute), "gitlab.com", "")}
+
+			Expect(names(s.Schedule(nil, pending))).To(Equal([]string{"gitlab"}))
+		})
+	})
+	Context("with retried PipelineRuns", func() {
+
+		It("should not start PipelineRuns before their not-before time", func() {
+			retry := newPipelineRun("retry", "ns-a", time.Hour)
+			retry.Annotations = map[string]string{MintMakerNotBeforeAnnotation: now.Add(time.Minute).Format(time.RFC3339)}
+			started := newPipelineRun("started", "ns-a", 30*time.Minute)
+			started.Annotations = map[string]string{MintMakerNotBeforeAnnotation: now.Add(-time.Minute).Format(time.RFC3339)}
+			pending := []tektonv1.PipelineRun{retry, started, newPipelineRun("new", "ns-b", 10*time.Minute)}
+
+			s := NewScheduler(cfg)
+			s.now = now
+			Expect(names(s.Schedule(nil, pending))).To(Equal([]string{"started", "new"}))
+
+			notBefore, ok := s.NextNotBefore(pending)
+			Expect(ok).To(BeTrue())
+			Expect(notBefore).To(BeTemporally("==", now.Add(time.Minute).Truncate(time.Second)))
+		})
+	})
+
+	Context("with blackout windows", func() {
+
+		var s *Scheduler
+
+		// newWindow returns a window which started an hour ago and lasts two hours
+		newWindow := func(name string, hosts, namespaces []string) config.BlackoutWindow {
+			start := now.Add(-time.Hour)
+			schedule, err := cron.ParseStandard(fmt.Sprintf("%d %d * * *", start.Minute(), start.Hour()))
+			Expect(err).NotTo(HaveOccurred())
+			return config.BlackoutWindow{Name: name, Schedule: schedule, Duration: 2 * time.Hour, Hosts: hosts, Namespaces: namespaces}
+		}
+
+		BeforeEach(func() {
+			cfg.MaxParallelPipelineruns = 10
+			s = NewScheduler(cfg)
+			s.now = now
+		})
+
+		It("should not start any PipelineRun during a global window", func() {
+			cfg.BlackoutWindows = []config.BlackoutWindow{newWindow("freeze", nil, nil)}
+			pending := []tektonv1.PipelineRun{
+				withHost(newPipelineRun("github", "ns-a", 30*time.Minute), "github.com", "1"),
+				withHost(newPipelineRun("gitlab", "ns-b", 20*time.Minute), "gitlab.com", ""),
+			}
+
+			Expect(s.Schedule(nil, pending)).To(BeEmpty())
+			end, ok := s.NextBlackoutEnd(pending)
+			Expect(ok).To(BeTrue())
+			Expect(end).To(BeTemporally("~", now.Add(time.Hour), time.Minute))
+		})
+
+		It("should only hold PipelineRuns of the hosts and namespaces of the window", func() {
+			cfg.BlackoutWindows = []config.BlackoutWindow{
+				newWindow("gitlab-maintenance", []string{"gitlab.com"}, nil),
+				newWindow("ns-c-freeze", nil, []string{"ns-c"}),
+			}
+			pending := []tektonv1.PipelineRun{
+				withHost(newPipelineRun("github", "ns-a", 30*time.Minute), "github.com", "1"),
+				withHost(newPipelineRun("gitlab", "ns-b", 20*time.Minute), "gitlab.com", ""),
+				withHost(newPipelineRun("github-ns-c", "ns-c", 10*time.Minute), "github.com", "1"),
+			}
+
+			Expect(names(s.Schedule(nil, pending))).To(Equal([]string{"github"}))
+		})
+
+		It("should only wait for the windows of the pending PipelineRuns", func() {
+			gitlabWindow := newWindow("gitlab-maintenance", []string{"gitlab.com"}, nil)
+			gitlabWindow.Duration = 90 * time.Minute
+			cfg.BlackoutWindows = []config.BlackoutWindow{gitlabWindow, newWindow("ns-c-freeze", nil, []string{"ns-c"})}
+			pending := []tektonv1.PipelineRun{
+				withHost(newPipelineRun("github-ns-c", "ns-c", 10*time.Minute), "github.com", "1"),
+			}
+
+			Expect(s.Schedule(nil, pending)).To(BeEmpty())
+			end, ok := s.NextBlackoutEnd(pending)
+			Expect(ok).To(BeTrue())
+			Expect(end).To(BeTemporally("~", now.Add(time.Hour), time.Minute))
+
+			pending = []tektonv1.PipelineRun{
+				withHost(newPipelineRun("github", "ns-a", 10*time.Minute), "github.com", "1"),
+			}
+			_, ok = s.NextBlackoutEnd(pending)
+			Expect(ok).To(BeFalse())
+		})
+
+		It("should start PipelineRuns outside of the window", func() {
+			window := newWindow("freeze", nil, nil)
+			window.Duration = 30 * time.Minute
+			cfg.BlackoutWindows = []config.BlackoutWindow{window}
+			pending := []tektonv1.PipelineRun{newPipelineRun("run", "ns-a", 10*time.Minute)}
+
+			Expect(names(s.Schedule(nil, pending))).To(Equal([]string{"run"}))
+			Expect(s.BlackoutWindows()).To(Equal([]WindowState{{Name: "freeze"}}))
+			_, ok := s.NextBlackoutEnd(pending)
+			Expect(ok).To(BeFalse())
+		})
+	})
+})
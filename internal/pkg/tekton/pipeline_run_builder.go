@@ -15,21 +15,26 @@
 package tekton
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"os"
 	"reflect"
+	"slices"
+	"strings"
 	"time"
 	"unicode"
 
 	"github.com/hashicorp/go-multierror"
 	. "github.com/konflux-ci/mintmaker/internal/pkg/constant"
+	"github.com/tektoncd/pipeline/pkg/apis/pipeline/pod"
 	tektonv1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
 	"k8s.io/utils/ptr"
-	"k8s.io/utils/strings/slices"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
 )
@@ -37,6 +42,8 @@ import (
 type PipelineRunBuilder struct {
 	err         *multierror.Error
 	pipelineRun *tektonv1.PipelineRun
+	// Task of the referenced pipeline running Renovate
+	refTaskName string
 }
 
 type MountOptions struct {
@@ -50,6 +57,9 @@ type MountOptions struct {
 	DefaultMode *int32
 	// Optional specifies whether the ConfigMap/Secret must exist
 	Optional *bool
+	// Which workspace of a referenced pipeline the ConfigMap/Secret is bound to.
+	// Only used with WithPipelineRef, TaskName and StepNames are then ignored
+	WorkspaceName string
 }
 
 // NewMountOptions creates a new MountOptions with default values
@@ -92,6 +102,11 @@ func (o *MountOptions) WithOptional(optional bool) *MountOptions {
 	return o
 }
 
+func (o *MountOptions) WithWorkspaceName(workspaceName string) *MountOptions {
+	o.WorkspaceName = workspaceName
+	return o
+}
+
 // NewPipelineRunBuilder initializes a new PipelineRunBuilder with the given name prefix and namespace.
 // It sets the name of the PipelineRun to be generated with the provided prefix and sets its namespace.
 func NewPipelineRunBuilder(name, namespace string) *PipelineRunBuilder {
@@ -134,7 +149,7 @@ func NewPipelineRunBuilder(name, namespace string) *PipelineRunBuilder {
 									Steps: []tektonv1.Step{
 										{
 											Name:   "prepare-db",
-											Image:  "quay.io/konflux-ci/mintmaker-osv-database:latest",
+											Image:  DefaultOSVDatabaseImageURL,
 											Script: "echo 'Copying OSV database to the shared workspace'; cp -r /data/osv-db /workspace/shared-data",
 											SecurityContext: &corev1.SecurityContext{
 												Capabilities:             &corev1.Capabilities{Drop: []corev1.Capability{"ALL"}},
@@ -145,7 +160,7 @@ func NewPipelineRunBuilder(name, namespace string) *PipelineRunBuilder {
 										},
 										{
 											Name:  "prepare-rpm-cert",
-											Image: "registry.access.redhat.com/ubi9",
+											Image: DefaultRPMCertImageURL,
 											Script: "[ ! -f \"/etc/renovate/secret/rpm-activationkey\" ] && echo 'RPM secret not found. Exiting.' && exit 0;" +
 												"echo 'Generating RPM certificate and copying it to shared workspace';" +
 												"KEY_NAME=$(cat /etc/renovate/secret/rpm-activationkey);" +
@@ -269,57 +284,34 @@ func (b *PipelineRunBuilder) WithLabels(labels map[string]string) *PipelineRunBu
 // - items: items from ConfigMap to be mounted. If nil, all items will be mounted
 // - opts: mount options
 func (b *PipelineRunBuilder) WithConfigMap(name, mountPath string, items []corev1.KeyToPath, opts *MountOptions) *PipelineRunBuilder {
-	if opts == nil {
-		opts = &MountOptions{}
-	}
-	if opts.TaskName == "" {
-		opts.TaskName = "build"
-	}
-	if opts.ReadOnly == nil {
-		readOnly := true
-		opts.ReadOnly = &readOnly
-	}
+	opts = withMountDefaults(opts)
 
-	for i, task := range b.pipelineRun.Spec.PipelineSpec.Tasks {
-		// Add volume when task matches
-		if task.Name == opts.TaskName && task.TaskSpec != nil {
-			volumeName := fmt.Sprintf("configmap-%s", name)
-			volume := corev1.Volume{
-				Name: volumeName,
-				VolumeSource: corev1.VolumeSource{
-					ConfigMap: &corev1.ConfigMapVolumeSource{
-						LocalObjectReference: corev1.LocalObjectReference{
-							Name: name,
-						},
-						Items:       items,
-						Optional:    opts.Optional,
-						DefaultMode: opts.DefaultMode,
-					},
+	if b.pipelineRun.Spec.PipelineRef != nil {
+		return b.withWorkspace(name, opts, tektonv1.WorkspaceBinding{
+			ConfigMap: &corev1.ConfigMapVolumeSource{
+				LocalObjectReference: corev1.LocalObjectReference{
+					Name: name,
 				},
-			}
-			b.pipelineRun.Spec.PipelineSpec.Tasks[i].TaskSpec.TaskSpec.Volumes = append(
-				b.pipelineRun.Spec.PipelineSpec.Tasks[i].TaskSpec.TaskSpec.Volumes,
-				volume,
-			)
-
-			// Add volume mount to specified steps or all steps
-			volumeMount := corev1.VolumeMount{
-				Name:      volumeName,
-				MountPath: mountPath,
-				ReadOnly:  *opts.ReadOnly,
-			}
-
-			for j := range b.pipelineRun.Spec.PipelineSpec.Tasks[i].TaskSpec.TaskSpec.Steps {
-				step := &b.pipelineRun.Spec.PipelineSpec.Tasks[i].TaskSpec.TaskSpec.Steps[j]
-				if len(opts.StepNames) == 0 || slices.Contains(opts.StepNames, step.Name) {
-					step.VolumeMounts = append(step.VolumeMounts, volumeMount)
-				}
-			}
-			break
-		}
+				Items:       items,
+				Optional:    opts.Optional,
+				DefaultMode: opts.DefaultMode,
+			},
+		})
 	}
-	// TODO: error when the specified task is not found
-	return b
+
+	return b.withVolume(corev1.Volume{
+		Name: volumeName("configmap", name),
+		VolumeSource: corev1.VolumeSource{
+			ConfigMap: &corev1.ConfigMapVolumeSource{
+				LocalObjectReference: corev1.LocalObjectReference{
+					Name: name,
+				},
+				Items:       items,
+				Optional:    opts.Optional,
+				DefaultMode: opts.DefaultMode,
+			},
+		},
+	}, mountPath, opts)
 }
 
 // Mounts a Secret to the specified task and steps.
@@ -328,6 +320,82 @@ func (b *PipelineRunBuilder) WithConfigMap(name, mountPath string, items []corev
 // - items: items from Secret to be mounted. If nil, all items will be mounted
 // - opts: mount options
 func (b *PipelineRunBuilder) WithSecret(name, mountPath string, items []corev1.KeyToPath, opts *MountOptions) *PipelineRunBuilder {
+	opts = withMountDefaults(opts)
+
+	if b.pipelineRun.Spec.PipelineRef != nil {
+		return b.withWorkspace(name, opts, tektonv1.WorkspaceBinding{
+			Secret: &corev1.SecretVolumeSource{
+				SecretName:  name,
+				Items:       items,
+				Optional:    opts.Optional,
+				DefaultMode: opts.DefaultMode,
+			},
+		})
+	}
+
+	return b.withVolume(corev1.Volume{
+		Name: volumeName("secret", name),
+		VolumeSource: corev1.VolumeSource{
+			Secret: &corev1.SecretVolumeSource{
+				SecretName:  name,
+				Items:       items,
+				Optional:    opts.Optional,
+				DefaultMode: opts.DefaultMode,
+			},
+		},
+	}, mountPath, opts)
+}
+
+// Mounts several Secrets as a single projected volume to the specified task
+// and steps, so they can share a mount path without shadowing each other.
+// - mountPath: where the Secrets should be mounted to
+// - secrets: the Secrets, and their items, to be mounted
+// - opts: mount options
+func (b *PipelineRunBuilder) WithProjectedSecrets(mountPath string, secrets []corev1.SecretProjection, opts *MountOptions) *PipelineRunBuilder {
+	opts = withMountDefaults(opts)
+
+	names := make([]string, 0, len(secrets))
+	sources := make([]corev1.VolumeProjection, 0, len(secrets))
+	for i := range secrets {
+		names = append(names, secrets[i].Name)
+		sources = append(sources, corev1.VolumeProjection{Secret: &secrets[i]})
+	}
+	projected := &corev1.ProjectedVolumeSource{
+		Sources:     sources,
+		DefaultMode: opts.DefaultMode,
+	}
+
+	if b.pipelineRun.Spec.PipelineRef != nil {
+		return b.withWorkspace(strings.Join(names, ", "), opts, tektonv1.WorkspaceBinding{Projected: projected})
+	}
+
+	return b.withVolume(corev1.Volume{
+		Name:         volumeName("projected", names...),
+		VolumeSource: corev1.VolumeSource{Projected: projected},
+	}, mountPath, opts)
+}
+
+// volumeName returns a stable volume name for the mounted objects. The names
+// of the objects are shortened to keep the volume name a valid DNS label, and
+// a hash of the names is appended to keep shortened names unique.
+func volumeName(kind string, names ...string) string {
+	hash := sha256.Sum256([]byte(kind + "/" + strings.Join(names, "/")))
+	suffix := hex.EncodeToString(hash[:])[:8]
+
+	name := strings.ReplaceAll(strings.Join(names, "-"), ".", "-")
+	maxLength := validation.DNS1123LabelMaxLength - len(kind) - len(suffix) - 2
+	if len(name) > maxLength {
+		name = strings.TrimRight(name[:maxLength], "-")
+	}
+	if name == "" {
+		return kind + "-" + suffix
+	}
+	return kind + "-" + name + "-" + suffix
+}
+
+// withMountDefaults returns the mount options with the unset task name and
+// read-only flag defaulted
+func withMountDefaults(opts *MountOptions) *MountOptions {
 	if opts == nil {
 		opts = &MountOptions{}
 	}
@@ -338,44 +406,114 @@ func (b *PipelineRunBuilder) WithSecret(name, mountPath string, items []corev1.K
 		readOnly := true
 		opts.ReadOnly = &readOnly
 	}
+	return opts
+}
 
-	// Find the specified task
+// withVolume adds the volume to the task of the mount options and mounts it
+// to the specified steps, or to all steps. Nothing is mounted and an error is
+// accumulated if the task or one of the steps doesn't exist, if the task
+// already has a volume with the same name, or if a step already has a volume
+// mounted at the mount path.
+func (b *PipelineRunBuilder) withVolume(volume corev1.Volume, mountPath string, opts *MountOptions) *PipelineRunBuilder {
+	var taskSpec *tektonv1.TaskSpec
 	for i, task := range b.pipelineRun.Spec.PipelineSpec.Tasks {
 		if task.Name == opts.TaskName && task.TaskSpec != nil {
-			volumeName := fmt.Sprintf("secret-%s", name)
-			volume := corev1.Volume{
-				Name: volumeName,
-				VolumeSource: corev1.VolumeSource{
-					Secret: &corev1.SecretVolumeSource{
-						SecretName:  name,
-						Items:       items,
-						Optional:    opts.Optional,
-						DefaultMode: opts.DefaultMode,
-					},
-				},
-			}
-			b.pipelineRun.Spec.PipelineSpec.Tasks[i].TaskSpec.TaskSpec.Volumes = append(
-				b.pipelineRun.Spec.PipelineSpec.Tasks[i].TaskSpec.TaskSpec.Volumes,
-				volume,
-			)
-
-			// Add volume mount to specified steps or all steps
-			volumeMount := corev1.VolumeMount{
-				Name:      volumeName,
-				MountPath: mountPath,
-				ReadOnly:  *opts.ReadOnly,
-			}
+			taskSpec = &b.pipelineRun.Spec.PipelineSpec.Tasks[i].TaskSpec.TaskSpec
+			break
+		}
+	}
+	if taskSpec == nil {
+		b.err = multierror.Append(b.err, fmt.Errorf("failed to mount volume %s: task %s not found", volume.Name, opts.TaskName))
+		return b
+	}
 
-			for j := range b.pipelineRun.Spec.PipelineSpec.Tasks[i].TaskSpec.TaskSpec.Steps {
-				step := &b.pipelineRun.Spec.PipelineSpec.Tasks[i].TaskSpec.TaskSpec.Steps[j]
-				if len(opts.StepNames) == 0 || slices.Contains(opts.StepNames, step.Name) {
-					step.VolumeMounts = append(step.VolumeMounts, volumeMount)
-				}
+	var err *multierror.Error
+	for _, existing := range taskSpec.Volumes {
+		if existing.Name == volume.Name {
+			err = multierror.Append(err, fmt.Errorf("failed to mount volume %s: task %s already has a volume with this name", volume.Name, opts.TaskName))
+		}
+	}
+	for _, stepName := range opts.StepNames {
+		if !slices.ContainsFunc(taskSpec.Steps, func(step tektonv1.Step) bool { return step.Name == stepName }) {
+			err = multierror.Append(err, fmt.Errorf("failed to mount volume %s: step %s not found in task %s", volume.Name, stepName, opts.TaskName))
+		}
+	}
+	for _, step := range taskSpec.Steps {
+		if len(opts.StepNames) > 0 && !slices.Contains(opts.StepNames, step.Name) {
+			continue
+		}
+		for _, mount := range step.VolumeMounts {
+			if mount.MountPath == mountPath {
+				err = multierror.Append(err, fmt.Errorf("failed to mount volume %s: step %s already mounts volume %s at %s",
+					volume.Name, step.Name, mount.Name, mountPath))
 			}
-			break
 		}
 	}
-	// TODO: error when the specified task is not found
+	if err != nil {
+		b.err = multierror.Append(b.err, err.Errors...)
+		return b
+	}
+
+	taskSpec.Volumes = append(taskSpec.Volumes, volume)
+
+	// Add volume mount to specified steps or all steps
+	volumeMount := corev1.VolumeMount{
+		Name:      volume.Name,
+		MountPath: mountPath,
+		ReadOnly:  *opts.ReadOnly,
+	}
+	for j := range taskSpec.Steps {
+		step := &taskSpec.Steps[j]
+		if len(opts.StepNames) == 0 || slices.Contains(opts.StepNames, step.Name) {
+			step.VolumeMounts = append(step.VolumeMounts, volumeMount)
+		}
+	}
+	return b
+}
+
+// withWorkspace binds a ConfigMap or Secret to the workspace of a referenced
+// pipeline named in the mount options.
+func (b *PipelineRunBuilder) withWorkspace(name string, opts *MountOptions, binding tektonv1.WorkspaceBinding) *PipelineRunBuilder {
+	if opts.WorkspaceName == "" {
+		b.err = multierror.Append(b.err, fmt.Errorf("no workspace name given to bind %s to the referenced pipeline", name))
+		return b
+	}
+	for _, existing := range b.pipelineRun.Spec.Workspaces {
+		if existing.Name == opts.WorkspaceName {
+			b.err = multierror.Append(b.err, fmt.Errorf("failed to bind %s: workspace %s is already bound", name, opts.WorkspaceName))
+			return b
+		}
+	}
+	binding.Name = opts.WorkspaceName
+	b.pipelineRun.Spec.Workspaces = append(b.pipelineRun.Spec.Workspaces, binding)
+	return b
+}
+
+// WithPipelineRef makes the PipelineRun run a referenced pipeline instead of
+// the embedded one, e.g. a Tekton bundle:
+//
+//	&tektonv1.PipelineRef{ResolverRef: tektonv1.ResolverRef{
+//		Resolver: "bundles",
+//		Params: tektonv1.Params{
+//			{Name: "bundle", Value: *tektonv1.NewStructuredValues("quay.io/org/renovate-pipeline:v1")},
+//			{Name: "name", Value: *tektonv1.NewStructuredValues("renovate")},
+//			{Name: "kind", Value: *tektonv1.NewStructuredValues("pipeline")},
+//		},
+//	}}
+//
+// It has to be called before the other methods changing the pipeline. The
+// referenced pipeline gets the same params and the shared-data workspace,
+// ConfigMaps and Secrets are bound to the workspaces given in their mount
+// options. The steps of a referenced pipeline can't be changed, only the
+// compute resources of its task running Renovate, named taskName. A nil
+// reference keeps the embedded pipeline.
+func (b *PipelineRunBuilder) WithPipelineRef(ref *tektonv1.PipelineRef, taskName string) *PipelineRunBuilder {
+	if ref == nil {
+		return b
+	}
+	b.pipelineRun.Spec.PipelineRef = ref
+	b.pipelineRun.Spec.PipelineSpec = nil
+	b.refTaskName = taskName
 	return b
 }
 
@@ -441,6 +579,57 @@ func (b *PipelineRunBuilder) WithServiceAccount(serviceAccount string) *Pipeline
 	return b
 }
 
+// WithPodTemplate sets the pod template of the PipelineRun's TaskRunTemplate,
+// replacing the fields set by WithNodeSelector, WithTolerations and WithAffinity.
+func (b *PipelineRunBuilder) WithPodTemplate(template *pod.PodTemplate) *PipelineRunBuilder {
+	b.pipelineRun.Spec.TaskRunTemplate.PodTemplate = template
+	return b
+}
+
+// podTemplate returns the pod template of the PipelineRun's TaskRunTemplate,
+// it's initialized if the PipelineRun doesn't have one.
+func (b *PipelineRunBuilder) podTemplate() *pod.PodTemplate {
+	if b.pipelineRun.Spec.TaskRunTemplate.PodTemplate == nil {
+		b.pipelineRun.Spec.TaskRunTemplate.PodTemplate = &pod.PodTemplate{}
+	}
+	return b.pipelineRun.Spec.TaskRunTemplate.PodTemplate
+}
+
+// WithNodeSelector appends or updates the node selector of the PipelineRun's pods.
+func (b *PipelineRunBuilder) WithNodeSelector(nodeSelector map[string]string) *PipelineRunBuilder {
+	if len(nodeSelector) == 0 {
+		return b
+	}
+	template := b.podTemplate()
+	if template.NodeSelector == nil {
+		template.NodeSelector = make(map[string]string)
+	}
+	for key, value := range nodeSelector {
+		template.NodeSelector[key] = value
+	}
+	return b
+}
+
+// WithTolerations appends the tolerations to the PipelineRun's pods.
+func (b *PipelineRunBuilder) WithTolerations(tolerations ...corev1.Toleration) *PipelineRunBuilder {
+	if len(tolerations) == 0 {
+		return b
+	}
+	template := b.podTemplate()
+	template.Tolerations = append(template.Tolerations, tolerations...)
+	return b
+}
+
+// WithAffinity sets the affinity of the PipelineRun's pods. A nil affinity
+// keeps the current one.
+func (b *PipelineRunBuilder) WithAffinity(affinity *corev1.Affinity) *PipelineRunBuilder {
+	if affinity == nil {
+		return b
+	}
+	b.podTemplate().Affinity = affinity
+	return b
+}
+
 // WithTimeouts sets the Timeouts for the PipelineRun.
 func (b *PipelineRunBuilder) WithTimeouts(timeouts *tektonv1.TimeoutFields) *PipelineRunBuilder {
 	defaultTimeouts := &tektonv1.TimeoutFields{
@@ -453,3 +642,82 @@ func (b *PipelineRunBuilder) WithTimeouts(timeouts *tektonv1.TimeoutFields) *Pip
 	}
 	return b
 }
+
+// step returns the step of the build task with the given name. An error is
+// accumulated if there is no such step.
+func (b *PipelineRunBuilder) step(stepName string) *tektonv1.Step {
+	if b.pipelineRun.Spec.PipelineSpec == nil {
+		b.err = multierror.Append(b.err, fmt.Errorf("step %s of a referenced pipeline can't be changed", stepName))
+		return nil
+	}
+	for i, task := range b.pipelineRun.Spec.PipelineSpec.Tasks {
+		if task.Name != "build" || task.TaskSpec == nil {
+			continue
+		}
+		for j := range task.TaskSpec.Steps {
+			if task.TaskSpec.Steps[j].Name == stepName {
+				return &b.pipelineRun.Spec.PipelineSpec.Tasks[i].TaskSpec.Steps[j]
+			}
+		}
+	}
+	b.err = multierror.Append(b.err, fmt.Errorf("step %s not found", stepName))
+	return nil
+}
+
+// taskRunSpec returns the taskRunSpec of a pipeline task, it's added if the
+// PipelineRun doesn't have one.
+func (b *PipelineRunBuilder) taskRunSpec(taskName string) *tektonv1.PipelineTaskRunSpec {
+	for i := range b.pipelineRun.Spec.TaskRunSpecs {
+		if b.pipelineRun.Spec.TaskRunSpecs[i].PipelineTaskName == taskName {
+			return &b.pipelineRun.Spec.TaskRunSpecs[i]
+		}
+	}
+	b.pipelineRun.Spec.TaskRunSpecs = append(b.pipelineRun.Spec.TaskRunSpecs, tektonv1.PipelineTaskRunSpec{PipelineTaskName: taskName})
+	return &b.pipelineRun.Spec.TaskRunSpecs[len(b.pipelineRun.Spec.TaskRunSpecs)-1]
+}
+
+// WithStepImage sets the image of a step. An empty image keeps the current one.
+func (b *PipelineRunBuilder) WithStepImage(stepName, image string) *PipelineRunBuilder {
+	if step := b.step(stepName); step != nil && image != "" {
+		step.Image = image
+	}
+	return b
+}
+
+// WithStepResources sets the compute resources of a step. The steps of a
+// referenced pipeline aren't known, so the resources are set on the whole
+// task running Renovate in the taskRunSpecs, replacing the previous ones.
+func (b *PipelineRunBuilder) WithStepResources(stepName string, resources corev1.ResourceRequirements) *PipelineRunBuilder {
+	if b.pipelineRun.Spec.PipelineRef != nil {
+		b.taskRunSpec(b.refTaskName).ComputeResources = resources.DeepCopy()
+		return b
+	}
+	if step := b.step(stepName); step != nil {
+		step.ComputeResources = resources
+	}
+	return b
+}
+
+// WithStepEnv sets environment variables of a step, replacing the variables
+// with the same name.
+func (b *PipelineRunBuilder) WithStepEnv(stepName string, env ...corev1.EnvVar) *PipelineRunBuilder {
+	step := b.step(stepName)
+	if step == nil {
+		return b
+	}
+	for _, envVar := range env {
+		index := -1
+		for i := range step.Env {
+			if step.Env[i].Name == envVar.Name {
+				index = i
+				break
+			}
+		}
+		if index >= 0 {
+			step.Env[index] = envVar
+		} else {
+			step.Env = append(step.Env, envVar)
+		}
+	}
+	return b
+}
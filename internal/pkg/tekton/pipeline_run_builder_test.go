@@ -16,6 +16,7 @@ package tekton
 
 import (
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/hashicorp/go-multierror"
@@ -23,7 +24,10 @@ import (
 	. "github.com/onsi/gomega"
 	tektonv1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/utils/ptr"
 )
 
 var _ = Describe("PipelineRun builder", func() {
@@ -51,8 +55,10 @@ var _ = Describe("PipelineRun builder", func() {
 			Expect(builder.pipelineRun.ObjectMeta.Namespace).To(Equal(namespace))
 		})
 
-		It("should initialize an empty PipelineRunSpec", func() {
-			Expect(builder.pipelineRun.Spec).To(Equal(tektonv1.PipelineRunSpec{}))
+		It("should initialize a pending PipelineRunSpec with the build task", func() {
+			Expect(builder.pipelineRun.Spec.Status).To(BeEquivalentTo(tektonv1.PipelineRunSpecStatusPending))
+			Expect(builder.pipelineRun.Spec.PipelineSpec.Tasks).To(HaveLen(1))
+			Expect(builder.pipelineRun.Spec.PipelineSpec.Tasks[0].Name).To(Equal("build"))
 		})
 	})
 
@@ -285,4 +291,276 @@ var _ = Describe("PipelineRun builder", func() {
 			Expect(builder.pipelineRun.Spec.Timeouts).To(Equal(defaultTimeouts))
 		})
 	})
+
+	When("step methods are called", func() {
+		var (
+			builder *PipelineRunBuilder
+		)
+
+		BeforeEach(func() {
+			builder = NewPipelineRunBuilder("testPrefix", "testNamespace")
+		})
+
+		renovateStep := func() tektonv1.Step {
+			for _, step := range builder.pipelineRun.Spec.PipelineSpec.Tasks[0].TaskSpec.Steps {
+				if step.Name == "renovate" {
+					return step
+				}
+			}
+			Fail("renovate step not found")
+			return tektonv1.Step{}
+		}
+
+		It("should set the image of the step", func() {
+			builder.WithStepImage("renovate", "quay.io/test/renovate:1")
+			Expect(renovateStep().Image).To(Equal("quay.io/test/renovate:1"))
+
+			builder.WithStepImage("renovate", "")
+			Expect(renovateStep().Image).To(Equal("quay.io/test/renovate:1"))
+		})
+
+		It("should set the resources of the step", func() {
+			resources := corev1.ResourceRequirements{
+				Limits: corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("6Gi")},
+			}
+			builder.WithStepResources("renovate", resources)
+			Expect(renovateStep().ComputeResources).To(Equal(resources))
+		})
+
+		It("should replace environment variables with the same name", func() {
+			builder.WithStepEnv("renovate",
+				corev1.EnvVar{Name: "LOG_LEVEL", Value: "info"},
+				corev1.EnvVar{Name: "NEW_VAR", Value: "value"},
+			)
+			env := renovateStep().Env
+			Expect(env).To(ContainElements(
+				corev1.EnvVar{Name: "LOG_LEVEL", Value: "info"},
+				corev1.EnvVar{Name: "NEW_VAR", Value: "value"},
+			))
+			Expect(env).NotTo(ContainElement(corev1.EnvVar{Name: "LOG_LEVEL", Value: "debug"}))
+		})
+
+		It("should return an error for an unknown step", func() {
+			builder.WithStepImage("unknown", "image")
+			_, err := builder.Build()
+			Expect(err).To(MatchError(ContainSubstring("step unknown not found")))
+		})
+	})
+
+	When("pod template methods are called", func() {
+		It("should set the scheduling constraints of the pods", func() {
+			builder := NewPipelineRunBuilder("testPrefix", "testNamespace")
+			toleration := corev1.Toleration{Key: "dedicated", Value: "renovate", Effect: corev1.TaintEffectNoSchedule}
+			affinity := &corev1.Affinity{
+				NodeAffinity: &corev1.NodeAffinity{
+					PreferredDuringSchedulingIgnoredDuringExecution: []corev1.PreferredSchedulingTerm{{Weight: 1}},
+				},
+			}
+			builder.WithNodeSelector(map[string]string{"pool": "renovate"}).
+				WithTolerations(toleration).
+				WithAffinity(affinity)
+
+			template := builder.pipelineRun.Spec.TaskRunTemplate.PodTemplate
+			Expect(template).NotTo(BeNil())
+			Expect(template.NodeSelector).To(Equal(map[string]string{"pool": "renovate"}))
+			Expect(template.Tolerations).To(Equal([]corev1.Toleration{toleration}))
+			Expect(template.Affinity).To(Equal(affinity))
+		})
+
+		It("should not set a pod template without constraints", func() {
+			builder := NewPipelineRunBuilder("testPrefix", "testNamespace")
+			builder.WithNodeSelector(nil).WithTolerations().WithAffinity(nil)
+			Expect(builder.pipelineRun.Spec.TaskRunTemplate.PodTemplate).To(BeNil())
+		})
+	})
+
+	When("ConfigMaps and Secrets are mounted", func() {
+		var builder *PipelineRunBuilder
+
+		BeforeEach(func() {
+			builder = NewPipelineRunBuilder("testPrefix", "testNamespace")
+		})
+
+		stepMounts := func(stepName string) []corev1.VolumeMount {
+			for _, step := range builder.pipelineRun.Spec.PipelineSpec.Tasks[0].TaskSpec.Steps {
+				if step.Name == stepName {
+					return step.VolumeMounts
+				}
+			}
+			return nil
+		}
+
+		It("should mount the volumes to the given steps", func() {
+			builder.WithConfigMap("config", "/etc/renovate/config", nil, NewMountOptions().WithStepNames([]string{"renovate"})).
+				WithSecret("token", "/etc/renovate/secret", nil, NewMountOptions().WithStepNames([]string{"renovate", "prepare-db"}))
+
+			pipelineRun, err := builder.Build()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(pipelineRun.Spec.PipelineSpec.Tasks[0].TaskSpec.Volumes).To(ConsistOf(
+				HaveField("Name", volumeName("configmap", "config")),
+				HaveField("Name", volumeName("secret", "token")),
+			))
+			Expect(stepMounts("renovate")).To(ConsistOf(
+				corev1.VolumeMount{Name: volumeName("configmap", "config"), MountPath: "/etc/renovate/config", ReadOnly: true},
+				corev1.VolumeMount{Name: volumeName("secret", "token"), MountPath: "/etc/renovate/secret", ReadOnly: true},
+			))
+			Expect(stepMounts("prepare-db")).To(ConsistOf(HaveField("Name", volumeName("secret", "token"))))
+			Expect(stepMounts("prepare-rpm-cert")).To(BeEmpty())
+		})
+
+		It("should mount the volume to all steps if no steps are given", func() {
+			builder.WithSecret("token", "/etc/renovate/secret", nil, nil)
+			Expect(stepMounts("prepare-db")).To(HaveLen(1))
+			Expect(stepMounts("prepare-rpm-cert")).To(HaveLen(1))
+			Expect(stepMounts("renovate")).To(HaveLen(1))
+		})
+
+		It("should return an error for an unknown task", func() {
+			builder.WithConfigMap("config", "/etc/renovate/config", nil, NewMountOptions().WithTaskName("biuld"))
+			_, err := builder.Build()
+			Expect(err).To(MatchError(ContainSubstring("task biuld not found")))
+		})
+
+		It("should return an error for an unknown step and not mount the volume", func() {
+			builder.WithSecret("token", "/etc/renovate/secret", nil, NewMountOptions().WithStepNames([]string{"renovate", "renovte"}))
+			_, err := builder.Build()
+			Expect(err).To(MatchError(ContainSubstring("step renovte not found in task build")))
+			Expect(builder.pipelineRun.Spec.PipelineSpec.Tasks[0].TaskSpec.Volumes).To(BeEmpty())
+			Expect(stepMounts("renovate")).To(BeEmpty())
+		})
+
+		It("should return an error when a volume name collides", func() {
+			builder.WithSecret("token", "/etc/renovate/secret", nil, NewMountOptions().WithStepNames([]string{"renovate"})).
+				WithSecret("token", "/home/renovate/.docker", nil, NewMountOptions().WithStepNames([]string{"renovate"}))
+			_, err := builder.Build()
+			Expect(err).To(MatchError(ContainSubstring("task build already has a volume with this name")))
+			Expect(stepMounts("renovate")).To(HaveLen(1))
+		})
+
+		It("should return an error when a mount path is already used by a step", func() {
+			builder.WithSecret("token", "/etc/renovate/secret", nil, NewMountOptions().WithStepNames([]string{"renovate"})).
+				WithSecret("rpm-key", "/etc/renovate/secret", nil, NewMountOptions())
+			_, err := builder.Build()
+			Expect(err).To(MatchError(ContainSubstring(fmt.Sprintf("step renovate already mounts volume %s at /etc/renovate/secret",
+				volumeName("secret", "token")))))
+			Expect(stepMounts("prepare-db")).To(BeEmpty())
+		})
+
+		It("should mount several secrets at the same path with a projected volume", func() {
+			builder.WithProjectedSecrets("/etc/renovate/secret", []corev1.SecretProjection{
+				{LocalObjectReference: corev1.LocalObjectReference{Name: "token"}},
+				{LocalObjectReference: corev1.LocalObjectReference{Name: "rpm-key"}, Optional: ptr.To(true)},
+			}, NewMountOptions().WithStepNames([]string{"renovate", "prepare-rpm-cert"}))
+
+			pipelineRun, err := builder.Build()
+			Expect(err).NotTo(HaveOccurred())
+			volumes := pipelineRun.Spec.PipelineSpec.Tasks[0].TaskSpec.Volumes
+			Expect(volumes).To(HaveLen(1))
+			Expect(volumes[0].Name).To(Equal(volumeName("projected", "token", "rpm-key")))
+			Expect(volumes[0].Projected.Sources).To(HaveLen(2))
+			Expect(volumes[0].Projected.Sources[1].Secret.Name).To(Equal("rpm-key"))
+			Expect(stepMounts("renovate")).To(ConsistOf(HaveField("MountPath", "/etc/renovate/secret")))
+			Expect(stepMounts("prepare-rpm-cert")).To(ConsistOf(HaveField("MountPath", "/etc/renovate/secret")))
+		})
+
+		It("should return all the errors", func() {
+			builder.WithConfigMap("config", "/etc/renovate/config", nil, NewMountOptions().WithTaskName("unknown")).
+				WithSecret("token", "/etc/renovate/secret", nil, NewMountOptions().WithStepNames([]string{"unknown"}))
+			_, err := builder.Build()
+			Expect(err).To(HaveOccurred())
+			Expect(err.(*multierror.Error).Errors).To(HaveLen(2))
+		})
+	})
+
+	When("volume names are generated", func() {
+		It("should generate stable names", func() {
+			Expect(volumeName("secret", "token")).To(Equal(volumeName("secret", "token")))
+			Expect(volumeName("secret", "token")).To(HavePrefix("secret-token-"))
+			Expect(volumeName("secret", "token")).NotTo(Equal(volumeName("configmap", "token")))
+		})
+
+		It("should keep long names valid DNS labels", func() {
+			long := strings.Repeat("a", 60) + ".example.com"
+			name := volumeName("configmap", long)
+			Expect(validation.IsDNS1123Label(name)).To(BeEmpty())
+			Expect(name).NotTo(Equal(volumeName("configmap", strings.Repeat("a", 60)+".example.org")))
+		})
+	})
+
+	When("a pipeline is referenced", func() {
+		var builder *PipelineRunBuilder
+
+		BeforeEach(func() {
+			builder = NewPipelineRunBuilder("testPrefix", "testNamespace").
+				WithPipelineRef(&tektonv1.PipelineRef{ResolverRef: tektonv1.ResolverRef{
+					Resolver: "bundles",
+					Params: tektonv1.Params{
+						{Name: "bundle", Value: *tektonv1.NewStructuredValues("quay.io/test/renovate-pipeline:1")},
+						{Name: "name", Value: *tektonv1.NewStructuredValues("renovate")},
+						{Name: "kind", Value: *tektonv1.NewStructuredValues("pipeline")},
+					},
+				}}, "renovate")
+		})
+
+		It("should reference the pipeline instead of embedding it", func() {
+			pipelineRun, err := builder.Build()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(pipelineRun.Spec.PipelineSpec).To(BeNil())
+			Expect(pipelineRun.Spec.PipelineRef.Resolver).To(BeEquivalentTo("bundles"))
+			Expect(pipelineRun.Spec.Status).To(BeEquivalentTo(tektonv1.PipelineRunSpecStatusPending))
+			Expect(pipelineRun.Spec.Workspaces).To(ConsistOf(
+				HaveField("Name", "shared-data"),
+			))
+		})
+
+		It("should bind ConfigMaps and Secrets to workspaces", func() {
+			items := []corev1.KeyToPath{{Key: "config.js", Path: "config.js"}}
+			builder.WithConfigMap("config", "/etc/renovate/config", items, NewMountOptions().WithWorkspaceName("renovate-config")).
+				WithSecret("token", "/etc/renovate/secret", nil, NewMountOptions().WithWorkspaceName("renovate-token"))
+
+			pipelineRun, err := builder.Build()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(pipelineRun.Spec.Workspaces).To(HaveLen(3))
+			Expect(pipelineRun.Spec.Workspaces[1].Name).To(Equal("renovate-config"))
+			Expect(pipelineRun.Spec.Workspaces[1].ConfigMap.Name).To(Equal("config"))
+			Expect(pipelineRun.Spec.Workspaces[1].ConfigMap.Items).To(Equal(items))
+			Expect(pipelineRun.Spec.Workspaces[2].Name).To(Equal("renovate-token"))
+			Expect(pipelineRun.Spec.Workspaces[2].Secret.SecretName).To(Equal("token"))
+		})
+
+		It("should return an error when a workspace is bound twice", func() {
+			builder.WithSecret("token", "/etc/renovate/secret", nil, NewMountOptions().WithWorkspaceName("shared-data"))
+			_, err := builder.Build()
+			Expect(err).To(MatchError(ContainSubstring("failed to bind token: workspace shared-data is already bound")))
+		})
+
+		It("should return an error for a mount without a workspace", func() {
+			builder.WithSecret("token", "/etc/renovate/secret", nil, NewMountOptions())
+			_, err := builder.Build()
+			Expect(err).To(MatchError(ContainSubstring("no workspace name given to bind token")))
+		})
+
+		It("should override the resources of the task in the taskRunSpecs", func() {
+			builder.WithStepResources("renovate", corev1.ResourceRequirements{
+				Limits: corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("4Gi")},
+			})
+			resources := corev1.ResourceRequirements{
+				Limits: corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("6Gi")},
+			}
+			builder.WithStepResources("renovate", resources)
+
+			pipelineRun, err := builder.Build()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(pipelineRun.Spec.TaskRunSpecs).To(Equal([]tektonv1.PipelineTaskRunSpec{{
+				PipelineTaskName: "renovate",
+				ComputeResources: &resources,
+			}}))
+		})
+
+		It("should return an error when changing the image of a step", func() {
+			builder.WithStepImage("renovate", "quay.io/test/renovate:1")
+			_, err := builder.Build()
+			Expect(err).To(MatchError(ContainSubstring("step renovate of a referenced pipeline can't be changed")))
+		})
+	})
 })
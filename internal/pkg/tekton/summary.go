@@ -0,0 +1,359 @@
+// Copyright 2024 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tekton
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/go-multierror"
+	tektonv1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+
+	"github.com/konflux-ci/mintmaker/internal/pkg/ratelimit"
+)
+
+const (
+	// RenovateSummaryResult is the result of the PipelineRun holding the
+	// RenovateSummary of the run
+	RenovateSummaryResult = "renovate-summary"
+	// RenovateDependenciesResult is the result of the PipelineRun holding the
+	// RenovateDependencies of the run
+	RenovateDependenciesResult = "renovate-dependencies"
+	// resultsBytes is the size of the summary and dependencies results
+	// together, once escaped in the termination message of the pod. The
+	// termination message is limited to 4KB, and holds the other results of
+	// the step too.
+	resultsBytes = "3000"
+	// summaryResultBytes is the size the errors of the summary result are
+	// trimmed to, the dependencies result gets the rest of resultsBytes
+	summaryResultBytes = "1000"
+	// summaryStepName is the step parsing the Renovate log
+	summaryStepName = "summarize"
+	// renovateLogFile is where Renovate writes its JSON log, besides the pod log
+	renovateLogFile = "/workspace/shared-data/renovate.log"
+	// unknownRateLimitReset is how long an exceeded quota with an unknown
+	// reset is considered exhausted, GitHub and GitLab reset quotas hourly
+	unknownRateLimitReset = time.Hour
+)
+
+// RenovateSummary is the outcome of a Renovate run, as extracted from its log
+// by the summary step
+type RenovateSummary struct {
+	// Status and result of the repository, as logged by Renovate when
+	// finishing the repository, e.g. onboarded and done
+	RepositoryStatus string `json:"repository-status"`
+	RepositoryResult string `json:"repository-result"`
+	BranchesUpdated  int    `json:"branches-updated"`
+	PRsCreated       int    `json:"prs-created"`
+	PRsUpdated       int    `json:"prs-updated"`
+	// Number of dependencies found in the repository
+	Dependencies int `json:"dependencies"`
+	// The first error messages logged by Renovate
+	Errors []string `json:"errors"`
+	// The API quota of the git platform last reported to Renovate, if any
+	RateLimit *RenovateRateLimit `json:"rate-limit,omitempty"`
+}
+
+// RenovateRateLimit is the API quota reported in the rate limit headers of the
+// last response of the git platform logged by Renovate. A run which exceeded
+// the rate limit without logging the headers has no quota left and an
+// unknown reset.
+type RenovateRateLimit struct {
+	Limit     int `json:"limit"`
+	Remaining int `json:"remaining"`
+	// Reset in seconds since the epoch or from the end of the run, 0 if unknown
+	Reset int64 `json:"reset"`
+}
+
+// RenovateDependencies are the pending updates found by a Renovate run, as
+// extracted from its log by the summary step
+type RenovateDependencies struct {
+	// Number of pending updates, Updates holds only the first ones
+	Total   int                  `json:"total"`
+	Updates []RenovateDependency `json:"updates"`
+}
+
+// RenovateDependency is a pending update of a dependency. The keys are short
+// to keep as many updates as possible in the result.
+type RenovateDependency struct {
+	Manager        string `json:"m"`
+	PackageFile    string `json:"f,omitempty"`
+	Name           string `json:"d"`
+	CurrentVersion string `json:"c,omitempty"`
+	NewVersion     string `json:"n,omitempty"`
+	UpdateType     string `json:"t,omitempty"`
+	PullRequest    int    `json:"pr,omitempty"`
+	// Whether the update fixes a vulnerability, and its severity
+	Vulnerable            bool   `json:"v,omitempty"`
+	VulnerabilitySeverity string `json:"s,omitempty"`
+}
+
+// RepositoryState is the state of a repository after a Renovate run
+type RepositoryState string
+
+const (
+	// RepositoryOnboarded is a repository Renovate processed
+	RepositoryOnboarded RepositoryState = "onboarded"
+	// RepositoryDisabled is a repository Renovate skipped, e.g. because it's
+	// disabled in its config or archived
+	RepositoryDisabled RepositoryState = "disabled"
+	// RepositoryNoConfig is a repository skipped for missing a Renovate config
+	RepositoryNoConfig RepositoryState = "no-config"
+	// RepositoryError is a repository Renovate failed to process
+	RepositoryError RepositoryState = "error"
+)
+
+// State returns the state of the repository, from the status and result
+// Renovate logged when finishing the repository. A run which didn't finish
+// the repository failed.
+func (s *RenovateSummary) State() RepositoryState {
+	switch {
+	case s.RepositoryResult == "disabled-no-config":
+		return RepositoryNoConfig
+	case s.RepositoryStatus == "disabled" || strings.HasPrefix(s.RepositoryResult, "disabled"):
+		return RepositoryDisabled
+	}
+	switch s.RepositoryResult {
+	case "archived", "blocked", "fork", "mirror", "empty":
+		return RepositoryDisabled
+	case "", "not-found", "external-host-error", "unknown-error", "config-validation":
+		return RepositoryError
+	}
+	return RepositoryOnboarded
+}
+
+// Quota returns the API quota left at the end of the run, or false if
+// Renovate didn't report it
+func (s *RenovateSummary) Quota(end time.Time) (ratelimit.Quota, bool) {
+	if s.RateLimit == nil {
+		return ratelimit.Quota{}, false
+	}
+	quota := ratelimit.Quota{
+		Limit:     s.RateLimit.Limit,
+		Remaining: s.RateLimit.Remaining,
+		Reset:     end.Add(unknownRateLimitReset),
+	}
+	if s.RateLimit.Reset > 0 {
+		quota.Reset = ratelimit.ResetTime(s.RateLimit.Reset, end)
+	}
+	return quota, true
+}
+
+// RenovateSummaryOf returns the summary of a finished PipelineRun, or nil if
+// the PipelineRun has no renovate-summary result
+func RenovateSummaryOf(pipelineRun *tektonv1.PipelineRun) (*RenovateSummary, error) {
+	for _, result := range pipelineRun.Status.Results {
+		if result.Name != RenovateSummaryResult {
+			continue
+		}
+		summary := &RenovateSummary{}
+		if err := json.Unmarshal([]byte(result.Value.StringVal), summary); err != nil {
+			return nil, fmt.Errorf("failed to parse the %s result of PipelineRun %s: %w", RenovateSummaryResult, pipelineRun.Name, err)
+		}
+		return summary, nil
+	}
+	return nil, nil
+}
+
+// RenovateDependenciesOf returns the pending updates of a finished
+// PipelineRun, or nil if the PipelineRun has no renovate-dependencies result
+func RenovateDependenciesOf(pipelineRun *tektonv1.PipelineRun) (*RenovateDependencies, error) {
+	for _, result := range pipelineRun.Status.Results {
+		if result.Name != RenovateDependenciesResult {
+			continue
+		}
+		dependencies := &RenovateDependencies{}
+		if err := json.Unmarshal([]byte(result.Value.StringVal), dependencies); err != nil {
+			return nil, fmt.Errorf("failed to parse the %s result of PipelineRun %s: %w", RenovateDependenciesResult, pipelineRun.Name, err)
+		}
+		return dependencies, nil
+	}
+	return nil, nil
+}
+
+// summaryScript reads the Renovate log line by line, as it can be large, and
+// writes the RenovateSummary and the RenovateDependencies to the results. The
+// API quota is read from the rate limit headers of the responses logged by
+// Renovate, e.g. with HTTP errors, and from the rate limit errors. The
+// results are stored in the termination message of the pod, which is limited
+// to 4KB, so only a few errors and the first updates are kept. The sizes are
+// measured once the results are escaped as strings of the termination
+// message. The updates fixing a vulnerability are kept first. The step fails
+// with the exit code of Renovate, so the PipelineRun still fails when
+// Renovate does.
+const summaryScript = `node -e '
+const fs = require("fs");
+const summary = {
+  "repository-status": "", "repository-result": "", "branches-updated": 0,
+  "prs-created": 0, "prs-updated": 0, "dependencies": 0, "errors": [],
+};
+const updates = [];
+const rateLimitHeaders = (value, depth) => {
+  if (!value || typeof value !== "object" || Array.isArray(value) || depth > 4) return undefined;
+  if ("x-ratelimit-remaining" in value || "ratelimit-remaining" in value) return value;
+  for (const child of Object.values(value)) {
+    const headers = rateLimitHeaders(child, depth + 1);
+    if (headers) return headers;
+  }
+};
+const pullRequests = {};
+const escapedSize = (value) => Buffer.byteLength(JSON.stringify(JSON.stringify(value)));
+const dependencies = (budget) => {
+  const result = { total: updates.length, updates: [] };
+  let size = escapedSize(result);
+  updates.sort((a, b) => b.v - a.v);
+  for (const update of updates) {
+    update.pr = pullRequests[update.b];
+    delete update.b;
+    // Without the quotes of the string, with the comma separating the updates
+    size += escapedSize(update) - 2 + (result.updates.length > 0 ? 1 : 0);
+    if (size > budget) break;
+    result.updates.push(update);
+  }
+  return result;
+};
+const write = () => {
+  while (summary.errors.length > 0 && escapedSize(summary) > ` + summaryResultBytes + `) summary.errors.pop();
+  fs.writeFileSync(process.argv[2], JSON.stringify(summary));
+  fs.writeFileSync(process.argv[3], JSON.stringify(dependencies(` + resultsBytes + ` - escapedSize(summary))));
+};
+const lines = require("readline").createInterface({ input: fs.createReadStream(process.argv[1]) });
+lines.on("error", (err) => { summary.errors.push("failed to read the Renovate log: " + err.message); write(); });
+lines.on("line", (line) => {
+  let entry;
+  try { entry = JSON.parse(line); } catch { return; }
+  switch (entry.msg) {
+    case "Branch created":
+    case "Branch updated":
+      summary["branches-updated"]++; break;
+    case "PR created":
+      summary["prs-created"]++; break;
+    case "PR updated":
+      summary["prs-updated"]++; break;
+    case "Dependency extraction complete":
+      summary["dependencies"] += entry.stats?.total?.depCount ?? 0; break;
+    case "Repository finished":
+      summary["repository-status"] = String(entry.status ?? "");
+      summary["repository-result"] = String(entry.result ?? ""); break;
+    case "packageFiles with updates":
+      for (const [manager, files] of Object.entries(entry.config ?? {})) {
+        for (const file of files ?? []) {
+          for (const dep of file.deps ?? []) {
+            for (const update of dep.updates ?? []) {
+              updates.push({
+                m: manager, f: file.packageFile, d: dep.depName,
+                c: dep.currentVersion ?? dep.currentValue, n: update.newVersion ?? update.newValue,
+                t: update.updateType, b: update.branchName,
+                v: Boolean(update.isVulnerabilityAlert || dep.isVulnerabilityAlert),
+                s: update.vulnerabilitySeverity ?? dep.vulnerabilitySeverity,
+              });
+            }
+          }
+        }
+      }
+      break;
+    case "branches info extended":
+      for (const branch of entry.branchesInformation ?? []) {
+        if (branch.prNo) pullRequests[branch.branchName] = branch.prNo;
+      }
+      break;
+  }
+  const headers = rateLimitHeaders(entry, 0);
+  if (headers) {
+    const header = (name) => Number(headers["x-ratelimit-" + name] ?? headers["ratelimit-" + name]) || 0;
+    summary["rate-limit"] = { limit: header("limit"), remaining: header("remaining"), reset: header("reset") };
+  }
+  if (entry.err?.message === "rate-limit-exceeded" || /rate limit exceeded/i.test(String(entry.msg))) {
+    summary["rate-limit"] = { limit: 0, reset: 0, ...summary["rate-limit"], remaining: 0 };
+  }
+  if (entry.level >= 50 && summary.errors.length < 5) {
+    summary.errors.push(String(entry.msg).slice(0, 200));
+  }
+});
+lines.on("close", write);
+' "` + renovateLogFile + `" "$(results.` + RenovateSummaryResult + `.path)" "$(results.` + RenovateDependenciesResult + `.path)"
+exit "$(cat $(steps.step-renovate.exitCode.path))"`
+
+// WithSummaryStep adds a step after the renovate step which extracts the
+// outcome of the run and the pending updates from the Renovate log, and
+// exposes them as the renovate-summary and renovate-dependencies results of
+// the PipelineRun. The step runs with the given image, which needs node, e.g.
+// the Renovate image.
+func (b *PipelineRunBuilder) WithSummaryStep(image string) *PipelineRunBuilder {
+	renovate := b.step("renovate")
+	if renovate == nil {
+		return b
+	}
+	// The renovate step continues on error for the summary to be written,
+	// the summary step then fails with its exit code
+	renovate.OnError = tektonv1.Continue
+	securityContext := renovate.SecurityContext.DeepCopy()
+	b.WithStepEnv("renovate",
+		corev1.EnvVar{Name: "LOG_FILE", Value: renovateLogFile},
+		corev1.EnvVar{Name: "LOG_FILE_LEVEL", Value: "debug"},
+	)
+
+	for i, task := range b.pipelineRun.Spec.PipelineSpec.Tasks {
+		if task.Name != "build" || task.TaskSpec == nil {
+			continue
+		}
+		taskSpec := &b.pipelineRun.Spec.PipelineSpec.Tasks[i].TaskSpec.TaskSpec
+		for _, step := range taskSpec.Steps {
+			if step.Name == summaryStepName {
+				b.err = multierror.Append(b.err, fmt.Errorf("step %s already exists", summaryStepName))
+				return b
+			}
+		}
+		taskSpec.Steps = append(taskSpec.Steps, tektonv1.Step{
+			Name:            summaryStepName,
+			Image:           image,
+			Script:          summaryScript,
+			SecurityContext: securityContext,
+			ComputeResources: corev1.ResourceRequirements{
+				Requests: corev1.ResourceList{
+					corev1.ResourceCPU:    resource.MustParse("50m"),
+					corev1.ResourceMemory: resource.MustParse("128Mi"),
+				},
+				Limits: corev1.ResourceList{
+					corev1.ResourceCPU:    resource.MustParse("200m"),
+					corev1.ResourceMemory: resource.MustParse("256Mi"),
+				},
+			},
+		})
+		taskSpec.Results = append(taskSpec.Results, tektonv1.TaskResult{
+			Name:        RenovateSummaryResult,
+			Description: "Outcome of the Renovate run",
+		}, tektonv1.TaskResult{
+			Name:        RenovateDependenciesResult,
+			Description: "Pending updates found by the Renovate run",
+		})
+		break
+	}
+
+	b.pipelineRun.Spec.PipelineSpec.Results = append(b.pipelineRun.Spec.PipelineSpec.Results, tektonv1.PipelineResult{
+		Name:        RenovateSummaryResult,
+		Description: "Outcome of the Renovate run",
+		Value:       *tektonv1.NewStructuredValues("$(tasks.build.results." + RenovateSummaryResult + ")"),
+	}, tektonv1.PipelineResult{
+		Name:        RenovateDependenciesResult,
+		Description: "Pending updates found by the Renovate run",
+		Value:       *tektonv1.NewStructuredValues("$(tasks.build.results." + RenovateDependenciesResult + ")"),
+	})
+	return b
+}
@@ -0,0 +1,246 @@
+// Copyright 2024 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tekton
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	tektonv1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+	corev1 "k8s.io/api/core/v1"
+
+	"github.com/konflux-ci/mintmaker/internal/pkg/ratelimit"
+)
+
+var _ = Describe("Renovate summary", func() {
+
+	When("the summary step is added", func() {
+		var builder *PipelineRunBuilder
+
+		BeforeEach(func() {
+			builder = NewPipelineRunBuilder("testPrefix", "testNamespace").WithSummaryStep("quay.io/test/renovate:1")
+		})
+
+		It("should add the summary step after the renovate step", func() {
+			pipelineRun, err := builder.Build()
+			Expect(err).NotTo(HaveOccurred())
+			task := pipelineRun.Spec.PipelineSpec.Tasks[0].TaskSpec
+			Expect(task.Steps[len(task.Steps)-1].Name).To(Equal("summarize"))
+			Expect(task.Steps[len(task.Steps)-1].Image).To(Equal("quay.io/test/renovate:1"))
+			Expect(task.Steps[len(task.Steps)-1].Script).To(ContainSubstring("$(results.renovate-summary.path)"))
+			Expect(task.Steps[len(task.Steps)-1].Script).To(ContainSubstring("$(results.renovate-dependencies.path)"))
+			Expect(task.Results).To(ConsistOf(
+				HaveField("Name", RenovateSummaryResult),
+				HaveField("Name", RenovateDependenciesResult),
+			))
+		})
+
+		It("should let the summary step report the failure of Renovate", func() {
+			renovate := builder.step("renovate")
+			Expect(renovate.OnError).To(Equal(tektonv1.Continue))
+			Expect(renovate.Env).To(ContainElement(corev1.EnvVar{Name: "LOG_FILE", Value: "/workspace/shared-data/renovate.log"}))
+			Expect(builder.step(summaryStepName).Script).To(HaveSuffix(`exit "$(cat $(steps.step-renovate.exitCode.path))"`))
+		})
+
+		It("should expose the summary and the dependencies as results of the PipelineRun", func() {
+			pipelineRun, err := builder.Build()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(pipelineRun.Spec.PipelineSpec.Results).To(Equal([]tektonv1.PipelineResult{{
+				Name:        RenovateSummaryResult,
+				Description: "Outcome of the Renovate run",
+				Value:       *tektonv1.NewStructuredValues("$(tasks.build.results.renovate-summary)"),
+			}, {
+				Name:        RenovateDependenciesResult,
+				Description: "Pending updates found by the Renovate run",
+				Value:       *tektonv1.NewStructuredValues("$(tasks.build.results.renovate-dependencies)"),
+			}}))
+		})
+
+		It("should keep both results within the termination message once escaped", func() {
+			node, err := exec.LookPath("node")
+			if err != nil {
+				Skip("node is not installed")
+			}
+			dir := GinkgoT().TempDir()
+			var log strings.Builder
+			for i := range 10 {
+				fmt.Fprintf(&log, `{"level": 50, "msg": "failed to look up \"package\" <%d> %s"}`+"\n", i, strings.Repeat("é", 190))
+			}
+			var deps []string
+			for i := range 200 {
+				deps = append(deps, fmt.Sprintf(`{"depName": "registry.access.redhat.com/ubi9/\"image-%d\"", "currentVersion": "9.%d", `+
+					`"updates": [{"newVersion": "9.%d", "updateType": "minor", "isVulnerabilityAlert": %t}]}`, i, i, i+1, i%10 == 0))
+			}
+			fmt.Fprintf(&log, `{"level": 20, "msg": "packageFiles with updates", "config": {"dockerfile": [{"packageFile": "Dockerfile", "deps": [%s]}]}}`+"\n",
+				strings.Join(deps, ", "))
+			logFile, summaryFile, dependenciesFile := dir+"/renovate.log", dir+"/summary", dir+"/dependencies"
+			Expect(os.WriteFile(logFile, []byte(log.String()), 0o600)).To(Succeed())
+
+			script := strings.TrimPrefix(summaryScript, "node -e '")
+			script = script[:strings.Index(script, "' \"")]
+			Expect(exec.Command(node, "-e", script, logFile, summaryFile, dependenciesFile).Run()).To(Succeed())
+
+			escapedSize := func(file string) int {
+				data, err := os.ReadFile(file)
+				Expect(err).NotTo(HaveOccurred())
+				escaped, err := json.Marshal(string(data))
+				Expect(err).NotTo(HaveOccurred())
+				return len(escaped)
+			}
+			Expect(escapedSize(summaryFile)).To(BeNumerically("<=", 1000))
+			Expect(escapedSize(summaryFile) + escapedSize(dependenciesFile)).To(BeNumerically("<=", 3000))
+
+			pipelineRun := &tektonv1.PipelineRun{}
+			for _, result := range []struct{ name, file string }{
+				{RenovateSummaryResult, summaryFile}, {RenovateDependenciesResult, dependenciesFile},
+			} {
+				data, err := os.ReadFile(result.file)
+				Expect(err).NotTo(HaveOccurred())
+				pipelineRun.Status.Results = append(pipelineRun.Status.Results, tektonv1.PipelineRunResult{
+					Name: result.name, Value: *tektonv1.NewStructuredValues(string(data)),
+				})
+			}
+			summary, err := RenovateSummaryOf(pipelineRun)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(summary.Errors).NotTo(BeEmpty())
+			dependencies, err := RenovateDependenciesOf(pipelineRun)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(dependencies.Total).To(Equal(200))
+			Expect(dependencies.Updates).NotTo(BeEmpty())
+			// The updates fixing a vulnerability are kept first
+			Expect(dependencies.Updates[0].Vulnerable).To(BeTrue())
+		})
+
+		It("should return an error when the summary step is added twice", func() {
+			builder.WithSummaryStep("quay.io/test/renovate:1")
+			_, err := builder.Build()
+			Expect(err).To(MatchError(ContainSubstring("step summarize already exists")))
+		})
+	})
+
+	When("the summary is read", func() {
+		pipelineRunWithResult := func(name, value string) *tektonv1.PipelineRun {
+			pipelineRun := &tektonv1.PipelineRun{}
+			pipelineRun.Status.Results = []tektonv1.PipelineRunResult{{
+				Name:  name,
+				Value: *tektonv1.NewStructuredValues(value),
+			}}
+			return pipelineRun
+		}
+		pipelineRunWithSummary := func(summary string) *tektonv1.PipelineRun {
+			return pipelineRunWithResult(RenovateSummaryResult, summary)
+		}
+
+		It("should parse the summary result", func() {
+			summary, err := RenovateSummaryOf(pipelineRunWithSummary(`{"repository-status": "onboarded",
+				"repository-result": "done", "branches-updated": 3, "prs-created": 2, "prs-updated": 1,
+				"dependencies": 40, "errors": ["failed to look up a package"]}`))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(*summary).To(Equal(RenovateSummary{
+				RepositoryStatus: "onboarded",
+				RepositoryResult: "done",
+				BranchesUpdated:  3,
+				PRsCreated:       2,
+				PRsUpdated:       1,
+				Dependencies:     40,
+				Errors:           []string{"failed to look up a package"},
+			}))
+		})
+
+		It("should parse the API quota reported to Renovate", func() {
+			end := time.Unix(1700000000, 0)
+			summary, err := RenovateSummaryOf(pipelineRunWithSummary(`{"repository-result": "done",
+				"rate-limit": {"limit": 5000, "remaining": 12, "reset": 1700000600}}`))
+			Expect(err).NotTo(HaveOccurred())
+			quota, ok := summary.Quota(end)
+			Expect(ok).To(BeTrue())
+			Expect(quota).To(Equal(ratelimit.Quota{Limit: 5000, Remaining: 12, Reset: time.Unix(1700000600, 0)}))
+
+			summary.RateLimit.Reset = 30
+			quota, _ = summary.Quota(end)
+			Expect(quota.Reset).To(Equal(end.Add(30 * time.Second)))
+
+			// An exceeded quota without the headers
+			summary.RateLimit = &RenovateRateLimit{}
+			quota, _ = summary.Quota(end)
+			Expect(quota).To(Equal(ratelimit.Quota{Reset: end.Add(time.Hour)}))
+
+			summary.RateLimit = nil
+			_, ok = summary.Quota(end)
+			Expect(ok).To(BeFalse())
+		})
+
+		It("should return no summary for a PipelineRun without the result", func() {
+			summary, err := RenovateSummaryOf(&tektonv1.PipelineRun{})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(summary).To(BeNil())
+		})
+
+		It("should return an error for an invalid summary", func() {
+			_, err := RenovateSummaryOf(pipelineRunWithSummary("not json"))
+			Expect(err).To(HaveOccurred())
+		})
+
+		It("should parse the dependencies result", func() {
+			dependencies, err := RenovateDependenciesOf(pipelineRunWithResult(RenovateDependenciesResult, `{"total": 3, "updates": [
+				{"m": "gomod", "f": "go.mod", "d": "golang.org/x/net", "c": "v0.20.0", "n": "v0.23.0", "t": "minor", "v": true, "s": "HIGH", "pr": 42},
+				{"m": "dockerfile", "d": "registry.access.redhat.com/ubi9", "c": "9.4", "n": "9.5", "t": "minor", "v": false}
+			]}`))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(dependencies.Total).To(Equal(3))
+			Expect(dependencies.Updates).To(Equal([]RenovateDependency{{
+				Manager:               "gomod",
+				PackageFile:           "go.mod",
+				Name:                  "golang.org/x/net",
+				CurrentVersion:        "v0.20.0",
+				NewVersion:            "v0.23.0",
+				UpdateType:            "minor",
+				PullRequest:           42,
+				Vulnerable:            true,
+				VulnerabilitySeverity: "HIGH",
+			}, {
+				Manager:        "dockerfile",
+				Name:           "registry.access.redhat.com/ubi9",
+				CurrentVersion: "9.4",
+				NewVersion:     "9.5",
+				UpdateType:     "minor",
+			}}))
+
+			dependencies, err = RenovateDependenciesOf(pipelineRunWithSummary("{}"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(dependencies).To(BeNil())
+		})
+
+		DescribeTable("should classify the state of the repository",
+			func(status, result string, state RepositoryState) {
+				summary := &RenovateSummary{RepositoryStatus: status, RepositoryResult: result}
+				Expect(summary.State()).To(Equal(state))
+			},
+			Entry("processed", "activated", "done", RepositoryOnboarded),
+			Entry("automerged", "onboarded", "automerged", RepositoryOnboarded),
+			Entry("disabled in its config", "disabled", "disabled-by-config", RepositoryDisabled),
+			Entry("archived", "unknown", "archived", RepositoryDisabled),
+			Entry("without config", "disabled", "disabled-no-config", RepositoryNoConfig),
+			Entry("failed", "unknown", "external-host-error", RepositoryError),
+			Entry("not finished", "", "", RepositoryError),
+		)
+	})
+})